@@ -0,0 +1,32 @@
+package oz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Mount point of the cgroup v2 hierarchy
+const CgroupRoot = "/sys/fs/cgroup"
+
+// OwnCgroup returns the cgroup v2 directory holding the calling process
+func OwnCgroup() (string, error) {
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return path.Join(CgroupRoot, strings.TrimPrefix(line, "0::")), nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 hierarchy found")
+}
+
+func WriteCgroupFile(dir, name, value string) error {
+	if err := ioutil.WriteFile(path.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("unable to write %s to %s: %v", value, path.Join(dir, name), err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	ozsupervise "github.com/subgraph/oz/oz-supervise"
+)
+
+func main() {
+	ozsupervise.Main()
+}
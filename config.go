@@ -2,35 +2,72 @@ package oz
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+
+	"github.com/op/go-logging"
 )
 
 type Config struct {
-	ProfileDir       string   `json:"profile_dir" desc:"Directory containing the sandbox profiles"`
-	ShellPath        string   `json:"shell_path" desc:"Path of the shell used when entering a sandbox"`
-	PrefixPath       string   `json:"prefix_path" desc:"Prefix path containing the oz executables"`
-	EtcPrefix        string   `json:"etc_prefix" desc:"Prefix for configuration files"`
-	SandboxPath      string   `json:"sandbox_path" desc:"Path of the sandboxes base"`
-	OpenVPNRunPath   string   `json:"openvpn_run_path" desc: "Path for OpenVPN run state"`
-	OpenVPNConfDir   string   `json:"openvpn_conf_dir" desc: "Path for OpenVPN conf files"`
-	OpenVPNGroup     string   `json:"openvpn_group" desc: "GID for OpenVPN process"`
-	RouteTableBase   int      `json:"route_table_base" desc: "Base for routing table"`
-	DivertSuffix     string   `json:"divert_suffix" desc:"Suffix using for dpkg-divert of application executables, can be left empty when using a divert path"`
-	DivertPath       bool     `json:"divert_path" desc:"Whether the diverted executable should be moved out of the path"`
-	NMIgnoreFile     string   `json:"nm_ignore_file" desc:"Path to the NetworkManager ignore config file, disables the warning if empty"`
-	UseFullDev       bool     `json:"use_full_dev" desc:"Give sandboxes full access to devices instead of a restricted set"`
-	AllowRootShell   bool     `json:"allow_root_shell" desc:"Allow entering a sandbox shell as root"`
-	LogXpra          bool     `json:"log_xpra" desc:"Log output of Xpra"`
-	EnableEphemerals bool     `json:"enable_ephemerals" desc:"Enable prompting to launch sandbox in ephemeral mode"`
-	EnvironmentVars  []string `json:"environment_vars" desc:"Default environment variables passed to sandboxes"`
-	DefaultGroups    []string `json:"default_groups" desc:"List of default group names that can be used inside the sandbox"`
-	EtcIncludes      []string `json:"etc_includes" desc:"Elements to include in the etc directory in the sandbox"`
+	ProfileDir             string   `json:"profile_dir" desc:"Directory containing the sandbox profiles"`
+	ShellPath              string   `json:"shell_path" desc:"Path of the shell used when entering a sandbox"`
+	PrefixPath             string   `json:"prefix_path" desc:"Prefix path containing the oz executables"`
+	EtcPrefix              string   `json:"etc_prefix" desc:"Prefix for configuration files"`
+	SandboxPath            string   `json:"sandbox_path" desc:"Path of the sandboxes base"`
+	OpenVPNRunPath         string   `json:"openvpn_run_path" desc: "Path for OpenVPN run state"`
+	OpenVPNConfDir         string   `json:"openvpn_conf_dir" desc: "Path for OpenVPN conf files"`
+	OpenVPNGroup           string   `json:"openvpn_group" desc: "GID for OpenVPN process"`
+	RouteTableBase         int      `json:"route_table_base" desc: "Base for routing table"`
+	DivertSuffix           string   `json:"divert_suffix" desc:"Suffix using for dpkg-divert of application executables, can be left empty when using a divert path"`
+	DivertPath             bool     `json:"divert_path" desc:"Whether the diverted executable should be moved out of the path"`
+	NMIgnoreFile           string   `json:"nm_ignore_file" desc:"Path to the NetworkManager ignore config file, disables the warning if empty"`
+	UseFullDev             bool     `json:"use_full_dev" desc:"Give sandboxes full access to devices instead of a restricted set"`
+	UsePivotRoot           bool     `json:"use_pivot_root" desc:"Establish the root of sandboxes with pivot_root and detach the host root instead of using chroot"`
+	AllowRootShell         bool     `json:"allow_root_shell" desc:"Allow entering a sandbox shell as root"`
+	AllowNologinShell      bool     `json:"allow_nologin_shell" desc:"Allow entering a sandbox shell as a user whose login shell is nologin"`
+	LogXpra                bool     `json:"log_xpra" desc:"Log output of Xpra"`
+	MaxCapturedOutputBytes uint64   `json:"max_captured_output_bytes" desc:"Maximum number of bytes of output logged or captured per application stream, 0 for unlimited"`
+	MaxLogLineBytes        int      `json:"max_log_line_bytes" desc:"Maximum length in bytes of a logged line of application output, the rest of a longer line is discarded"`
+	XpraStopTimeout        int      `json:"xpra_stop_timeout" desc:"Seconds to wait for xpra to stop gracefully before killing it"`
+	XpraReadyTimeout       int      `json:"xpra_ready_timeout" desc:"Seconds to wait for xpra to report its display ready before the startup of a sandbox fails"`
+	ShutdownGraceSeconds   int      `json:"shutdown_grace_seconds" desc:"Seconds to wait for the processes of a sandbox to exit on shutdown before killing them"`
+	CleanXpraWorkdir       bool     `json:"clean_xpra_workdir" desc:"Remove the xpra sockets and logs of a sandbox from its workdir when it stops"`
+	LogSeccompDenials      bool     `json:"log_seccomp_denials" desc:"Log the syscalls denied by seccomp in sandboxes, read from the kernel audit log"`
+	WatchProfile           bool     `json:"watch_profile" desc:"Reload the profiles when their files change and add the new whitelist items to the running sandboxes, for development"`
+	EnableDebugDump        bool     `json:"enable_debug_dump" desc:"Allow root to dump the goroutines and internal state of oz-init with oz debugdump"`
+	InitMemoryLimitMB      int      `json:"init_memory_limit_mb" desc:"Soft memory limit in MiB of the oz-init process of each sandbox, 0 for no limit"`
+	InitGCPercent          int      `json:"init_gc_percent" desc:"GC target percentage of the oz-init process of each sandbox, 0 keeps the Go default"`
+	EnableEphemerals       bool     `json:"enable_ephemerals" desc:"Enable prompting to launch sandbox in ephemeral mode"`
+	EnvironmentVars        []string `json:"environment_vars" desc:"Default environment variables passed to sandboxes"`
+	DefaultGroups          []string `json:"default_groups" desc:"List of default group names that can be used inside the sandbox"`
+	EtcIncludes            []string `json:"etc_includes" desc:"Elements to include in the etc directory in the sandbox"`
+	SyntheticPasswd        bool     `json:"synthetic_passwd" desc:"Generate /etc/passwd and /etc/group with only the sandbox user instead of binding the host files"`
+	DebuggerPath           string   `json:"debugger_path" desc:"Path of the debugger attached to sandboxed processes of profiles allowing ptrace"`
+	CreatedDirMode         string   `json:"created_dir_mode" desc:"Octal mode of the directories oz creates for the sandbox user"`
+	CreatedDirACL          bool     `json:"created_dir_acl" desc:"Add a default ACL for the sandbox user on the directories oz creates"`
+	AllowHomeSymlinkEscape bool     `json:"allow_home_symlink_escape" desc:"Allow whitelisted paths inside the home directory to be symlinks leading out of it"`
+	MetadataPath           string   `json:"metadata_path" desc:"Path of the JSON file describing the sandbox to applications, disabled if empty"`
+	RunProgramRateLimit    float64  `json:"run_program_rate_limit" desc:"Maximum program launches per second in a sandbox, 0 for unlimited"`
+	RunProgramBurst        int      `json:"run_program_burst" desc:"Program launches allowed in a burst above the rate limit"`
+	IPCBacklog             int      `json:"ipc_backlog" desc:"Length of the queue of pending connections to the control socket of each sandbox, 0 for the system default"`
+	IPCMaxConnections      int      `json:"ipc_max_connections" desc:"Connections to the control socket of each sandbox served at once, further ones wait in the backlog, 0 for unlimited"`
+	LogFormat              string   `json:"log_format" desc:"Format of the log messages of oz-init, text or json for one JSON object per line"`
+	ShutdownSignals        []string `json:"shutdown_signals" desc:"Signals shutting down a sandbox when received by oz-init, it must include SIGINT which the daemon sends to stop a sandbox. SIGHUP, SIGINT, SIGQUIT, SIGTERM, SIGUSR1 and SIGUSR2 not listed are forwarded to the applications"`
+
+	LogLevels map[string]string `json:"log_levels" desc:"Log levels of the subsystems of oz-init (fs, ipc, network, xpra), ie: {\"xpra\": \"INFO\"}"`
 }
 
 const OzVersion = "0.0.1"
 
+// Length in bytes of a logged line of application output when
+// max_log_line_bytes is unset
+const DefaultMaxLogLineBytes = 1024 * 1024
+
+// Subsystems of oz-init whose log level is set with log_levels
+var LogSubsystems = []string{"fs", "ipc", "network", "xpra"}
+
 var DefaultConfigPath = "/etc/oz/oz.conf"
 
 func CheckSettingsOverRide() {
@@ -86,22 +123,47 @@ var DefaultEtcIncludes = []string{
 
 func NewDefaultConfig() *Config {
 	return &Config{
-		ProfileDir:       "/var/lib/oz/cells.d",
-		ShellPath:        "/bin/bash",
-		PrefixPath:       "/usr/local",
-		EtcPrefix:        "/etc/oz",
-		SandboxPath:      "/srv/oz",
-		OpenVPNRunPath:   "/var/run/openvpn",
-		OpenVPNConfDir:   "/var/lib/oz/openvpn",
-		OpenVPNGroup:     "oz-openvpn",
-		RouteTableBase:   8000,
-		DivertPath:       true,
-		NMIgnoreFile:     "/etc/NetworkManager/conf.d/oz.conf",
-		DivertSuffix:     "",
-		UseFullDev:       false,
-		AllowRootShell:   false,
-		LogXpra:          true,
-		EnableEphemerals: false,
+		ProfileDir:             "/var/lib/oz/cells.d",
+		ShellPath:              "/bin/bash",
+		PrefixPath:             "/usr/local",
+		EtcPrefix:              "/etc/oz",
+		SandboxPath:            "/srv/oz",
+		OpenVPNRunPath:         "/var/run/openvpn",
+		OpenVPNConfDir:         "/var/lib/oz/openvpn",
+		OpenVPNGroup:           "oz-openvpn",
+		RouteTableBase:         8000,
+		DivertPath:             true,
+		NMIgnoreFile:           "/etc/NetworkManager/conf.d/oz.conf",
+		DivertSuffix:           "",
+		UseFullDev:             false,
+		UsePivotRoot:           false,
+		AllowRootShell:         false,
+		AllowNologinShell:      false,
+		SyntheticPasswd:        true,
+		LogXpra:                true,
+		MaxCapturedOutputBytes: 0,
+		MaxLogLineBytes:        DefaultMaxLogLineBytes,
+		XpraStopTimeout:        10,
+		XpraReadyTimeout:       60,
+		ShutdownGraceSeconds:   5,
+		CleanXpraWorkdir:       false,
+		LogSeccompDenials:      false,
+		WatchProfile:           false,
+		EnableDebugDump:        false,
+		InitMemoryLimitMB:      0,
+		InitGCPercent:          0,
+		EnableEphemerals:       false,
+		DebuggerPath:           "/usr/bin/gdb",
+		CreatedDirMode:         "0750",
+		CreatedDirACL:          false,
+		AllowHomeSymlinkEscape: false,
+		MetadataPath:           "/run/oz/metadata.json",
+		RunProgramRateLimit:    0,
+		RunProgramBurst:        5,
+		IPCBacklog:             0,
+		IPCMaxConnections:      64,
+		LogFormat:              "text",
+		ShutdownSignals:        []string{"SIGTERM", "SIGINT"},
 		EnvironmentVars: []string{
 			"USER", "USERNAME", "LOGNAME",
 			"LANG", "LANGUAGE", "_", "TZ=UTC",
@@ -131,6 +193,25 @@ func LoadConfig(cpath string) (*Config, error) {
 		return nil, err
 	}
 
+	switch c.LogFormat {
+	case "", "text", "json":
+	default:
+		return nil, fmt.Errorf("invalid log_format (%s), must be one of text, json", c.LogFormat)
+	}
+
+	for name, level := range c.LogLevels {
+		known := false
+		for _, s := range LogSubsystems {
+			known = known || s == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown subsystem (%s) in log_levels", name)
+		}
+		if _, err := logging.LogLevel(level); err != nil {
+			return nil, fmt.Errorf("invalid log level (%s) for %s: %v", level, name, err)
+		}
+	}
+
 	if c.DivertSuffix == "" && c.DivertPath == false {
 		c.DivertSuffix = "unsafe"
 	}
@@ -0,0 +1,64 @@
+package oz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const onlineCpusPath = "/sys/devices/system/cpu/online"
+
+// ParseCpuList parses a cpuset list such as "0-1,4" into sorted cpu numbers
+func ParseCpuList(s string) ([]int, error) {
+	seen := map[int]bool{}
+	for _, r := range strings.Split(strings.TrimSpace(s), ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpu list (%s)", s)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu range (%s) in cpu list (%s)", r, s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			seen[cpu] = true
+		}
+	}
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// CheckCpusOnline returns an error when a cpu of the list is not online
+func CheckCpusOnline(s string) error {
+	cpus, err := ParseCpuList(s)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(onlineCpusPath)
+	if err != nil {
+		return fmt.Errorf("unable to read online cpus: %v", err)
+	}
+	online, err := ParseCpuList(string(data))
+	if err != nil {
+		return err
+	}
+	available := map[int]bool{}
+	for _, cpu := range online {
+		available[cpu] = true
+	}
+	for _, cpu := range cpus {
+		if !available[cpu] {
+			return fmt.Errorf("cpu %d of cpu list (%s) is not online (%s)", cpu, s, strings.TrimSpace(string(data)))
+		}
+	}
+	return nil
+}
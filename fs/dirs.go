@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/naegelejd/go-acl"
+)
+
+// Mode of the directories created by oz when created_dir_mode is not set
+const defaultCreatedDirMode = 0750
+
+func parseDirMode(mode string) os.FileMode {
+	if mode == "" {
+		return defaultCreatedDirMode
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return defaultCreatedDirMode
+	}
+	return os.FileMode(m).Perm()
+}
+
+func permString(bits os.FileMode) string {
+	s := []byte("---")
+	if bits&4 != 0 {
+		s[0] = 'r'
+	}
+	if bits&2 != 0 {
+		s[1] = 'w'
+	}
+	if bits&1 != 0 {
+		s[2] = 'x'
+	}
+	return string(s)
+}
+
+// setCreatedDirACL adds a default ACL to a directory created by oz so that
+// everything created below it stays accessible to the sandbox user.
+func (fs *Filesystem) setCreatedDirACL(p string, uid int) error {
+	if !fs.createdDirACL {
+		return nil
+	}
+	spec := fmt.Sprintf("u::rwx,u:%d:rwx,g::%s,m::rwx,o::%s", uid, permString(fs.createdDirMode>>3&7), permString(fs.createdDirMode&7))
+	a, err := acl.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid default ACL (%s): %v", spec, err)
+	}
+	defer a.Free()
+	if err := a.SetFileDefault(p); err != nil {
+		return fmt.Errorf("failed to set default ACL on %s: %v", p, err)
+	}
+	return nil
+}
+
+// CreateUserDir creates directory p and its missing parents with the
+// configured mode, owned by the owner of parent.
+func (fs *Filesystem) CreateUserDir(p string, parent os.FileInfo) error {
+	return fs.MkdirAllChownParent(p, fs.createdDirMode, parent)
+}
+
+// CreateOwnedDir creates the directory p inside the sandbox owned by uid and gid
+// with the configured mode.
+func (fs *Filesystem) CreateOwnedDir(p string, uid, gid int) error {
+	target := fs.absPath(p)
+	if err := os.MkdirAll(target, fs.createdDirMode); err != nil {
+		return err
+	}
+	if err := os.Chown(target, uid, gid); err != nil {
+		return fmt.Errorf("failed to chown %s: %v", target, err)
+	}
+	if err := os.Chmod(target, fs.createdDirMode); err != nil {
+		return fmt.Errorf("failed to chmod %s: %v", target, err)
+	}
+	return fs.setCreatedDirACL(target, uid)
+}
@@ -16,12 +16,21 @@ import (
 )
 
 type Filesystem struct {
-	log     *logging.Logger
-	base    string
-	chroot  bool
-	xdgDirs *xdgdirs.Dirs
-	user    *user.User
-	profile *oz.Profile
+	log            *logging.Logger
+	base           string
+	chroot         bool
+	xdgDirs        *xdgdirs.Dirs
+	user           *user.User
+	profile        *oz.Profile
+	createdDirMode os.FileMode
+	createdDirACL  bool
+	// Allow sources inside the home directory to be symlinks leading out of it
+	allowHomeSymlinkEscape bool
+	// Establish the root with pivot_root instead of chroot
+	usePivotRoot bool
+	// Mount table read for the mounts of the sources, /proc/self/mountinfo
+	// when empty
+	mountInfo string
 }
 
 func NewFilesystem(config *oz.Config, log *logging.Logger, u *user.User, p *oz.Profile) *Filesystem {
@@ -34,11 +43,15 @@ func NewFilesystem(config *oz.Config, log *logging.Logger, u *user.User, p *oz.P
 		dirs.Load(u.HomeDir)
 	}
 	return &Filesystem{
-		base:    config.SandboxPath,
-		log:     log,
-		user:    u,
-		xdgDirs: dirs,
-		profile: p,
+		base:                   config.SandboxPath,
+		log:                    log,
+		user:                   u,
+		xdgDirs:                dirs,
+		profile:                p,
+		createdDirMode:         parseDirMode(config.CreatedDirMode),
+		createdDirACL:          config.CreatedDirACL,
+		allowHomeSymlinkEscape: config.AllowHomeSymlinkEscape,
+		usePivotRoot:           config.UsePivotRoot,
 	}
 }
 
@@ -102,6 +115,10 @@ const (
 	BindForce
 	BindNoFollow
 	BindAllowSetuid
+	BindPreserveMountFlags
+	BindNoExec
+	BindPreserveXattrs
+	BindRecursive
 )
 
 func (fs *Filesystem) bindResolve(from string, to string, flags int, display int) error {
@@ -130,6 +147,9 @@ func (fs *Filesystem) bindSame(p string, flags int, display int) error {
 	if err != nil {
 		return err
 	}
+	if len(ps) == 0 {
+		fs.log.Info("Whitelist path (%s) matched no file", p)
+	}
 	for _, p := range ps {
 		if err := fs.bind(p, p, flags); err != nil {
 			return err
@@ -154,6 +174,13 @@ func (fs *Filesystem) bind(from string, to string, flags int) error {
 	if src == "" {
 		src = from
 	}
+	if err := fs.checkSourceBounds(from); err != nil {
+		fs.log.Warning("Rejected bind of unsafe path: %v", err)
+		if ii {
+			return nil
+		}
+		return fmt.Errorf("failed to bind path: %v", err)
+	}
 	sinfo, err := readSourceInfo(src, cc, fs)
 	if err != nil {
 		if !ii {
@@ -167,12 +194,33 @@ func (fs *Filesystem) bind(from string, to string, flags int) error {
 		fs.log.Warning("bind target (%s) does not exist and has been ignored!", src)
 		return nil
 	}
+	msrc := src
+	sf, err := fs.openSource(src)
+	if err != nil {
+		fs.log.Warning("Rejected bind of unsafe path: %v", err)
+		if ii {
+			return nil
+		}
+		return fmt.Errorf("failed to bind path: %v", err)
+	} else if sf != nil {
+		defer sf.Close()
+		msrc = fdPath(sf)
+	}
 
 	if to == "" {
 		to = from
 	}
-	oto := to
-	to = path.Join(fs.Root(), to)
+	// Symlinks along the target are resolved inside the rootfs, the kernel
+	// would otherwise follow them out of the sandbox when mounting
+	rto, err := resolveInRoot(fs.Root(), to)
+	if err != nil {
+		return fmt.Errorf("failed to resolve bind target (%s): %v", to, err)
+	}
+	if rto != path.Join(fs.Root(), to) {
+		fs.log.Info("bind target (%s) resolved inside the sandbox to (%s)", to, strings.TrimPrefix(rto, fs.Root()))
+	}
+	to = rto
+	oto := strings.TrimPrefix(to, fs.Root())
 
 	_, err = os.Stat(to)
 	if !ff && (err == nil || !os.IsNotExist(err)) {
@@ -193,6 +241,13 @@ func (fs *Filesystem) bind(from string, to string, flags int) error {
 	if err := copyPathPermissions(fs.Root(), src, oto); err != nil {
 		return fmt.Errorf("failed to copy path permissions for (%s): %v", src, err)
 	}
+	if flags&BindPreserveXattrs != 0 {
+		// The bind shows the attributes of the source itself, the parent
+		// directories created in the rootfs need them copied
+		if err := walkPathPairs(fs.Root(), src, oto, CopyXattrs); err != nil {
+			fs.log.Warning("Extended attributes not preserved for (%s): %v", src, err)
+		}
+	}
 
 	rolog := " "
 	sulog := " "
@@ -200,16 +255,63 @@ func (fs *Filesystem) bind(from string, to string, flags int) error {
 	if flags&BindReadOnly != 0 {
 		mntflags |= syscall.MS_RDONLY
 		rolog = "(as readonly) "
-	} else {
-		flags |= syscall.MS_NOEXEC
+	} else if flags&BindNoExec != 0 {
+		mntflags |= syscall.MS_NOEXEC
+		rolog = "(as noexec) "
 	}
 	if flags&BindAllowSetuid != 0 {
 		sulog = "(setuid allowed) "
 	} else {
 		mntflags |= syscall.MS_NOSUID
 	}
+	if flags&BindPreserveMountFlags != 0 {
+		// A plain bind drops the options of the source mount, carry them
+		// over so a noexec or nosuid host mount does not lose them
+		srcflags, err := sourceMountFlags(src, fs.mountInfoPath())
+		if err != nil {
+			return fmt.Errorf("failed to preserve mount flags of (%s): %v", src, err)
+		}
+		if srcflags&syscall.MS_NOSUID != 0 {
+			sulog = " "
+		}
+		mntflags |= srcflags
+		fs.log.Info("preserving mount flags %x of source (%s)", srcflags, src)
+	}
+	if flags&BindRecursive != 0 {
+		fs.log.Info("bind mounting recursively %s%s%s -> %s", rolog, sulog, src, to)
+		return bindMountRecursive(msrc, to, mntflags, fs.mountInfoPath())
+	}
 	fs.log.Info("bind mounting %s%s%s -> %s", rolog, sulog, src, to)
-	return bindMount(src, to, mntflags)
+	return bindMount(msrc, to, mntflags)
+}
+
+// RemountNoExec binds a writable path over itself and remounts it noexec,
+// submounts are carried over with their existing flags.
+func (fs *Filesystem) RemountNoExec(p string) error {
+	target := fs.absPath(p)
+	if _, err := os.Stat(target); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := syscall.Mount(target, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind mount of %s over itself failed: %v", target, err)
+	}
+	fs.log.Info("remounting %s as noexec", target)
+	return remount(target, syscall.MS_NOEXEC|syscall.MS_NOSUID|syscall.MS_NODEV)
+}
+
+// RemountRootReadOnly makes the root of the sandbox read-only. Only the root
+// mount is changed, the writable binds and the tmpfs mounted on top of it
+// remain writable.
+func (fs *Filesystem) RemountRootReadOnly() error {
+	if !fs.chroot {
+		return fmt.Errorf("cannot remount the root read-only until Chroot() is called.")
+	}
+	fs.log.Info("remounting the root read-only")
+	// The flags of the initial rootfs mount are cleared unless given again
+	return remount("/", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NOEXEC|syscall.MS_NODEV)
 }
 
 func (fs *Filesystem) UnbindPath(to string) error {
@@ -254,15 +356,7 @@ func readSourceInfo(src string, cancreate bool, fs *Filesystem) (os.FileInfo, er
 
 	// Create the tree inside the user's home directory, chown it to the user
 
-	if err := fs.MkdirAllChownParent(src, 0750, hi); err != nil {
-		return nil, err
-	}
-
-	pinfo, err := os.Stat(path.Dir(src))
-	if err != nil {
-		return nil, err
-	}
-	if err := copyFileInfo(pinfo, src); err != nil {
+	if err := fs.CreateUserDir(src, hi); err != nil {
 		return nil, err
 	}
 
@@ -274,6 +368,9 @@ func (fs *Filesystem) BlacklistPath(target string, display int) error {
 	if err != nil {
 		return nil
 	}
+	if len(ps) == 0 {
+		fs.log.Info("Blacklist path (%s) matched no file", target)
+	}
 	for _, p := range ps {
 		if err := fs.blacklist(p); err != nil {
 			return err
@@ -312,6 +409,13 @@ func (fs *Filesystem) Chroot() error {
 	if fs.chroot {
 		return fmt.Errorf("filesystem is already in chroot()")
 	}
+	if fs.usePivotRoot {
+		if err := fs.pivotRoot(); err != nil {
+			return err
+		}
+		fs.chroot = true
+		return nil
+	}
 	fs.log.Debug("chroot to %s", fs.Root())
 	if err := syscall.Chroot(fs.Root()); err != nil {
 		return fmt.Errorf("chroot to %s failed: %v", fs.Root(), err)
@@ -323,6 +427,29 @@ func (fs *Filesystem) Chroot() error {
 	return nil
 }
 
+// pivotRoot makes the rootfs the root of the mount namespace and detaches the
+// old root, so unlike with chroot no mount of the host is left to escape to.
+// pivot_root requires the rootfs to be a mount point and the mounts to be
+// private, which setupRootfs in oz-init takes care of.
+func (fs *Filesystem) pivotRoot() error {
+	fs.log.Debug("pivot_root to %s", fs.Root())
+	if err := os.Chdir(fs.Root()); err != nil {
+		return fmt.Errorf("chdir to %s failed: %v", fs.Root(), err)
+	}
+	// The old root is stacked on top of the new one, without requiring a
+	// directory for it inside the rootfs, and is then unmounted from there
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root to %s failed: %v", fs.Root(), err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount the old root after pivot_root: %v", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return fmt.Errorf("chdir to / after pivot_root() failed: %v", err)
+	}
+	return nil
+}
+
 func (fs *Filesystem) MountProc() error {
 	err := fs.mountSpecial("/proc", "proc", 0, "")
 	if err != nil {
@@ -353,7 +480,7 @@ func (fs *Filesystem) MountSys() error {
 }
 
 func (fs *Filesystem) MountTmp() error {
-	return fs.mountSpecial("/tmp", "tmpfs", syscall.MS_NODEV, "")
+	return fs.mountSpecial("/tmp", "tmpfs", syscall.MS_NODEV, TmpfsOptions("", fs.tmpfsSizeMB()))
 }
 
 func (fs *Filesystem) MountPts() error {
@@ -363,7 +490,27 @@ func (fs *Filesystem) MountPts() error {
 }
 
 func (fs *Filesystem) MountShm() error {
-	return fs.mountSpecial("/dev/shm", "tmpfs", syscall.MS_NODEV, "")
+	return fs.mountSpecial("/dev/shm", "tmpfs", syscall.MS_NODEV, TmpfsOptions("", fs.tmpfsSizeMB()))
+}
+
+func (fs *Filesystem) tmpfsSizeMB() int {
+	if fs.profile == nil {
+		return 0
+	}
+	return fs.profile.TmpfsSizeMB
+}
+
+// TmpfsOptions returns the mount options of a tmpfs created with mode and
+// limited to sizeMB megabytes, the kernel default size is kept when it is 0
+func TmpfsOptions(mode string, sizeMB int) string {
+	opts := []string{}
+	if mode != "" {
+		opts = append(opts, "mode="+mode)
+	}
+	if sizeMB > 0 {
+		opts = append(opts, fmt.Sprintf("size=%dm", sizeMB))
+	}
+	return strings.Join(opts, ",")
 }
 
 func (fs *Filesystem) mountSpecial(path, mtype string, flags int, args string) error {
@@ -378,12 +525,69 @@ func (fs *Filesystem) mountSpecial(path, mtype string, flags int, args string) e
 	return syscall.Mount("", path, mtype, mountFlags, args)
 }
 
+// bindMount binds source on target and applies flags with a separate remount,
+// the kernel ignores MS_RDONLY and the other per-mount flags on the initial bind.
 func bindMount(source, target string, flags int) error {
 	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
 		return fmt.Errorf("bind mount of %s -> %s failed: %v", source, target, err)
 	}
-	if flags != 0 {
-		return remount(target, flags)
+	if flags == 0 {
+		return nil
+	}
+	if err := remount(target, flags); err != nil {
+		return err
+	}
+	if flags&syscall.MS_RDONLY != 0 {
+		return checkReadOnly(target)
+	}
+	return nil
+}
+
+func (fs *Filesystem) mountInfoPath() string {
+	if fs.mountInfo == "" {
+		return mountInfoPath
+	}
+	return fs.mountInfo
+}
+
+// bindMountRecursive binds source on target along with the mounts below it.
+// A remount only changes the flags of a single mount, so each of the submounts
+// listed in mountinfo is remounted with flags in addition to its own noexec,
+// nosuid and nodev.
+func bindMountRecursive(source, target string, flags int, mountinfo string) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("recursive bind mount of %s -> %s failed: %v", source, target, err)
+	}
+	if flags == 0 {
+		return nil
+	}
+	mounts, err := ReadMountInfo(mountinfo)
+	if err != nil {
+		return fmt.Errorf("unable to read mount information: %v", err)
+	}
+	for _, m := range submounts(mounts, target) {
+		if err := remount(m.MountPoint, flags|m.flags()); err != nil {
+			return err
+		}
+		if flags&syscall.MS_RDONLY != 0 {
+			if err := checkReadOnly(m.MountPoint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stRdonly is the ST_RDONLY flag reported by statfs for read-only mounts
+const stRdonly = 0x1
+
+func checkReadOnly(target string) error {
+	var sfs syscall.Statfs_t
+	if err := syscall.Statfs(target, &sfs); err != nil {
+		return fmt.Errorf("unable to verify read-only mount of %s: %v", target, err)
+	}
+	if sfs.Flags&stRdonly == 0 {
+		return fmt.Errorf("mount of %s is still writable after read-only remount", target)
 	}
 	return nil
 }
@@ -472,6 +676,12 @@ func createEmptyFile(name string, mode os.FileMode) error {
 }
 
 func copyPathPermissions(root, src, target string) error {
+	return walkPathPairs(root, src, target, copyFilePermissions)
+}
+
+// walkPathPairs calls fn for every component of target inside root, with the
+// matching component of src as long as it exists.
+func walkPathPairs(root, src, target string, fn func(src, target string) error) error {
 	current := "/"
 	sparts := strings.Split(src, "/")
 	scurrent := "/"
@@ -487,7 +697,7 @@ func copyPathPermissions(root, src, target string) error {
 				scurrent = nc
 			}
 		}
-		if err := copyFilePermissions(scurrent, target); err != nil {
+		if err := fn(scurrent, target); err != nil {
 			return err
 		}
 	}
@@ -569,10 +779,8 @@ func (fs *Filesystem) MkdirAllChownParent(pathstr string, perm os.FileMode, pare
 	}
 	st := parent.Sys().(*syscall.Stat_t)
 	os.Chown(pathstr, int(st.Uid), int(st.Gid))
-	os.Chmod(pathstr, parent.Mode().Perm())
+	// Mkdir is subject to the umask, set the requested mode explicitly
+	os.Chmod(pathstr, perm)
 
-	if err != nil {
-		return err
-	}
-	return nil
+	return fs.setCreatedDirACL(pathstr, int(st.Uid))
 }
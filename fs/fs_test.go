@@ -0,0 +1,673 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+// inMountNamespace runs fn on a thread with a private mount namespace,
+// the thread is discarded afterwards so mounts never leak to the host.
+// fn runs outside of the test goroutine and must not call t.Fatal. Only
+// threadMountInfoPath lists the mounts made by fn.
+func inMountNamespace(t *testing.T, fn func()) {
+	if os.Getuid() != 0 {
+		t.Skip("mount tests must run as root")
+	}
+	setup := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+			setup <- err
+			return
+		}
+		if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+			setup <- err
+			return
+		}
+		setup <- nil
+		fn()
+	}()
+	if err := <-setup; err != nil {
+		t.Skipf("unable to create private mount namespace: %v", err)
+	}
+	<-done
+}
+
+func writeScript(t *testing.T, dir string) string {
+	p := path.Join(dir, "run.sh")
+	if err := ioutil.WriteFile(p, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Error(err)
+		return ""
+	}
+	return p
+}
+
+func TestRemountNoExec(t *testing.T) {
+	inMountNamespace(t, func() {
+		dir, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(dir)
+		if err := syscall.Mount("", dir, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(dir, syscall.MNT_DETACH)
+
+		script := writeScript(t, dir)
+		if script == "" {
+			return
+		}
+		if err := exec.Command(script).Run(); err != nil {
+			t.Errorf("expecting script to run before remount: %v", err)
+			return
+		}
+
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), chroot: true}
+		if err := fsys.RemountNoExec(dir); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := exec.Command(script).Run(); err == nil {
+			t.Error("expecting execution to fail after noexec remount")
+		}
+		if err := ioutil.WriteFile(path.Join(dir, "data"), []byte("x"), 0644); err != nil {
+			t.Errorf("expecting path to remain writable: %v", err)
+		}
+	})
+}
+
+func TestBindReadOnly(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		src := path.Join(base, "src")
+		if err := os.Mkdir(src, 0755); err != nil {
+			t.Error(err)
+			return
+		}
+
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.bind(src, "/readonly", BindReadOnly); err != nil {
+			t.Error(err)
+			return
+		}
+		err = ioutil.WriteFile(path.Join(fsys.Root(), "readonly", "data"), []byte("x"), 0644)
+		if err == nil {
+			t.Error("expecting write to a read-only bind to fail")
+		} else if !isErrno(err, syscall.EROFS) {
+			t.Errorf("expecting EROFS writing to a read-only bind, got %v", err)
+		}
+		if err := ioutil.WriteFile(path.Join(src, "data"), []byte("x"), 0644); err != nil {
+			t.Errorf("expecting the source of a read-only bind to remain writable: %v", err)
+		}
+	})
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err == errno
+	}
+	return false
+}
+
+func TestBindNoExec(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		src := path.Join(base, "src")
+		if err := os.Mkdir(src, 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if writeScript(t, src) == "" {
+			return
+		}
+
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.bind(src, "/writable", BindNoExec); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := exec.Command(path.Join(fsys.Root(), "writable", "run.sh")).Run(); err == nil {
+			t.Error("expecting execution from a noexec writable bind to fail")
+		}
+	})
+}
+
+func TestResolveInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(path.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"root":     "/",
+		"up":       "../../..",
+		"dir/etc":  "/etc",
+		"dir/loop": "loop",
+	} {
+		if err := os.Symlink(target, path.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, expected := range map[string]string{
+		"/root/etc/passwd": "/etc/passwd",
+		"/up/tmp":          "/tmp",
+		"/dir/../../x":     "/x",
+		"/dir/etc":         "/etc",
+		"/dir/missing/y":   "/dir/missing/y",
+	} {
+		r, err := resolveInRoot(root, p)
+		if err != nil {
+			t.Errorf("unexpected error resolving %s: %v", p, err)
+		} else if r != path.Join(root, expected) {
+			t.Errorf("expecting %s to resolve to %s, got %s", p, path.Join(root, expected), r)
+		}
+	}
+	if _, err := resolveInRoot(root, "/dir/loop/z"); err == nil {
+		t.Error("expecting a symlink loop to be rejected")
+	}
+}
+
+func TestBindRejectsHomeSymlinkEscape(t *testing.T) {
+	home, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	escape := path.Join(home, "escape")
+	if err := os.Symlink("/", escape); err != nil {
+		t.Fatal(err)
+	}
+	fsys := &Filesystem{
+		log:  logging.MustGetLogger("oz-test"),
+		base: home,
+		user: &user.User{HomeDir: home},
+	}
+	if err := fsys.bind(escape, "", 0); err == nil {
+		t.Error("expecting a symlink to / in the home directory to be rejected")
+	}
+	if err := fsys.bind(path.Join(escape, "etc"), "", 0); err == nil {
+		t.Error("expecting a path through a symlink to / in the home directory to be rejected")
+	}
+	if err := fsys.bind(escape, "", BindIgnore); err != nil {
+		t.Errorf("expecting an ignored escaping path to be skipped, got %v", err)
+	}
+
+	// A missing source below an escaping symlink would be created outside
+	outside, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+	if err := os.Symlink(outside, path.Join(home, "outside")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.bind(path.Join(home, "outside", "missing"), "", BindCanCreate); err == nil {
+		t.Error("expecting a missing path through an escaping symlink to be rejected")
+	}
+	if _, err := os.Stat(path.Join(outside, "missing")); err == nil {
+		t.Error("expecting the missing path not to be created outside of the home directory")
+	}
+	if err := os.Symlink(path.Join(outside, "dangling"), path.Join(home, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.bind(path.Join(home, "dangling"), "", BindCanCreate); err == nil {
+		t.Error("expecting a dangling symlink in the home directory to be rejected")
+	}
+}
+
+func TestResolveExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	if err := os.Mkdir(path.Join(dir, "real"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path.Join(dir, "real"), path.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	for p, expected := range map[string]string{
+		path.Join(dir, "real"):                 path.Join(dir, "real"),
+		path.Join(dir, "link"):                 path.Join(dir, "real"),
+		path.Join(dir, "link", "missing", "a"): path.Join(dir, "real", "missing", "a"),
+		path.Join(dir, "missing"):              path.Join(dir, "missing"),
+	} {
+		r, err := resolveExisting(p)
+		if err != nil {
+			t.Errorf("unexpected error resolving %s: %v", p, err)
+		} else if r != expected {
+			t.Errorf("expecting %s to resolve to %s, got %s", p, expected, r)
+		}
+	}
+}
+
+func TestOpenSourceStaysInHome(t *testing.T) {
+	home, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	src := path.Join(home, "data")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), user: &user.User{HomeDir: home}}
+	f, err := fsys.openSource(src)
+	if err != nil || f == nil {
+		t.Fatalf("expecting the source in the home directory to be opened, got %v", err)
+	}
+	f.Close()
+
+	// A symlink swapped in after the check is not followed
+	os.Remove(src)
+	if err := os.Symlink("/", src); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := fsys.openSource(src); err == nil {
+		f.Close()
+		t.Error("expecting a source replaced by a symlink to be rejected")
+	}
+	if f, err := fsys.openSource("/etc"); f != nil || err != nil {
+		t.Errorf("expecting sources outside of the home directory to be left alone, got %v", err)
+	}
+}
+
+func TestBindTargetSymlinkStaysInRoot(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		src := path.Join(base, "src")
+		if err := os.Mkdir(src, 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := os.Symlink("/", path.Join(fsys.Root(), "link")); err != nil {
+			t.Error(err)
+			return
+		}
+		name := path.Base(base) + "-target"
+		if err := fsys.bind(src, path.Join("/link", name), 0); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := os.Stat(path.Join("/", name)); err == nil {
+			os.Remove(path.Join("/", name))
+			t.Error("expecting the bind target not to be created outside of the rootfs")
+		}
+		if _, err := os.Stat(path.Join(fsys.Root(), name)); err != nil {
+			t.Errorf("expecting the bind target inside the rootfs: %v", err)
+		}
+	})
+}
+
+func TestChrootPivotRoot(t *testing.T) {
+	// Created outside of the namespace, the host is no longer reachable after pivot_root
+	base, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	inMountNamespace(t, func() {
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base, usePivotRoot: true}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := syscall.Mount("", fsys.Root(), "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := ioutil.WriteFile(path.Join(fsys.Root(), "marker"), []byte("x"), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.Chroot(); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := os.Stat("/marker"); err != nil {
+			t.Errorf("expecting the rootfs to be the new root: %v", err)
+		}
+		if _, err := os.Stat(base); err == nil {
+			t.Error("expecting the old root to be detached after pivot_root")
+		}
+	})
+}
+
+func TestTmpfsOptions(t *testing.T) {
+	for _, tc := range []struct {
+		mode     string
+		sizeMB   int
+		expected string
+	}{
+		{"", 0, ""},
+		{"1777", 0, "mode=1777"},
+		{"", 64, "size=64m"},
+		{"777", 256, "mode=777,size=256m"},
+	} {
+		if opts := TmpfsOptions(tc.mode, tc.sizeMB); opts != tc.expected {
+			t.Errorf("expecting options %q for mode %q and %d MB, got %q", tc.expected, tc.mode, tc.sizeMB, opts)
+		}
+	}
+}
+
+func TestTmpfsSizeLimit(t *testing.T) {
+	inMountNamespace(t, func() {
+		dir, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(dir)
+		if err := syscall.Mount("", dir, "tmpfs", 0, TmpfsOptions("777", 2)); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(dir, syscall.MNT_DETACH)
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(dir, &st); err != nil {
+			t.Error(err)
+			return
+		}
+		if size := uint64(st.Blocks) * uint64(st.Bsize); size != 2*1024*1024 {
+			t.Errorf("expecting a tmpfs of 2 MB, got %d bytes", size)
+		}
+	})
+}
+
+func TestRemountRootReadOnly(t *testing.T) {
+	base, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	inMountNamespace(t, func() {
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base, usePivotRoot: true}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := syscall.Mount("", fsys.Root(), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		src := path.Join(base, "src")
+		for _, d := range []string{src, path.Join(fsys.Root(), "tmp")} {
+			if err := os.Mkdir(d, 0755); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := fsys.bind(src, "/home", 0); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.Chroot(); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.RemountRootReadOnly(); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.mountSpecial("/tmp", "tmpfs", syscall.MS_NODEV, ""); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := ioutil.WriteFile("/data", []byte("x"), 0644); err == nil {
+			t.Error("expecting a write to the read-only root to fail")
+		} else if !isErrno(err, syscall.EROFS) {
+			t.Errorf("expecting EROFS writing to the root, got %v", err)
+		}
+		for _, p := range []string{"/tmp/data", "/home/data"} {
+			if err := ioutil.WriteFile(p, []byte("x"), 0644); err != nil {
+				t.Errorf("expecting %s to remain writable: %v", p, err)
+			}
+		}
+	})
+}
+
+func TestResolvePathGlob(t *testing.T) {
+	home, err := ioutil.TempDir("", "oz-fs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	for _, f := range []string{"a.pdf", "b.pdf", "c.txt"} {
+		if err := ioutil.WriteFile(path.Join(home, f), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := &user.User{HomeDir: home}
+	for p, expected := range map[string][]string{
+		"~/*.pdf":        {"a.pdf", "b.pdf"},
+		"${HOME}/?.txt":  {"c.txt"},
+		"~/[ab].pdf":     {"a.pdf", "b.pdf"},
+		"~/c.txt":        {"c.txt"},
+		"~/*.doc":        {},
+		"~/missing.file": {"missing.file"},
+	} {
+		ps, err := resolvePath(p, -1, u, nil, nil)
+		if err != nil {
+			t.Errorf("unexpected error resolving %s: %v", p, err)
+			continue
+		}
+		abs := []string{}
+		for _, e := range expected {
+			abs = append(abs, path.Join(home, e))
+		}
+		if len(ps) != len(abs) || (len(ps) > 0 && !reflect.DeepEqual(ps, abs)) {
+			t.Errorf("expecting %s to resolve to %v, got %v", p, abs, ps)
+		}
+	}
+}
+
+func TestBindRecursive(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		nested := path.Join(base, "src", "nested")
+		if err := os.MkdirAll(nested, 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := syscall.Mount("", nested, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := ioutil.WriteFile(path.Join(nested, "data"), []byte("x"), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base, mountInfo: threadMountInfoPath}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		src := path.Join(base, "src")
+		if err := fsys.bind(src, "/plain", 0); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := os.Stat(path.Join(fsys.Root(), "plain", "nested", "data")); err == nil {
+			t.Error("expecting the nested mount to be missing from a plain bind")
+		}
+		if err := fsys.bind(src, "/recursive", BindRecursive|BindReadOnly); err != nil {
+			t.Error(err)
+			return
+		}
+		data := path.Join(fsys.Root(), "recursive", "nested", "data")
+		if _, err := os.Stat(data); err != nil {
+			t.Errorf("expecting the nested mount in a recursive bind: %v", err)
+		}
+		if err := ioutil.WriteFile(data, []byte("y"), 0644); err == nil {
+			t.Error("expecting the nested mount of a read-only recursive bind to be read-only")
+		}
+	})
+}
+
+func TestParseMountInfo(t *testing.T) {
+	info := `22 1 0:20 / / rw,nosuid,nodev,noexec - tmpfs tmpfs rw,mode=755
+23 22 253:1 /home/alice /home/alice rw,nosuid,nodev shared:1 master:2 - ext4 /dev/mapper/home rw
+24 22 253:1 /data /media/my\040files ro,nosuid,nodev - ext4 /dev/sda\0401 rw
+truncated line
+`
+	mounts, err := parseMountInfo(strings.NewReader(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []MountInfo{
+		{MountPoint: "/", Source: "tmpfs", FSType: "tmpfs", Options: []string{"rw", "nosuid", "nodev", "noexec"}},
+		{MountPoint: "/home/alice", Source: "/dev/mapper/home", FSType: "ext4", Options: []string{"rw", "nosuid", "nodev"}},
+		{MountPoint: "/media/my files", Source: "/dev/sda 1", FSType: "ext4", Options: []string{"ro", "nosuid", "nodev"}},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("expecting mounts %+v, got %+v", expected, mounts)
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	mounts := []MountInfo{
+		{MountPoint: "/", Options: []string{"rw"}},
+		{MountPoint: "/home", Options: []string{"rw", "nosuid", "nodev"}},
+		{MountPoint: "/home/user/media", Options: []string{"ro", "noexec"}},
+		{MountPoint: "/home", Options: []string{"rw", "noexec"}},
+	}
+	for _, test := range []struct {
+		path  string
+		flags int
+	}{
+		{"/usr/bin", 0},
+		{"/home/user", syscall.MS_NOEXEC},
+		{"/home/user/media/video", syscall.MS_NOEXEC},
+		{"/homework", 0},
+	} {
+		m := findMount(mounts, test.path)
+		if m == nil {
+			t.Errorf("expecting a mount for %s", test.path)
+			continue
+		}
+		if flags := m.flags(); flags != test.flags {
+			t.Errorf("expecting flags %x for %s, got %x", test.flags, test.path, flags)
+		}
+	}
+}
+
+func TestBindPreserveMountFlags(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-fs-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		src := path.Join(base, "src")
+		if err := os.Mkdir(src, 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := syscall.Mount("", src, "tmpfs", syscall.MS_NOEXEC, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		if writeScript(t, src) == "" {
+			return
+		}
+
+		fsys := &Filesystem{log: logging.MustGetLogger("oz-test"), base: base, mountInfo: threadMountInfoPath}
+		if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := fsys.bind(src, "/preserved", BindPreserveMountFlags|BindAllowSetuid); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := exec.Command(path.Join(fsys.Root(), "preserved", "run.sh")).Run(); err == nil {
+			t.Error("expecting the noexec flag of the source mount to be preserved")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	mountInfoPath = "/proc/self/mountinfo"
+	// The mounts seen by the calling thread, which may have its own mount
+	// namespace, rather than those of the main thread
+	threadMountInfoPath = "/proc/thread-self/mountinfo"
+)
+
+// MountInfo is a mount listed in a mountinfo file
+type MountInfo struct {
+	MountPoint string
+	Source     string
+	FSType     string
+	Options    []string
+}
+
+// ReadMountInfo returns the mounts listed in the mountinfo file, in mount order
+func ReadMountInfo(mountinfo string) ([]MountInfo, error) {
+	f, err := os.Open(mountinfo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+func parseMountInfo(r io.Reader) ([]MountInfo, error) {
+	mounts := []MountInfo{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(fields) < sep+3 {
+			continue
+		}
+		mounts = append(mounts, MountInfo{
+			MountPoint: unescapeMountPath(fields[4]),
+			Source:     unescapeMountPath(fields[sep+2]),
+			FSType:     fields[sep+1],
+			Options:    strings.Split(fields[5], ","),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// Mount points in mountinfo have spaces, tabs, newlines and backslashes escaped in octal
+func unescapeMountPath(p string) string {
+	if !strings.Contains(p, "\\") {
+		return p
+	}
+	out := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+3 < len(p) {
+			if c, err := strconv.ParseUint(p[i+1:i+4], 8, 8); err == nil {
+				out = append(out, byte(c))
+				i += 3
+				continue
+			}
+		}
+		out = append(out, p[i])
+	}
+	return string(out)
+}
+
+// findMount returns the mount containing path p, mounts listed later shadow earlier ones.
+func findMount(mounts []MountInfo, p string) *MountInfo {
+	var found *MountInfo
+	for i := range mounts {
+		mp := mounts[i].MountPoint
+		if mp != "/" && p != mp && !strings.HasPrefix(p, mp+"/") {
+			continue
+		}
+		if found == nil || len(mp) >= len(found.MountPoint) {
+			found = &mounts[i]
+		}
+	}
+	return found
+}
+
+// sourceMountFlags returns the noexec, nosuid and nodev flags of the mount holding path p.
+func sourceMountFlags(p, mountinfo string) (int, error) {
+	mounts, err := ReadMountInfo(mountinfo)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read mount information: %v", err)
+	}
+	m := findMount(mounts, p)
+	if m == nil {
+		return 0, fmt.Errorf("no mount found for path (%s)", p)
+	}
+	return m.flags(), nil
+}
+
+// submounts returns the mounts at or below mount point p, in mount order.
+func submounts(mounts []MountInfo, p string) []MountInfo {
+	found := []MountInfo{}
+	for _, m := range mounts {
+		if m.MountPoint == p || strings.HasPrefix(m.MountPoint, p+"/") {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// flags returns the noexec, nosuid and nodev flags of the mount.
+func (m *MountInfo) flags() int {
+	flags := 0
+	for _, opt := range m.Options {
+		switch opt {
+		case "noexec":
+			flags |= syscall.MS_NOEXEC
+		case "nosuid":
+			flags |= syscall.MS_NOSUID
+		case "nodev":
+			flags |= syscall.MS_NODEV
+		}
+	}
+	return flags
+}
@@ -34,7 +34,6 @@ func resolveVars(p string, d int, u *user.User, xdgDirs *xdgdirs.Dirs, profile *
 	const userVar = "${USER}"
 	const displayVar = "${DISPLAY}"
 
-	fmt.Println("IS XDG: %s => %b", p, xdgdirs.IsXDGDir(p))
 	switch {
 	case strings.HasPrefix(p, pathVar):
 		emptyPath := false
@@ -57,6 +56,12 @@ func resolveVars(p string, d int, u *user.User, xdgDirs *xdgdirs.Dirs, profile *
 		}
 		return path.Join(u.HomeDir, p[len(homeVar):]), nil
 
+	case p == "~" || strings.HasPrefix(p, "~/"):
+		if u == nil {
+			return p, nil
+		}
+		return path.Join(u.HomeDir, p[1:]), nil
+
 	case strings.Contains(p, displayVar):
 		if d < 0 {
 			return p, nil
@@ -90,7 +95,7 @@ func resolveVars(p string, d int, u *user.User, xdgDirs *xdgdirs.Dirs, profile *
 }
 
 func isGlobbed(p string) bool {
-	return strings.Contains(p, "*")
+	return strings.ContainsAny(p, "*?[")
 }
 
 func resolveGlob(p string) ([]string, error) {
@@ -0,0 +1,164 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Maximum number of symlinks followed resolving a path inside the sandbox
+const maxSymlinks = 255
+
+// resolveInRoot joins p to root resolving every symlink along p as if root
+// was the filesystem root, so absolute symlinks and dot-dot components can
+// never lead out of root. Missing components are kept as they are.
+func resolveInRoot(root, p string) (string, error) {
+	parts := strings.Split(path.Clean("/"+p), "/")
+	resolved := "/"
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, part)
+		fi, err := os.Lstat(path.Join(root, next))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+			resolved = next
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks resolving (%s)", p)
+		}
+		target, err := os.Readlink(path.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return path.Join(root, resolved), nil
+}
+
+func isPathUnder(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// resolveExisting resolves the symlinks of the longest prefix of p which
+// exists, the missing components are appended to it as they are. A dangling
+// symlink is an error, creating the missing path would follow it.
+func resolveExisting(p string) (string, error) {
+	missing := []string{}
+	for {
+		r, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return path.Join(append([]string{r}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if fi, lerr := os.Lstat(p); lerr == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("path (%s) is a dangling symlink", p)
+		}
+		if p == "/" || p == "." {
+			return "", err
+		}
+		missing = append([]string{path.Base(p)}, missing...)
+		p = path.Dir(p)
+	}
+}
+
+// boundedHome returns the resolved home directory when the sources below it
+// must not resolve outside of it.
+func (fs *Filesystem) boundedHome(p string) (string, bool) {
+	if fs.user == nil || fs.user.HomeDir == "" || fs.allowHomeSymlinkEscape {
+		return "", false
+	}
+	home := path.Clean(fs.user.HomeDir)
+	if !isPathUnder(path.Clean(p), home) {
+		return "", false
+	}
+	if rhome, err := filepath.EvalSymlinks(home); err == nil {
+		home = rhome
+	}
+	return home, true
+}
+
+// checkSourceBounds rejects a source inside the home directory of the user
+// which resolves outside of it. The paths of the home are writable by the
+// sandboxed applications, a symlink planted there must not give the next
+// sandbox access to the rest of the host. A missing source is checked
+// through its longest existing prefix, as it is created before the bind.
+func (fs *Filesystem) checkSourceBounds(from string) error {
+	home, ok := fs.boundedHome(from)
+	if !ok {
+		return nil
+	}
+	src, err := resolveExisting(path.Clean(from))
+	if err != nil {
+		return fmt.Errorf("unable to resolve path (%s): %v", from, err)
+	}
+	if !isPathUnder(src, home) {
+		return fmt.Errorf("path (%s) resolves to (%s) outside of the home directory (%s)", from, src, home)
+	}
+	return nil
+}
+
+// openSource opens a source inside the home directory without following it,
+// and checks the opened file is still inside the home. The bind is made from
+// the descriptor so a symlink swapped in after checkSourceBounds is never
+// followed. nil is returned for the sources outside of the home.
+func (fs *Filesystem) openSource(src string) (*os.File, error) {
+	home, ok := fs.boundedHome(src)
+	if !ok {
+		return nil, nil
+	}
+	fd, err := unix.Open(src, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open path (%s): %v", src, err)
+	}
+	f := os.NewFile(uintptr(fd), src)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to stat path (%s): %v", src, err)
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		f.Close()
+		return nil, fmt.Errorf("path (%s) was replaced by a symlink", src)
+	}
+	opened, err := os.Readlink(fdPath(f))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to read the path of (%s): %v", src, err)
+	}
+	if !isPathUnder(opened, home) {
+		f.Close()
+		return nil, fmt.Errorf("path (%s) was opened as (%s) outside of the home directory (%s)", src, opened, home)
+	}
+	return f, nil
+}
+
+// fdPath returns a path referring to the file opened as f
+func fdPath(f *os.File) string {
+	return fmt.Sprintf("/proc/self/fd/%d", f.Fd())
+}
@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+func listXattrs(p string) ([]string, error) {
+	sz, err := syscall.Listxattr(p, nil)
+	if err != nil || sz == 0 {
+		return nil, err
+	}
+	buf := make([]byte, sz)
+	sz, err = syscall.Listxattr(p, buf)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, n := range bytes.Split(buf[:sz], []byte{0}) {
+		if len(n) > 0 {
+			names = append(names, string(n))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(p, name string) ([]byte, error) {
+	sz, err := syscall.Getxattr(p, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, sz)
+	sz, err = syscall.Getxattr(p, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:sz], nil
+}
+
+// CopyXattrs copies the extended attributes of src to target. Every attribute
+// is attempted and the ones which could not be copied, usually because the
+// filesystem of target does not support their namespace, are reported.
+func CopyXattrs(src, target string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		return fmt.Errorf("unable to list extended attributes of %s: %v", src, err)
+	}
+	failed := []string{}
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err == nil {
+			err = syscall.Setxattr(target, name, value, 0)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to copy extended attributes of %s to %s: %s", src, target, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-xattr-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := path.Join(dir, "src")
+	dst := path.Join(dir, "dst")
+	for _, p := range []string{src, dst} {
+		if err := ioutil.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := syscall.Setxattr(src, "user.oz_test", []byte("value"), 0); err != nil {
+		t.Skipf("user extended attributes not supported: %v", err)
+	}
+	// A SELinux labeled source keeps its label
+	label, _ := getXattr(src, "security.selinux")
+
+	if err := CopyXattrs(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := getXattr(dst, "user.oz_test"); err != nil || string(v) != "value" {
+		t.Errorf("expecting user.oz_test to be copied, got %q %v", v, err)
+	}
+	if label != nil {
+		if v, err := getXattr(dst, "security.selinux"); err != nil || string(v) != string(label) {
+			t.Errorf("expecting SELinux label %q to be copied, got %q %v", label, v, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net"
+	"os"
 	"syscall"
 
 	"encoding/binary"
@@ -11,6 +12,7 @@ import (
 	"github.com/op/go-logging"
 	"io"
 	"reflect"
+	"sync"
 )
 
 const maxFdCount = 3
@@ -28,6 +30,7 @@ type MsgConn struct {
 	idGen    <-chan int
 	respMan  *responseManager
 	onClose  func()
+	release  func()
 }
 
 type MsgServer struct {
@@ -38,15 +41,30 @@ type MsgServer struct {
 	listener *net.UnixListener
 	done     chan bool
 	idGen    <-chan int
+	conns    chan struct{}
+}
+
+// ServerOptions tune how a server accepts connections, zero values keep the defaults
+type ServerOptions struct {
+	// Length of the queue of connections waiting to be accepted, the system
+	// default (net.core.somaxconn) when 0
+	Backlog int
+	// Maximum number of connections served at once, further connections wait
+	// in the backlog until one is closed or detached. Unbounded when 0
+	MaxConns int
 }
 
 func NewServer(address string, factory MsgFactory, log *logging.Logger, handlers ...interface{}) (*MsgServer, error) {
+	return NewServerWithOptions(address, ServerOptions{}, factory, log, handlers...)
+}
+
+func NewServerWithOptions(address string, opts ServerOptions, factory MsgFactory, log *logging.Logger, handlers ...interface{}) (*MsgServer, error) {
 	md, err := createDispatcher(log, handlers...)
 	if err != nil {
 		return nil, err
 	}
 
-	listener, err := net.ListenUnix("unix", &net.UnixAddr{address, "unix"})
+	listener, err := listenUnix(address, opts.Backlog)
 	if err != nil {
 		md.close()
 		return nil, err
@@ -56,6 +74,10 @@ func NewServer(address string, factory MsgFactory, log *logging.Logger, handlers
 	}
 	done := make(chan bool)
 	idGen := newIdGen(done)
+	var conns chan struct{}
+	if opts.MaxConns > 0 {
+		conns = make(chan struct{}, opts.MaxConns)
+	}
 	return &MsgServer{
 		log:      log,
 		disp:     md,
@@ -63,11 +85,65 @@ func NewServer(address string, factory MsgFactory, log *logging.Logger, handlers
 		listener: listener,
 		done:     done,
 		idGen:    idGen,
+		conns:    conns,
 	}, nil
 }
 
+// listenUnix listens on address with a backlog of the given length, net.ListenUnix
+// does not allow choosing it.
+func listenUnix(address string, backlog int) (*net.UnixListener, error) {
+	if backlog <= 0 {
+		return net.ListenUnix("unix", &net.UnixAddr{address, "unix"})
+	}
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrUnix{Name: address}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind %s: %v", address, err)
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("listen %s: %v", address, err)
+	}
+	f := os.NewFile(uintptr(fd), address)
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	ul := l.(*net.UnixListener)
+	// Remove the socket file on close like a listener created by net.ListenUnix
+	ul.SetUnlinkOnClose(true)
+	return ul, nil
+}
+
+// acquireConn waits for a free connection slot, false is returned once the
+// server is closed.
+func (s *MsgServer) acquireConn() bool {
+	if s.conns == nil {
+		return true
+	}
+	select {
+	case s.conns <- struct{}{}:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *MsgServer) releaseConn() {
+	if s.conns != nil {
+		<-s.conns
+	}
+}
+
 func (s *MsgServer) Run() error {
 	for !s.isClosed {
+		if !s.acquireConn() {
+			return nil
+		}
 		conn, err := s.listener.AcceptUnix()
 		if err != nil {
 			if s.isClosed {
@@ -88,7 +164,12 @@ func (s *MsgServer) Run() error {
 			idGen:   s.idGen,
 			respMan: newResponseManager(),
 		}
-		go mc.readLoop()
+		var once sync.Once
+		mc.release = func() { once.Do(s.releaseConn) }
+		go func() {
+			mc.readLoop()
+			mc.release()
+		}()
 	}
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"sync"
 	"testing"
+	"time"
 )
 
 type TestMsg struct {
@@ -97,3 +98,85 @@ func TestPassFDs(t *testing.T) {
 
 	})
 }
+
+func TestConcurrentConnections(t *testing.T) {
+	const clients = 50
+	var handled sync.WaitGroup
+	handled.Add(clients)
+	handler := func(tm *TestMsg, msg *Message) error {
+		handled.Done()
+		return nil
+	}
+	s, err := NewServerWithOptions("@test-concurrent", ServerOptions{Backlog: clients, MaxConns: 4}, testFactory, nil, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	errs := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			c, err := Connect("@test-concurrent", testFactory, nil)
+			if err != nil {
+				errs <- err
+				handled.Done()
+				return
+			}
+			// The message is still read by the server once the client closed
+			err = c.SendMsg(&TestMsg{})
+			c.Close()
+			if err != nil {
+				errs <- err
+				handled.Done()
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		handled.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the connections to be served")
+	}
+	close(errs)
+	for err := range errs {
+		t.Errorf("connection failed: %v", err)
+	}
+}
+
+func TestDetachedConnection(t *testing.T) {
+	handled := make(chan bool, 2)
+	handler := func(tm *TestMsg, msg *Message) error {
+		msg.Detach()
+		handled <- true
+		return nil
+	}
+	s, err := NewServerWithOptions("@test-detach", ServerOptions{MaxConns: 1}, testFactory, nil, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	// The first connection stays open, the second one is still served once
+	// the first one detached
+	for i := 0; i < 2; i++ {
+		c, err := Connect("@test-detach", testFactory, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if err := c.SendMsg(&TestMsg{}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for connection %d to be served", i+1)
+		}
+	}
+}
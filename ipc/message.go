@@ -115,6 +115,14 @@ func (m *Message) parseControlData(data []byte) error {
 	return nil
 }
 
+// Detach stops counting the connection of the message against the MaxConns
+// limit of the server, for handlers keeping it open to stream to the client.
+func (m *Message) Detach() {
+	if m.mconn != nil && m.mconn.release != nil {
+		m.mconn.release()
+	}
+}
+
 func (m *Message) Respond(msg interface{}, fds ...int) error {
 	return m.mconn.sendMessage(msg, m.MsgID, fds...)
 }
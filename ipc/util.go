@@ -1,25 +1,19 @@
 package ipc
 
 import (
-	"reflect"
 	"syscall"
 )
 
-func setPassCred(c interface{}) error {
-	fd := reflectFD(c)
-	return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
-}
-
-func reflectFD(c interface{}) int {
-	sysfd := extractField(c, "fd", "sysfd")
-	return int(sysfd.Int())
-}
-
-func extractField(ob interface{}, fieldNames ...string) reflect.Value {
-	v := reflect.Indirect(reflect.ValueOf(ob))
-	for _, fn := range fieldNames {
-		field := v.FieldByName(fn)
-		v = reflect.Indirect(field)
+func setPassCred(c syscall.Conn) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	}); err != nil {
+		return err
 	}
-	return v
+	return serr
 }
@@ -5,9 +5,20 @@ import (
 	"fmt"
 	"github.com/milosgajdos83/tenus"
 	"github.com/op/go-logging"
+	"io/ioutil"
 	"net"
+	"os"
+	"strings"
 )
 
+// Unique local prefix the IPv4 addresses of the bridges and sandboxes are
+// embedded in to give them an IPv6 address on their bridge (ie:
+// fd6f:7a00::a01:203 for 10.1.2.3). The prefix is neither routed nor NATed.
+var ipv6Prefix = net.ParseIP("fd6f:7a00::")
+
+// ErrNoIPv6 is returned by SetupIPv6 when IPv6 is disabled on the host
+var ErrNoIPv6 = errors.New("IPv6 is not available on the host")
+
 // Bridges manages the creation of bridges for sandbox bridged networking
 type Bridges struct {
 	log         *logging.Logger      // global logger
@@ -22,6 +33,8 @@ type OzBridge struct {
 	Name          string          // Name of bridge
 	ipr           *IPRange        // IPRange for allocating addresses to veth interfaces
 	ip            *net.IP         // IP assigned to the bridge itself
+	ip6           *net.IP         // IPv6 assigned to the bridge once a sandbox requests it
+	ip6net        *net.IPNet      // IPv6 network of the bridge
 	veths         map[int]*OzVeth // map from sandbox id to OzVeth instances
 	log           *logging.Logger
 }
@@ -33,6 +46,7 @@ type OzVeth struct {
 	peerPid      int       // The process id of the init process of the sandbox this veth pair belongs to
 	bridge       *OzBridge // The bridge this veth pair is attached to
 	sbip         net.IP    // The sandbox's IP through the bridge
+	sbip6        net.IP    // The sandbox's bridge local IPv6, if enabled
 	log          *logging.Logger
 }
 
@@ -53,15 +67,42 @@ func (b *OzBridge) configure() error {
 	return nil
 }
 
+// configureIPv6 gives the bridge the IPv6 address embedding its IPv4 address
+func (b *OzBridge) configureIPv6() error {
+	if b.ip6 != nil {
+		return nil
+	}
+	ip6, ipnet6 := embedIPv4(b.ipr.FirstIP(), b.ipr.Mask)
+	b.log.Infof("Configuring bridge %s with IPv6 address %v", b.Name, ip6)
+	if err := b.SetLinkIp(ip6, ipnet6); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("error configuring IPv6 address of bridge: %v", err)
+	}
+	b.ip6 = &ip6
+	b.ip6net = ipnet6
+	return nil
+}
+
 func (b *OzBridge) reconfigure(ipr *IPRange) error {
 	if err := b.SetLinkIp(ipr.FirstIP(), ipr.IPNet); err != nil {
 
 	}
 	b.ipr = ipr
+	if b.ip6 != nil {
+		// The IPv6 addresses embed the IPv4 ones, they move to the new range
+		if err := b.UnsetLinkIp(*b.ip6, b.ip6net); err != nil {
+			b.log.Warningf("Unable to remove IPv6 address %v of bridge %s: %v", *b.ip6, b.Name, err)
+		}
+		b.ip6 = nil
+	}
 	for _, veth := range b.veths {
 		if err := veth.AssignIP(); err != nil {
 			return err
 		}
+		if veth.sbip6 != nil {
+			if err := veth.SetupIPv6(); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -107,6 +148,46 @@ func createVethPair() (tenus.Vether, error) {
 	return veth, nil
 }
 
+// ParseMacAddr parses the hardware address given to a sandbox veth, it must
+// be a unicast ethernet address.
+func ParseMacAddr(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address (%s)", s)
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("MAC address (%s) is not an ethernet address", s)
+	}
+	if mac[0]&0x01 != 0 {
+		return nil, fmt.Errorf("MAC address (%s) is a multicast address", s)
+	}
+	return mac, nil
+}
+
+// setPeerMacAddr sets the hardware address of the peer veth, it must be
+// called before the peer is moved into the network namespace of the sandbox.
+func setPeerMacAddr(veth tenus.Vether, s string) error {
+	mac, err := ParseMacAddr(s)
+	if err != nil {
+		return err
+	}
+	peer, err := tenus.NewLinkFrom(veth.PeerNetInterface().Name)
+	if err != nil {
+		return err
+	}
+	return peer.SetLinkMacAddress(mac.String())
+}
+
+// SetPeerMacAddr gives the sandbox veth a fixed hardware address instead of
+// a random one, it must be called before Setup.
+func (v *OzVeth) SetPeerMacAddr(s string) error {
+	if err := setPeerMacAddr(v.Vether, s); err != nil {
+		return fmt.Errorf("failed to set MAC address of peer veth %s: %v", v.PeerNetInterface().Name, err)
+	}
+	v.log.Infof("Set MAC address %s on sandbox veth %s", s, v.PeerNetInterface().Name)
+	return nil
+}
+
 func (v *OzVeth) Setup() error {
 	if err := v.bridge.AddSlaveIfc(v.NetInterface()); err != nil {
 		return fmt.Errorf("failed to add veth %s to bridge: %v", v.NetInterface().Name, err)
@@ -157,6 +238,33 @@ func (v *OzVeth) SetIP(ip net.IP) error {
 	//	return v.SetPeerLinkNetInNs(v.peerPid, ip, ipnet.IPNet, gw)
 }
 
+// SetupIPv6 gives the sandbox veth the IPv6 address embedding its IPv4
+// address, reaching the bridge and the other sandboxes on it only: there is
+// no default IPv6 route. ErrNoIPv6 is returned without changing anything when
+// the host lacks IPv6.
+func (v *OzVeth) SetupIPv6() error {
+	if !ipv6Available() {
+		return ErrNoIPv6
+	}
+	if v.sbip == nil {
+		return errors.New("no IPv4 address assigned to sandbox veth")
+	}
+	if err := v.bridge.configureIPv6(); err != nil {
+		return err
+	}
+	ip6, ipnet6 := embedIPv4(v.sbip, v.bridge.ipr.Mask)
+	v.log.Infof("Assigning IPv6 address %v to sandbox veth %s", ip6, v.PeerNetInterface().Name)
+	if err := v.SetPeerLinkNetInNs(v.peerPid, ip6, ipnet6, nil); err != nil {
+		return err
+	}
+	v.sbip6 = ip6
+	return nil
+}
+
+func (v *OzVeth) GetSandboxIPv6() net.IP {
+	return v.sbip6
+}
+
 func (v *OzVeth) GetSandboxIP() net.IP {
 	return v.sbip
 }
@@ -297,3 +405,25 @@ func (v *OzVeth) RemoveFWRules() error {
 	fmt.Println("Done.")
 	return nil
 }
+
+// embedIPv4 returns the address of ip in the IPv6 prefix and the network
+// matching mask, so the IPv6 subnets of the bridges do not overlap either.
+func embedIPv4(ip net.IP, mask net.IPMask) (net.IP, *net.IPNet) {
+	ip6 := make(net.IP, net.IPv6len)
+	copy(ip6, ipv6Prefix)
+	copy(ip6[12:], ip.To4())
+	ones, _ := mask.Size()
+	m := net.CIDRMask(96+ones, 128)
+	return ip6, &net.IPNet{IP: ip6.Mask(m), Mask: m}
+}
+
+func ipv6Available() bool {
+	disabled, err := ioutil.ReadFile("/proc/sys/net/ipv6/conf/all/disable_ipv6")
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat("/proc/net/if_inet6"); err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(disabled)) == "0"
+}
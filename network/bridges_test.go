@@ -0,0 +1,36 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEmbedIPv4(t *testing.T) {
+	_, ipnet, _ := net.ParseCIDR("10.1.2.0/24")
+	ip6, ipnet6 := embedIPv4(net.ParseIP("10.1.2.3"), ipnet.Mask)
+	if ip6.String() != "fd6f:7a00::a01:203" {
+		t.Errorf("expecting the IPv4 address embedded in the prefix, got %v", ip6)
+	}
+	if ipnet6.String() != "fd6f:7a00::a01:200/120" {
+		t.Errorf("expecting the IPv6 network to match the IPv4 mask, got %v", ipnet6)
+	}
+	_, other := embedIPv4(net.ParseIP("10.1.3.1"), ipnet.Mask)
+	if other.Contains(ip6) {
+		t.Errorf("expecting the IPv6 networks of distinct IPv4 subnets not to overlap")
+	}
+}
+
+func TestParseMacAddr(t *testing.T) {
+	mac, err := ParseMacAddr("02:00:00:00:00:2A")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mac.String() != "02:00:00:00:00:2a" {
+		t.Errorf("unexpected MAC address, got %v", mac)
+	}
+	for _, s := range []string{"", "02:00:00:00:2a", "02-00-00-00-00-zz", "01:00:5e:00:00:01", "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"} {
+		if _, err := ParseMacAddr(s); err == nil {
+			t.Errorf("expecting %q to be rejected", s)
+		}
+	}
+}
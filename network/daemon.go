@@ -183,6 +183,12 @@ func NetInit(stn *SandboxNetwork, htn *HostNetwork, log *logging.Logger) error {
 		return fmt.Errorf("Unable to create veth pair %s, %s.", stn.VethHost, err)
 	}
 
+	if stn.MacAddr != "" {
+		if err := setPeerMacAddr(veth, stn.MacAddr); err != nil {
+			return fmt.Errorf("Unable to set MAC address of veth pair %s, %s.", stn.VethGuest, err)
+		}
+	}
+
 	// Fetch the newly created hostside veth
 	vethIf, err := net.InterfaceByName(stn.VethHost)
 	if err != nil {
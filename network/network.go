@@ -75,6 +75,8 @@ type SandboxNetwork struct {
 	Veth tenus.Vether
 	//
 	IpByte uint
+	// Hardware address of the guest veth, random when empty
+	MacAddr string
 }
 
 var privateNetworkRanges []string
@@ -103,7 +105,7 @@ func NetPrint(log *logging.Logger) {
 	strLine := ""
 	ifs, _ := net.Interfaces()
 
-	strHeader := fmt.Sprintf("%-15.15s%-30.30s%-16.16s%-6.6s", "Interface", "IP", "Mask", "Status")
+	strHeader := fmt.Sprintf("%-15.15s%-45.45s%-16.16s%-6.6s", "Interface", "IP", "Mask", "Status")
 	strHr := ""
 	ii := len(strHeader)
 	for i := 0; i < ii; i++ {
@@ -123,17 +125,9 @@ func NetPrint(log *logging.Logger) {
 		strLine = fmt.Sprintf("%-15.14s", netif.Name)
 
 		if len(addrs) > 0 {
-			strLine += fmt.Sprintf("%-30.30s", addrs[0])
-
-			bIP, brIP, _ := net.ParseCIDR(addrs[0].String())
-			if bIP.To4() != nil {
-				bMask := []byte(brIP.Mask)
-				strLine += fmt.Sprintf("%-16.16s", net.IPv4(bMask[0], bMask[1], bMask[2], bMask[3]).String())
-			} else {
-				strLine += fmt.Sprintf("%-16.16s", "")
-			}
+			strLine += fmt.Sprintf("%-45.45s%-16.16s", addrs[0], addrMask(addrs[0]))
 		} else {
-			strLine += fmt.Sprintf("%-30.30s%-16.16s", "", "")
+			strLine += fmt.Sprintf("%-45.45s%-16.16s", "", "")
 		}
 
 		if netif.Flags&net.FlagUp == 1 {
@@ -146,16 +140,7 @@ func NetPrint(log *logging.Logger) {
 			strLine += fmt.Sprintf("")
 
 			for _, addr := range addrs[1:] {
-				strLine += fmt.Sprintf("%-15.15s%-30.30s", "", addr)
-
-				bIP, brIP, _ := net.ParseCIDR(addr.String())
-
-				if bIP.To4() != nil {
-					bMask := []byte(brIP.Mask)
-					strLine += fmt.Sprintf("%-20.20s", net.IPv4(bMask[0], bMask[1], bMask[2], bMask[3]).String())
-				} else {
-					strLine += fmt.Sprintf("%-16.16s", "")
-				}
+				strLine += fmt.Sprintf("\n%-15.15s%-45.45s%-16.16s", "", addr, addrMask(addr))
 			}
 		}
 
@@ -168,6 +153,20 @@ func NetPrint(log *logging.Logger) {
 
 }
 
+// Netmask of an IPv4 address, or prefix length of an IPv6 address
+func addrMask(addr net.Addr) string {
+	bIP, brIP, err := net.ParseCIDR(addr.String())
+	if err != nil {
+		return ""
+	}
+	if bIP.To4() != nil {
+		bMask := []byte(brIP.Mask)
+		return net.IPv4(bMask[0], bMask[1], bMask[2], bMask[3]).String()
+	}
+	ones, _ := brIP.Mask.Size()
+	return "/" + strconv.Itoa(ones)
+}
+
 // Convert longip to net.IP
 func inet_ntoa(ipnr uint64) net.IP {
 	var bytes [4]byte
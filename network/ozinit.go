@@ -2,9 +2,13 @@ package network
 
 import (
 	//Builtin
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 
 	// Internal
 	//"github.com/op/go-logging"
@@ -43,3 +47,46 @@ func setupLoopback() error {
 
 	return nil
 }
+
+// Path of the nftables tool used to restrict the egress ports
+const nftPath = "/usr/sbin/nft"
+
+// egressRuleset returns the nftables rules dropping the tcp and udp traffic
+// leaving the sandbox to any port but the given ones
+func egressRuleset(ports []int) string {
+	ps := make([]string, len(ports))
+	for i, p := range ports {
+		ps[i] = strconv.Itoa(p)
+	}
+	return fmt.Sprintf(`table inet oz-egress {
+	chain output {
+		type filter hook output priority 0; policy accept;
+		oif "lo" accept
+		ct state established,related accept
+		meta l4proto { tcp, udp } th dport { %s } accept
+		meta l4proto { tcp, udp } drop
+	}
+}
+`, strings.Join(ps, ", "))
+}
+
+// Restrict the egress traffic of the sandbox to the given ports
+// and return the applied rule set. The rules are loaded in the
+// network namespace of the child so they never apply to the host.
+func NetRestrictPorts(ports []int) (string, error) {
+	if os.Getpid() != 1 {
+		return "", errors.New("Cannot use NetRestrictPorts from parent.")
+	}
+
+	rules := egressRuleset(ports)
+	cmd := exec.Command(nftPath, "-f", "-")
+	cmd.Stdin = strings.NewReader(rules)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Unable to load egress rules, %v: %s", err, strings.TrimSpace(out.String()))
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,23 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEgressRuleset(t *testing.T) {
+	rules := egressRuleset([]int{53, 443})
+	for _, expected := range []string{
+		"type filter hook output priority 0; policy accept;",
+		`oif "lo" accept`,
+		"meta l4proto { tcp, udp } th dport { 53, 443 } accept",
+		"meta l4proto { tcp, udp } drop",
+	} {
+		if !strings.Contains(rules, expected) {
+			t.Errorf("expecting %q in the rule set:\n%s", expected, rules)
+		}
+	}
+	if strings.Index(rules, "accept\n\t\tmeta l4proto { tcp, udp } drop") < 0 {
+		t.Errorf("expecting the drop rule after the allowed ports:\n%s", rules)
+	}
+}
@@ -165,25 +165,40 @@ func removeProxyPair(in net.Conn, out net.Conn) bool {
 	return false
 }
 
-func ProxySetup(childPid int, ozSockets []ProxyConfig, log *logging.Logger, ready sync.WaitGroup) error {
+// ProxySetup starts the forwarding of the sockets of the sandbox of childPid
+// and returns the listeners it opened, closing them stops the forwarding.
+func ProxySetup(childPid int, ozSockets []ProxyConfig, log *logging.Logger, ready sync.WaitGroup) ([]net.Listener, error) {
+	listeners := []net.Listener{}
 	for _, socket := range ozSockets {
 		if socket.Nettype == "" {
 			continue
 		}
+		var l net.Listener
+		var err error
 		if socket.Nettype == PROXY_CLIENT {
-			err := newProxyClient(childPid, &socket, log, ready)
+			l, err = newProxyClient(childPid, &socket, log, ready)
 			if err != nil {
-				return fmt.Errorf("%+v, %s", socket, err)
+				return listeners, fmt.Errorf("%+v, %s", socket, err)
 			}
 		} else if socket.Nettype == PROXY_SERVER {
-			err := newProxyServer(childPid, &socket, log, ready)
+			l, err = newProxyServer(childPid, &socket, log, ready)
 			if err != nil {
-				return fmt.Errorf("%+s, %s", socket, err)
+				return listeners, fmt.Errorf("%+s, %s", socket, err)
 			}
 		}
+		if l != nil {
+			listeners = append(listeners, l)
+		}
 	}
 
-	return nil
+	return listeners, nil
+}
+
+// acceptClosed reports whether an Accept error means the listener was closed
+// rather than a temporary failure
+func acceptClosed(err error) bool {
+	ne, ok := err.(net.Error)
+	return !ok || !ne.Temporary()
 }
 
 /**
@@ -217,7 +232,7 @@ func proxyClientConn(conn *net.Conn, proto ProtoType, rAddr string, ready sync.W
 	return nil
 }
 
-func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready sync.WaitGroup) error {
+func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready sync.WaitGroup) (net.Listener, error) {
 	if config.Destination == "" {
 		config.Destination = "127.0.0.1"
 	}
@@ -234,7 +249,7 @@ func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 	} else if strings.HasPrefix(string(config.Proto), "unix") {
 		if !strings.HasPrefix(config.Destination, "@") {
 			log.Warning("Only abstract unix socket are supported!")
-			return nil
+			return nil, nil
 		}
 		lAddr = config.Destination
 		rAddr = config.Destination
@@ -243,7 +258,7 @@ func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 		rAddr = config.Destination
 	} else {
 		log.Warning("Unsupported proxy protocol specified!")
-		return nil
+		return nil, nil
 	}
 
 	var listenProto ProtoType
@@ -256,7 +271,7 @@ func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 	}
 	listen, err := proxySocketListener(pid, listenProto, lAddr)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	wgProxy.Add(1)
@@ -267,6 +282,10 @@ func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 		for {
 			conn, err := listen.Accept()
 			if err != nil {
+				if acceptClosed(err) {
+					log.Info("Stopped socket forwarding: %s://%s.", listenProto, lAddr)
+					return
+				}
 				log.Error("Socket: %+v.", err)
 				//panic(err)
 				continue
@@ -300,7 +319,7 @@ func newProxyClient(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 		}
 	}()
 
-	return nil
+	return listen, nil
 }
 
 func proxySocketListener(pid int, proto ProtoType, lAddr string) (net.Listener, error) {
@@ -358,7 +377,7 @@ func proxyServerConn(pid int, conn *net.Conn, proto ProtoType, rAddr string, log
 	return nil
 }
 
-func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready sync.WaitGroup) error {
+func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready sync.WaitGroup) (net.Listener, error) {
 	if config.Destination == "" {
 		config.Destination = "127.0.0.1"
 	}
@@ -370,7 +389,7 @@ func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 	} else {
 		if !strings.HasPrefix(config.Destination, "@") {
 			log.Warning("Only abstract unix socket are supported!")
-			return nil
+			return nil, nil
 		}
 		lAddr = config.Destination
 		rAddr = config.Destination
@@ -380,7 +399,7 @@ func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 
 	listen, err := net.Listen(string(config.Proto), lAddr)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	wgProxy.Add(1)
@@ -389,6 +408,10 @@ func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 		for {
 			conn, err := listen.Accept()
 			if err != nil {
+				if acceptClosed(err) {
+					log.Info("Stopped socket forwarding: %s://%s.", config.Proto, lAddr)
+					return
+				}
 				log.Error("Socket: %+v.", err)
 				//panic(err)
 				continue
@@ -398,7 +421,7 @@ func newProxyServer(pid int, config *ProxyConfig, log *logging.Logger, ready syn
 		}
 	}()
 
-	return nil
+	return listen, nil
 }
 
 func socketConnect(pid int, proto ProtoType, rAddr string) (net.Conn, error) {
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestProxySetupReturnsListeners(t *testing.T) {
+	log := logging.MustGetLogger("oz-test")
+	sockets := []ProxyConfig{
+		{Nettype: PROXY_SERVER, Proto: PROTO_TCP, Port: 0},
+		{Nettype: PROXY_SERVER, Proto: PROTO_UNIX, Destination: "not-abstract"},
+		{},
+	}
+	listeners, err := ProxySetup(os.Getpid(), sockets, log, sync.WaitGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 {
+		t.Fatalf("expecting one listener, got %d", len(listeners))
+	}
+	addr := listeners[0].Addr().String()
+	listeners[0].Close()
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Error("expecting the closed proxy to stop accepting connections")
+	}
+}
+
+func TestAcceptClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := l.Accept(); !acceptClosed(err) {
+		t.Errorf("expecting the error of a closed listener to stop the accept loop, got %v", err)
+	}
+}
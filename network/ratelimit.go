@@ -0,0 +1,62 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/milosgajdos83/tenus"
+)
+
+// Path of the traffic control tool used to limit the bandwidth of sandboxes
+const tcPath = "/sbin/tc"
+
+// Smallest token bucket, a full frame must fit in it
+const minRateLimitBurst = 1600
+
+// tbfArgs returns the tc arguments shaping the egress of iface to kbps,
+// with a bucket holding 100ms of traffic
+func tbfArgs(iface string, kbps int) []string {
+	burst := kbps * 1000 / 8 / 10
+	if burst < minRateLimitBurst {
+		burst = minRateLimitBurst
+	}
+	return []string{"qdisc", "replace", "dev", iface, "root", "tbf",
+		"rate", strconv.Itoa(kbps) + "kbit", "burst", strconv.Itoa(burst), "latency", "400ms"}
+}
+
+func runTc(args []string) error {
+	var out bytes.Buffer
+	cmd := exec.Command(tcPath, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed, %v: %s", tcPath, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// SetRateLimit limits the bandwidth of the sandbox to kbps in each direction.
+// The egress is shaped on the peer veth, inside the network namespace of the
+// sandbox, and the ingress on the host veth. tc is not available inside the
+// sandbox, it is run from a thread switched to its network namespace.
+func (v *OzVeth) SetRateLimit(kbps int) error {
+	errc := make(chan error, 1)
+	go func() {
+		// Never unlocked, the thread exits along with the goroutine
+		// rather than running others in the namespace of the sandbox
+		runtime.LockOSThread()
+		if err := tenus.SetNetNsToPid(v.peerPid); err != nil {
+			errc <- err
+			return
+		}
+		errc <- runTc(tbfArgs(v.PeerNetInterface().Name, kbps))
+	}()
+	if err := <-errc; err != nil {
+		return err
+	}
+	return runTc(tbfArgs(v.NetInterface().Name, kbps))
+}
@@ -0,0 +1,16 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTbfArgs(t *testing.T) {
+	expected := []string{"qdisc", "replace", "dev", "voz1", "root", "tbf", "rate", "8000kbit", "burst", "100000", "latency", "400ms"}
+	if args := tbfArgs("voz1", 8000); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expecting tc arguments %v, got %v", expected, args)
+	}
+	if args := tbfArgs("voz1", 64); args[9] != "1600" {
+		t.Errorf("expecting the smallest burst for a low rate, got %s", args[9])
+	}
+}
@@ -0,0 +1,125 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/subgraph/oz"
+)
+
+// Leaf cgroup the daemon moves to, processes cannot live in a cgroup with
+// controllers enabled for its children
+const daemonCgroupLeaf = "oz-daemon"
+
+// prepareCgroupBase moves the processes of the daemon cgroup to a leaf so
+// controllers can be enabled for the cgroups of the sandboxes.
+func (d *daemonState) prepareCgroupBase() (string, error) {
+	if d.cgroupBase != "" {
+		return d.cgroupBase, nil
+	}
+	base, err := oz.OwnCgroup()
+	if err != nil {
+		return "", err
+	}
+	if path.Base(base) == daemonCgroupLeaf {
+		base = path.Dir(base)
+	}
+	leaf := path.Join(base, daemonCgroupLeaf)
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return "", err
+	}
+	procs, err := ioutil.ReadFile(path.Join(base, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	for _, pid := range strings.Fields(string(procs)) {
+		if err := oz.WriteCgroupFile(leaf, "cgroup.procs", pid); err != nil {
+			return "", err
+		}
+	}
+	d.cgroupBase = base
+	return base, nil
+}
+
+func enableCgroupController(base, controller string) error {
+	if err := oz.WriteCgroupFile(base, "cgroup.subtree_control", "+"+controller); err != nil {
+		return fmt.Errorf("%s controller unavailable, it must be delegated to the oz-daemon service: %v", controller, err)
+	}
+	return nil
+}
+
+// setupCgroup moves oz-init to a cgroup of its own, before it starts any
+// process. The cgroup is restricted to the cpus of the profile, and oz-init
+// limits the memory and cpu of the applications in a child cgroup.
+func (sbox *Sandbox) setupCgroup() error {
+	cpus := sbox.profile.Cgroup.Cpus
+	limits := sbox.profile.Limits
+	if cpus == "" && limits.MemoryMB == 0 && limits.CPUPercent == 0 {
+		return nil
+	}
+	if cpus != "" {
+		if err := oz.CheckCpusOnline(cpus); err != nil {
+			return err
+		}
+	}
+	base, err := sbox.daemon.prepareCgroupBase()
+	if err != nil {
+		return err
+	}
+	if cpus != "" {
+		if err := enableCgroupController(base, "cpuset"); err != nil {
+			return err
+		}
+	}
+	if limits.MemoryMB != 0 {
+		if err := enableCgroupController(base, "memory"); err != nil {
+			return err
+		}
+	}
+	if limits.CPUPercent != 0 {
+		if err := enableCgroupController(base, "cpu"); err != nil {
+			return err
+		}
+	}
+	cg := path.Join(base, fmt.Sprintf("sandbox-%d", sbox.id))
+	if err := os.Mkdir(cg, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if cpus != "" {
+		if err := oz.WriteCgroupFile(cg, "cpuset.cpus", cpus); err != nil {
+			os.Remove(cg)
+			return err
+		}
+	}
+	if err := oz.WriteCgroupFile(cg, "cgroup.procs", strconv.Itoa(sbox.init.Process.Pid)); err != nil {
+		os.Remove(cg)
+		return err
+	}
+	sbox.cgroup = cg
+	if cpus != "" {
+		sbox.daemon.log.Info("Sandbox (%s) pinned to cpus %s", sbox.profile.Name, cpus)
+	}
+	return nil
+}
+
+func (sbox *Sandbox) removeCgroup() {
+	if sbox.cgroup == "" {
+		return
+	}
+	// Child cgroups created by oz-init are left behind once it exited
+	if entries, err := ioutil.ReadDir(sbox.cgroup); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				os.Remove(path.Join(sbox.cgroup, e.Name()))
+			}
+		}
+	}
+	if err := os.Remove(sbox.cgroup); err != nil {
+		sbox.daemon.Warning("Unable to remove cgroup %s: %v", sbox.cgroup, err)
+	}
+	sbox.cgroup = ""
+}
@@ -228,6 +228,51 @@ func RelaunchAllXpraClient() error {
 	return RelaunchXpraClient(-1)
 }
 
+func SetGroups(id int, groups []string) error {
+	resp, err := clientSend(&SetGroupsMsg{Id: id, Groups: groups})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	case *OkMsg:
+		return nil
+	default:
+		return fmt.Errorf("Unexpected message received %+v", body)
+	}
+}
+
+func GetSeccompDenials(id int) ([]SeccompDenial, error) {
+	resp, err := clientSend(&GetSeccompDenialsMsg{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	case *SeccompDenialsResp:
+		return body.Denials, nil
+	default:
+		return nil, fmt.Errorf("Unexpected message received %+v", body)
+	}
+}
+
+func ReloadSeccomp(id int) error {
+	resp, err := clientSend(&ReloadSeccompMsg{Id: id})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	case *OkMsg:
+		return nil
+	default:
+		return fmt.Errorf("Unexpected message received %+v", body)
+	}
+}
+
 func MountFiles(id int, files []string, readOnly bool) error {
 	mountFilesMsg := MountFilesMsg{
 		Id:       id,
@@ -16,6 +16,7 @@ import (
 	"github.com/subgraph/oz"
 	"github.com/subgraph/oz/ipc"
 	"github.com/subgraph/oz/network"
+	"github.com/subgraph/oz/oz-init"
 
 	"github.com/op/go-logging"
 )
@@ -41,6 +42,10 @@ type daemonState struct {
 	// openvpns     *network.OpenVPNs
 	systemGroups map[string]groupEntry
 	envOverrides []string
+	// Cgroup holding the cgroups of the sandboxes, set once prepared
+	cgroupBase string
+	// Set when watch_profile is enabled
+	profileWatch *profileWatcher
 }
 
 func Main() {
@@ -60,6 +65,9 @@ func Main() {
 		d.handleListSandboxes,
 		d.handleKillSandbox,
 		d.handleRelaunchXpraClient,
+		d.handleReloadSeccomp,
+		d.handleGetSeccompDenials,
+		d.handleSetGroups,
 		d.handleMountFiles,
 		d.handleUnmountFile,
 		d.handleLogs,
@@ -71,6 +79,9 @@ func Main() {
 	if err != nil {
 		d.log.Error("Error running server: %v", err)
 	}
+	if d.profileWatch != nil {
+		d.profileWatch.stop()
+	}
 }
 
 func initialize() *daemonState {
@@ -100,6 +111,21 @@ func initialize() *daemonState {
 
 	d.bridges = network.NewBridges(d.log)
 
+	if d.config.LogSeccompDenials {
+		if err := d.watchSeccompDenials(); err != nil {
+			d.log.Warning("Seccomp denials will not be logged: %v", err)
+		}
+	}
+
+	if d.config.WatchProfile {
+		w, err := d.watchProfiles()
+		if err != nil {
+			d.log.Warning("Profile changes will not be applied: %v", err)
+		} else {
+			d.profileWatch = w
+		}
+	}
+
 	sockets := path.Join(config.SandboxPath, "sockets")
 	if err := os.MkdirAll(sockets, 0755); err != nil {
 		d.log.Fatalf("Failed to create sockets directory: %v", err)
@@ -348,7 +374,7 @@ func (d *daemonState) handleLaunch(msg *LaunchMsg, m *ipc.Message) error {
 			return m.Respond(&ErrorMsg{errmsg})
 		} else {
 			d.Info("Found running sandbox for `%s`, running program there", p.Name)
-			sbox.launchProgram(d.config.PrefixPath, msg.Path, msg.Pwd, msg.Args, d.log)
+			sbox.launchProgram(d.config.PrefixPath, msg.Path, msg.Pwd, getEnvValue(msg.Env, "TERM"), msg.Args, d.log)
 		}
 	} else {
 		d.Debug("Would launch %s (ephemeral: %b)", p.Name, msg.Ephemeral)
@@ -363,6 +389,15 @@ func (d *daemonState) handleLaunch(msg *LaunchMsg, m *ipc.Message) error {
 	return m.Respond(&OkMsg{})
 }
 
+func getEnvValue(env []string, name string) string {
+	for _, e := range env {
+		if strings.HasPrefix(e, name+"=") {
+			return e[len(name)+1:]
+		}
+	}
+	return ""
+}
+
 func (d *daemonState) sanitizeEnvironment(p *oz.Profile, oldEnv []string) []string {
 	newEnv := []string{}
 
@@ -371,6 +406,10 @@ func (d *daemonState) sanitizeEnvironment(p *oz.Profile, oldEnv []string) []stri
 			newEnv = append(newEnv, EnvItem)
 			continue
 		}
+		if !p.EnvWhitelisted(EnvItem) {
+			d.log.Debug("Stripping %s from the launch environment, not in env_whitelist", EnvItem)
+			continue
+		}
 		for _, OldItem := range oldEnv {
 			if strings.HasPrefix(OldItem, EnvItem+"=") {
 				newEnv = append(newEnv, EnvItem+"="+strings.Replace(OldItem, EnvItem+"=", "", 1))
@@ -466,6 +505,78 @@ func (d *daemonState) handleRelaunchXpraClient(msg *RelaunchXpraClientMsg, m *ip
 	return m.Respond(&OkMsg{})
 }
 
+// handleReloadSeccomp re-reads the profile of a sandbox from disk and sends
+// its seccomp configuration to oz-init, it only applies to later launches.
+func (d *daemonState) handleReloadSeccomp(msg *ReloadSeccompMsg, m *ipc.Message) error {
+	sbox := d.sandboxById(msg.Id)
+	if sbox == nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("no sandbox found with id = %d", msg.Id)})
+	}
+	ps, err := d.loadProfiles(d.config.ProfileDir)
+	if err != nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("Unable to reload profiles: %v", err)})
+	}
+	var p *oz.Profile
+	for _, pp := range ps {
+		if pp.Name == sbox.profile.Name {
+			p = pp
+			break
+		}
+	}
+	if p == nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("no profile found with name = %s", sbox.profile.Name)})
+	}
+	if err := ozinit.ReloadSeccomp(sbox.addr, p.Seccomp); err != nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("Unable to reload seccomp policy: %v", err)})
+	}
+	sbox.profile.Seccomp = p.Seccomp
+	d.log.Info("Seccomp policy reloaded for sandbox %d (%s)", sbox.id, sbox.profile.Name)
+	return m.Respond(&OkMsg{})
+}
+
+// handleSetGroups re-reads the system groups and gives the allowed groups the
+// sandbox user is now a member of to the programs launched afterwards.
+func (d *daemonState) handleSetGroups(msg *SetGroupsMsg, m *ipc.Message) error {
+	sbox := d.sandboxById(msg.Id)
+	if sbox == nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("no sandbox found with id = %d", msg.Id)})
+	}
+	if err := d.cacheSystemGroups(); err != nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("Unable to read system groups: %v", err)})
+	}
+	allowed, err := d.sanitizeGroups(sbox.profile, sbox.user.Username, nil)
+	if err != nil {
+		return m.Respond(&ErrorMsg{err.Error()})
+	}
+	groups := allowed
+	if len(msg.Groups) > 0 {
+		groups = map[string]uint32{}
+		for _, name := range msg.Groups {
+			gid, ok := allowed[name]
+			if !ok {
+				return m.Respond(&ErrorMsg{fmt.Sprintf("group %s is not allowed or %s is not a member", name, sbox.user.Username)})
+			}
+			groups[name] = gid
+		}
+	}
+	if err := ozinit.SetGroups(sbox.addr, groups); err != nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("Unable to set groups: %v", err)})
+	}
+	d.log.Info("Groups of new processes changed for sandbox %d (%s)", sbox.id, sbox.profile.Name)
+	return m.Respond(&OkMsg{})
+}
+
+func (d *daemonState) handleGetSeccompDenials(msg *GetSeccompDenialsMsg, m *ipc.Message) error {
+	if !d.config.LogSeccompDenials {
+		return m.Respond(&ErrorMsg{"Logging of seccomp denials is not enabled in oz config"})
+	}
+	sbox := d.sandboxById(msg.Id)
+	if sbox == nil {
+		return m.Respond(&ErrorMsg{fmt.Sprintf("no sandbox found with id = %d", msg.Id)})
+	}
+	return m.Respond(&SeccompDenialsResp{Denials: sbox.denials.list()})
+}
+
 func (d *daemonState) handleMountFiles(msg *MountFilesMsg, m *ipc.Message) error {
 	sbox := d.sandboxById(msg.Id)
 	if sbox == nil {
@@ -572,7 +683,7 @@ func (d *daemonState) getRunningSandboxByName(name string) *Sandbox {
 func (d *daemonState) handleListSandboxes(list *ListSandboxesMsg, msg *ipc.Message) error {
 	r := new(ListSandboxesResp)
 	for _, sb := range d.sandboxes {
-		r.Sandboxes = append(r.Sandboxes, SandboxInfo{Id: sb.id, Address: sb.addr, Mounts: sb.mountedFiles, Profile: sb.profile.Name, InitPid: sb.init.Process.Pid})
+		r.Sandboxes = append(r.Sandboxes, SandboxInfo{Id: sb.id, Address: sb.addr, Mounts: sb.mountedFiles, Profile: sb.profile.Name, InitPid: sb.init.Process.Pid, Ready: sb.isReady(), NotReady: sb.notReady()})
 	}
 	return msg.Respond(r)
 }
@@ -42,6 +42,7 @@ type Sandbox struct {
 	stderr       io.ReadCloser
 	addr         string
 	xpra         *xpra.Xpra
+	xpraDisplays []int
 	ready        sync.WaitGroup
 	waiting      sync.WaitGroup
 	iface        *network.OzVeth
@@ -50,6 +51,13 @@ type Sandbox struct {
 	forwarders   []ActiveForwarder
 	ovpn         *OpenVPN
 	ephemeral    bool
+	readyLock    sync.Mutex
+	appReady     bool
+	readyFailed  bool
+	cgroup       string
+	denials      seccompDenials
+	proxyLock    sync.Mutex
+	listeners    []net.Listener
 }
 
 type OpenVPN struct {
@@ -141,7 +149,8 @@ func (d *daemonState) launch(p *oz.Profile, msg *LaunchMsg, rawEnv []string, uid
 	display := 0
 	if p.XServer.Enabled && p.Networking.Nettype == network.TYPE_HOST {
 		display = d.nextDisplay
-		d.nextDisplay += 1
+		// The extra displays of the profile are numbered after the main one
+		d.nextDisplay += 1 + p.XServer.ExtraDisplays
 	}
 
 	socketPath, err := createSocketPath(path.Join(d.config.SandboxPath, "sockets"), "oz-init-control")
@@ -164,6 +173,7 @@ func (d *daemonState) launch(p *oz.Profile, msg *LaunchMsg, rawEnv []string, uid
 
 	jdata, err := json.Marshal(ozinit.InitData{
 		Display:   display,
+		Reserved:  d.reservedDisplays(),
 		User:      *u,
 		Uid:       uid,
 		Gid:       gid,
@@ -207,6 +217,11 @@ func (d *daemonState) launch(p *oz.Profile, msg *LaunchMsg, rawEnv []string, uid
 
 	sbox.waiting.Wait()
 
+	if err := sbox.setupCgroup(); err != nil {
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("Unable to setup cgroup of sandbox: %v", err)
+	}
+
         //pname := fmt.Sprintf("%s (%d)", sbox.profile.Name, sbox.id)
         log.Noticef("Registering %s (%d) init pid %d with fw-daemon", sbox.profile.Name, sbox.id, sbox.init.Process.Pid)
         err = registerSandboxPid(sbox.init.Process.Pid, sbox.profile.Name, sbox.id)
@@ -266,7 +281,8 @@ func (d *daemonState) launch(p *oz.Profile, msg *LaunchMsg, rawEnv []string, uid
 		go func() {
 			defer wgNet.Done()
 			sbox.ready.Wait()
-			err := network.ProxySetup(sbox.init.Process.Pid, p.Networking.Sockets, d.log, sbox.ready)
+			listeners, err := network.ProxySetup(sbox.init.Process.Pid, p.Networking.Sockets, d.log, sbox.ready)
+			sbox.addListeners(listeners)
 			if err != nil {
 				log.Warning("Unable to create connection proxy: %+s", err)
 			}
@@ -276,7 +292,7 @@ func (d *daemonState) launch(p *oz.Profile, msg *LaunchMsg, rawEnv []string, uid
 		go func() {
 			sbox.ready.Wait()
 			wgNet.Wait()
-			go sbox.launchProgram(d.config.PrefixPath, msg.Path, msg.Pwd, msg.Args, log)
+			go sbox.launchProgram(d.config.PrefixPath, msg.Path, msg.Pwd, getEnvValue(rawEnv, "TERM"), msg.Args, log)
 		}()
 	}
 
@@ -353,10 +369,31 @@ func (sbox *Sandbox) configureBridgedIface() error {
 	if err != nil {
 		return err
 	}
+	if mac := sbox.profile.Networking.MacAddr; mac != "" {
+		if err := veth.SetPeerMacAddr(mac); err != nil {
+			veth.Delete()
+			return err
+		}
+	}
 	if err := veth.Setup(); err != nil {
 		veth.Delete()
 		return err
 	}
+	if sbox.profile.Networking.LocalIPv6 {
+		// The sandbox keeps working over IPv4 when IPv6 cannot be set up
+		if err := veth.SetupIPv6(); err == network.ErrNoIPv6 {
+			sbox.daemon.log.Infof("IPv6 not configured for %s (id=%d): %v", sbox.profile.Name, sbox.id, err)
+		} else if err != nil {
+			sbox.daemon.log.Warningf("Unable to configure IPv6 for %s (id=%d): %v", sbox.profile.Name, sbox.id, err)
+		}
+	}
+	if kbps := sbox.profile.Networking.RateLimitKbps; kbps > 0 {
+		if err := veth.SetRateLimit(kbps); err != nil {
+			veth.Delete()
+			return fmt.Errorf("unable to limit the bandwidth: %v", err)
+		}
+		sbox.daemon.log.Infof("Bandwidth of %s (id=%d) limited to %d kbit/s in each direction", sbox.profile.Name, sbox.id, kbps)
+	}
 	sbox.iface = veth
 	return nil
 }
@@ -368,11 +405,11 @@ func (sbox *Sandbox) getBridgeName() string {
 	return "default"
 }
 
-func (sbox *Sandbox) launchProgram(binpath, cpath, pwd string, args []string, log *logging.Logger) {
+func (sbox *Sandbox) launchProgram(binpath, cpath, pwd, term string, args []string, log *logging.Logger) {
 	if sbox.profile.AllowFiles {
 		sbox.whitelistArgumentFiles(binpath, pwd, args, log)
 	}
-	err := ozinit.RunProgram(sbox.addr, cpath, pwd, args)
+	err := ozinit.RunProgram(sbox.addr, cpath, pwd, term, args, 0)
 	if err != nil {
 		log.Error("run program command failed: %v", err)
 		pid := sbox.init.Process.Pid
@@ -547,6 +584,23 @@ func (sbox *Sandbox) whitelistArgumentFiles(binpath, pwd string, args []string,
 	}
 }
 
+// addListeners records the proxy listeners of the sandbox, they are closed
+// when it is removed
+func (sbox *Sandbox) addListeners(listeners []net.Listener) {
+	sbox.proxyLock.Lock()
+	defer sbox.proxyLock.Unlock()
+	sbox.listeners = append(sbox.listeners, listeners...)
+}
+
+func (sbox *Sandbox) closeListeners() {
+	sbox.proxyLock.Lock()
+	defer sbox.proxyLock.Unlock()
+	for _, l := range sbox.listeners {
+		l.Close()
+	}
+	sbox.listeners = nil
+}
+
 func (sbox *Sandbox) remove(log *logging.Logger) {
 	sboxes := []*Sandbox{}
 	for _, sb := range sbox.daemon.sandboxes {
@@ -561,8 +615,10 @@ func (sbox *Sandbox) remove(log *logging.Logger) {
 				sb.iface.Delete()
 				sb.iface = nil
 			}
+			sb.closeListeners()
 			//		sb.fs.Cleanup()
 			os.Remove(sb.addr)
+			sb.removeCgroup()
 		} else {
 			sboxes = append(sboxes, sb)
 		}
@@ -584,6 +640,12 @@ func (sbox *Sandbox) logMessages() {
 			sbox.daemon.log.Info("oz-init (%s) is ready", sbox.profile.Name)
 			seenOk = true
 			sbox.ready.Done()
+		} else if line == "READY" {
+			sbox.daemon.log.Info("application in sandbox (%s) is ready", sbox.profile.Name)
+			sbox.setReady(true)
+		} else if line == "NOTREADY" {
+			sbox.daemon.log.Warning("application in sandbox (%s) did not become ready in time", sbox.profile.Name)
+			sbox.setReady(false)
 		} else if len(line) > 1 {
 			sbox.logLine(line)
 		}
@@ -591,10 +653,49 @@ func (sbox *Sandbox) logMessages() {
 	sbox.stderr.Close()
 }
 
+// A sandbox without a ready pattern is ready as soon as it is launched,
+// otherwise the application must signal it through its output.
+func (sbox *Sandbox) isReady() bool {
+	sbox.readyLock.Lock()
+	defer sbox.readyLock.Unlock()
+	return sbox.profile.ReadyPattern == "" || sbox.appReady
+}
+
+// notReady reports whether the application did not match its ready
+// pattern within the ready timeout and has not matched it since.
+func (sbox *Sandbox) notReady() bool {
+	sbox.readyLock.Lock()
+	defer sbox.readyLock.Unlock()
+	return sbox.readyFailed && !sbox.appReady
+}
+
+func (sbox *Sandbox) setReady(ready bool) {
+	sbox.readyLock.Lock()
+	defer sbox.readyLock.Unlock()
+	if ready {
+		sbox.appReady = true
+	} else {
+		sbox.readyFailed = true
+	}
+}
+
 func (sbox *Sandbox) logLine(line string) {
 	if len(line) < 2 {
 		return
 	}
+	// Written by oz-init with log_format json
+	if strings.HasPrefix(line, "{") {
+		var rec struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err == nil && rec.Level != "" {
+			if f := sbox.getLogFunc(rec.Level[0]); f != nil {
+				f("[%s] %s", sbox.profile.Name, rec.Msg)
+				return
+			}
+		}
+	}
 	f := sbox.getLogFunc(line[0])
 	msg := line[2:]
 	if f != nil {
@@ -629,34 +730,74 @@ func (sbox *Sandbox) startXpraClient() {
 		sbox.daemon.Error("Failed to lookup user for uid=%d, cannot start xpra", sbox.cred.Uid)
 		return
 	}
+	// oz-init may have moved to another display if the allocated one was in use
+	extra := []int{}
+	if info, err := ozinit.GetInfo(sbox.addr); err != nil {
+		sbox.daemon.Warning("Failed to retrieve display from oz-init, using :%d: %v", sbox.display, err)
+	} else {
+		if info.Display != sbox.display {
+			sbox.daemon.Notice("Sandbox (%s) moved from display :%d to :%d", sbox.profile.Name, sbox.display, info.Display)
+			sbox.display = info.Display
+		}
+		extra = info.ExtraDisplays
+		sbox.xpraDisplays = extra
+	}
 	xpraPath := path.Join(u.HomeDir, ".Xoz", sbox.profile.Name)
-	sbox.xpra = xpra.NewClient(
+	sbox.xpra = sbox.newXpraClient(sbox.display, xpraPath)
+	for _, display := range extra {
+		sbox.newXpraClient(display, xpraPath)
+	}
+}
+
+// reservedDisplays returns the displays of the running sandboxes, their
+// extra displays are those allocated at launch until oz-init reports them.
+func (d *daemonState) reservedDisplays() []int {
+	displays := []int{}
+	for _, sb := range d.sandboxes {
+		if sb.display == 0 {
+			continue
+		}
+		displays = append(displays, sb.display)
+		if sb.xpraDisplays != nil {
+			displays = append(displays, sb.xpraDisplays...)
+			continue
+		}
+		for i := 1; i <= sb.profile.XServer.ExtraDisplays; i++ {
+			displays = append(displays, sb.display+i)
+		}
+	}
+	return displays
+}
+
+func (sbox *Sandbox) newXpraClient(display int, xpraPath string) *xpra.Xpra {
+	x := xpra.NewClient(
 		&sbox.profile.XServer,
-		uint64(sbox.display),
+		uint64(display),
 		sbox.cred,
 		path.Join(sbox.daemon.config.PrefixPath, "bin", "oz-seccomp"),
 		xpraPath,
 		sbox.profile.Name,
 		sbox.daemon.log)
 
-	sbox.xpra.Process.Env = append(sbox.rawEnv, sbox.xpra.Process.Env...)
+	x.Process.Env = append(sbox.rawEnv, x.Process.Env...)
 
-	//sbox.daemon.log.Debug("%s %s", strings.Join(sbox.xpra.Process.Env, " "), strings.Join(sbox.xpra.Process.Args, " "))
+	//sbox.daemon.log.Debug("%s %s", strings.Join(x.Process.Env, " "), strings.Join(x.Process.Args, " "))
 	if sbox.daemon.config.LogXpra {
-		sbox.setupXpraLogging()
+		sbox.setupXpraLogging(x)
 	}
-	if err := sbox.xpra.Process.Start(); err != nil {
-		sbox.daemon.Warning("Failed to start xpra client: %v", err)
+	if err := x.Process.Start(); err != nil {
+		sbox.daemon.Warning("Failed to start xpra client on display :%d: %v", display, err)
 	}
+	return x
 }
 
-func (sbox *Sandbox) setupXpraLogging() {
-	stdout, err := sbox.xpra.Process.StdoutPipe()
+func (sbox *Sandbox) setupXpraLogging(x *xpra.Xpra) {
+	stdout, err := x.Process.StdoutPipe()
 	if err != nil {
 		sbox.daemon.Warning("Failed to create xpra stdout pipe: %v", err)
 		return
 	}
-	stderr, err := sbox.xpra.Process.StderrPipe()
+	stderr, err := x.Process.StderrPipe()
 	if err != nil {
 		stdout.Close()
 		sbox.daemon.Warning("Failed to create xpra stderr pipe: %v", err)
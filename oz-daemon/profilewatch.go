@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/oz-init"
+)
+
+// Time without further change before the profiles are reloaded, an editor
+// saving a file generates several events
+const profileWatchDelay = 500 * time.Millisecond
+
+// profileWatcher reloads the profiles when a file of the profile directory
+// changes and applies the whitelist items added to the running sandboxes.
+type profileWatcher struct {
+	f    *os.File
+	done chan struct{}
+}
+
+func (d *daemonState) watchProfiles() (*profileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create inotify instance: %v", err)
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, d.config.ProfileDir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("unable to watch profile directory %s: %v", d.config.ProfileDir, err)
+	}
+	// Non blocking so the read returns once the file is closed
+	w := &profileWatcher{f: os.NewFile(uintptr(fd), "inotify"), done: make(chan struct{})}
+	changes := make(chan struct{}, 1)
+	go w.read(changes)
+	go func() {
+		for {
+			select {
+			case <-changes:
+			case <-w.done:
+				return
+			}
+			// Wait for the last of a burst of changes
+			for settled := false; !settled; {
+				select {
+				case <-changes:
+				case <-time.After(profileWatchDelay):
+					settled = true
+				case <-w.done:
+					return
+				}
+			}
+			d.applyProfileChanges()
+		}
+	}()
+	return w, nil
+}
+
+func (w *profileWatcher) read(changes chan<- struct{}) {
+	buf := make([]byte, 4096)
+	for {
+		if _, err := w.f.Read(buf); err != nil {
+			return
+		}
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *profileWatcher) stop() {
+	close(w.done)
+	w.f.Close()
+}
+
+// whitelistAdditions returns the items of the new whitelist missing from the
+// old one, and whether items of the old whitelist were removed or changed.
+func whitelistAdditions(old, new []oz.WhitelistItem) ([]oz.WhitelistItem, bool) {
+	contains := func(items []oz.WhitelistItem, wl oz.WhitelistItem) bool {
+		for _, item := range items {
+			if reflect.DeepEqual(item, wl) {
+				return true
+			}
+		}
+		return false
+	}
+	added := []oz.WhitelistItem{}
+	for _, wl := range new {
+		if !contains(old, wl) {
+			added = append(added, wl)
+		}
+	}
+	removed := false
+	for _, wl := range old {
+		if !contains(new, wl) {
+			removed = true
+		}
+	}
+	return added, removed
+}
+
+// applyProfileChanges reloads the profiles and binds the whitelist items added
+// to the profile of each running sandbox. Only additive changes apply, any
+// other change takes effect once the sandbox is restarted.
+func (d *daemonState) applyProfileChanges() {
+	ps, err := d.loadProfiles(d.config.ProfileDir)
+	if err != nil {
+		d.log.Warning("Failed to reload profiles after a change: %v", err)
+		return
+	}
+	d.profiles = ps
+	for _, sbox := range d.sandboxes {
+		var p *oz.Profile
+		for _, pp := range ps {
+			if pp.Name == sbox.profile.Name {
+				p = pp
+				break
+			}
+		}
+		if p == nil {
+			continue
+		}
+		added, removed := whitelistAdditions(sbox.profile.Whitelist, p.Whitelist)
+		oldp, newp := *sbox.profile, *p
+		oldp.Whitelist, newp.Whitelist = nil, nil
+		if removed || !reflect.DeepEqual(oldp, newp) {
+			d.log.Notice("Profile %s changed, only the whitelist items added apply to sandbox %d until it is restarted", p.Name, sbox.id)
+		}
+		if len(added) == 0 {
+			continue
+		}
+		if err := ozinit.AddWhitelistItems(sbox.addr, added); err != nil {
+			d.log.Warning("Unable to add the whitelist items of profile %s to sandbox %d: %v", p.Name, sbox.id, err)
+			continue
+		}
+		sbox.profile.Whitelist = append(sbox.profile.Whitelist, added...)
+		d.log.Info("Added %d whitelist items of profile %s to sandbox %d", len(added), p.Name, sbox.id)
+	}
+}
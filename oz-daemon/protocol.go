@@ -1,6 +1,10 @@
 package daemon
 
-import "github.com/subgraph/oz/ipc"
+import (
+	"time"
+
+	"github.com/subgraph/oz/ipc"
+)
 
 const SocketName = "@oz-control"
 
@@ -91,6 +95,8 @@ type SandboxInfo struct {
 	Mounts    []string
 	Ephemeral bool
 	InitPid int
+	Ready     bool
+	NotReady  bool
 }
 
 type ListSandboxesResp struct {
@@ -105,6 +111,33 @@ type RelaunchXpraClientMsg struct {
 	Id int "RelaunchXpraClient"
 }
 
+type ReloadSeccompMsg struct {
+	Id int "ReloadSeccomp"
+}
+
+// An empty Groups list selects every group allowed to the sandbox user
+type SetGroupsMsg struct {
+	Id     int "SetGroups"
+	Groups []string
+}
+
+type GetSeccompDenialsMsg struct {
+	Id int "GetSeccompDenials"
+}
+
+type SeccompDenial struct {
+	Pid         int
+	Comm        string
+	Exe         string
+	Syscall     int
+	SyscallName string
+	Time        time.Time
+}
+
+type SeccompDenialsResp struct {
+	Denials []SeccompDenial "SeccompDenialsResp"
+}
+
 type MountFilesMsg struct {
 	Id       int "MountFiles"
 	Files    []string
@@ -172,6 +205,10 @@ var messageFactory = ipc.NewMsgFactory(
 	new(ListSandboxesResp),
 	new(KillSandboxMsg),
 	new(RelaunchXpraClientMsg),
+	new(ReloadSeccompMsg),
+	new(SetGroupsMsg),
+	new(GetSeccompDenialsMsg),
+	new(SeccompDenialsResp),
 	new(MountFilesMsg),
 	new(UnmountFileMsg),
 	new(LogsMsg),
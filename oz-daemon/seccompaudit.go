@@ -0,0 +1,199 @@
+package daemon
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	constants "github.com/subgraph/constants"
+)
+
+const (
+	NETLINK_AUDIT = 9
+	// Multicast group receiving a copy of the audit records
+	AUDIT_NLGRP_READLOG = 1
+	AUDIT_SECCOMP       = 1326
+	AUDIT_ARCH_X86_64   = "c000003e"
+)
+
+// Number of recent denials kept for each sandbox
+const seccompDenialsKept = 100
+
+// seccompDenials holds the most recent syscalls denied in a sandbox. Records
+// are appended by the audit reader while clients query them.
+type seccompDenials struct {
+	lock    sync.Mutex
+	entries []SeccompDenial
+}
+
+func (sd *seccompDenials) add(d SeccompDenial) {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+	sd.entries = append(sd.entries, d)
+	if len(sd.entries) > seccompDenialsKept {
+		sd.entries = sd.entries[len(sd.entries)-seccompDenialsKept:]
+	}
+}
+
+func (sd *seccompDenials) list() []SeccompDenial {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+	return append([]SeccompDenial{}, sd.entries...)
+}
+
+// auditValue decodes a field of an audit record, strings containing
+// special characters are hex encoded instead of quoted.
+func auditValue(v string) string {
+	if len(v) >= 2 && strings.HasPrefix(v, "\"") && strings.HasSuffix(v, "\"") {
+		return v[1 : len(v)-1]
+	}
+	if b, err := hex.DecodeString(v); err == nil && len(v) > 0 {
+		return string(b)
+	}
+	return v
+}
+
+// parseSeccompRecord parses the text of an AUDIT_SECCOMP record:
+// audit(1700000000.123:42): auid=1000 uid=1000 ... pid=1234 comm="app" exe="/usr/bin/app" sig=31 arch=c000003e syscall=165 ...
+func parseSeccompRecord(record string) (*SeccompDenial, error) {
+	d := &SeccompDenial{Time: time.Now()}
+	arch := ""
+	hasSyscall := false
+	for _, field := range strings.Fields(record) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "pid":
+			pid, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pid in seccomp record: %s", kv[1])
+			}
+			d.Pid = pid
+		case "comm":
+			d.Comm = auditValue(kv[1])
+		case "exe":
+			d.Exe = auditValue(kv[1])
+		case "arch":
+			arch = kv[1]
+		case "syscall":
+			n, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid syscall in seccomp record: %s", kv[1])
+			}
+			d.Syscall = n
+			hasSyscall = true
+		}
+	}
+	if d.Pid == 0 || !hasSyscall {
+		return nil, fmt.Errorf("incomplete seccomp record: %s", record)
+	}
+	// Syscall numbers are only known for the native architecture
+	if arch == AUDIT_ARCH_X86_64 {
+		if name, err := constants.GetConstByNo("syscall_name", uint(d.Syscall)); err == nil {
+			d.SyscallName = name
+		}
+	}
+	return d, nil
+}
+
+func parentPid(pid int) (int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "PPid:") {
+			return strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "PPid:")))
+		}
+	}
+	return 0, fmt.Errorf("no parent found for pid %d", pid)
+}
+
+// sandboxByHostPid returns the sandbox whose oz-init is an ancestor of pid.
+// A process killed by seccomp is reaped quickly, it is then no longer found.
+func (d *daemonState) sandboxByHostPid(pid int) *Sandbox {
+	inits := make(map[int]*Sandbox)
+	for _, sb := range d.sandboxes {
+		if sb.init != nil && sb.init.Process != nil {
+			inits[sb.init.Process.Pid] = sb
+		}
+	}
+	for pid > 1 {
+		if sb, ok := inits[pid]; ok {
+			return sb
+		}
+		ppid, err := parentPid(pid)
+		if err != nil {
+			return nil
+		}
+		pid = ppid
+	}
+	return nil
+}
+
+// watchSeccompDenials reads the seccomp records of the kernel audit log and
+// records those of sandboxed processes. The audit multicast socket is only
+// available in the initial network namespace, so it is read by the daemon
+// rather than by oz-init.
+func (d *daemonState) watchSeccompDenials() error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, NETLINK_AUDIT)
+	if err != nil {
+		return fmt.Errorf("unable to open audit socket: %v", err)
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: AUDIT_NLGRP_READLOG}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("unable to join the audit log multicast group: %v", err)
+	}
+	go func() {
+		buf := make([]byte, os.Getpagesize()*2)
+		for {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				if err == syscall.EINTR || err == syscall.ENOBUFS {
+					continue
+				}
+				d.log.Warning("Stopped reading seccomp denials: %v", err)
+				syscall.Close(fd)
+				return
+			}
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				if m.Header.Type == AUDIT_SECCOMP {
+					d.recordSeccompDenial(string(m.Data))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (d *daemonState) recordSeccompDenial(record string) {
+	denial, err := parseSeccompRecord(strings.TrimRight(record, "\x00"))
+	if err != nil {
+		d.log.Debug("%v", err)
+		return
+	}
+	sbox := d.sandboxByHostPid(denial.Pid)
+	if sbox == nil {
+		return
+	}
+	name := denial.SyscallName
+	if name == "" {
+		name = strconv.Itoa(denial.Syscall)
+	}
+	d.log.Warning("Seccomp denied syscall %s to %s (pid %d, %s) in sandbox %d (%s)",
+		name, denial.Comm, denial.Pid, denial.Exe, sbox.id, sbox.profile.Name)
+	sbox.denials.add(*denial)
+}
@@ -0,0 +1,78 @@
+package ozinit
+
+import (
+	"os"
+
+	"github.com/subgraph/oz/xpra"
+)
+
+// watchStartupAbort aborts the startup when a shutdown signal arrives before
+// the sandbox is ready, the ipc server does not serve requests yet so the
+// daemon can only cancel a slow startup (ie: waiting on xpra) with a signal.
+// The other signals are queued, the returned function stops the watch and
+// returns them so that processSignals handles them in order.
+func (st *initState) watchStartupAbort(sigs chan os.Signal) func() []os.Signal {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	var pending []os.Signal
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-sigs:
+				if st.shutdownSignals[sig] {
+					st.log.Warning("Received signal (%v) during startup, aborting", sig)
+					st.abortStartup()
+				}
+				st.log.Info("Queueing signal (%v) received during startup", sig)
+				pending = append(pending, sig)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() []os.Signal {
+		close(stop)
+		<-stopped
+		return pending
+	}
+}
+
+// abortStartup kills what the startup already launched and exits. The mounts
+// and the network setup live in the namespaces of oz-init, the kernel releases
+// them once it exits as pid 1 of the sandbox. The cgroup of the applications,
+// the metadata file and the xpra work dirs live outside of them and are
+// removed here.
+func (st *initState) abortStartup() {
+	st.releaseStartup()
+	os.Exit(1)
+}
+
+// releaseStartup kills the processes of the aborted startup and removes the
+// files and cgroup it created outside of the namespaces of oz-init.
+func (st *initState) releaseStartup() {
+	for _, c := range st.childrenVector() {
+		c.cmd.Process.Kill()
+	}
+	st.lock.Lock()
+	xpras := make(map[int]*xpra.Xpra, len(st.xpras))
+	for display, x := range st.xpras {
+		xpras[display] = x
+		if x.Process.Process != nil {
+			x.Process.Process.Kill()
+		}
+	}
+	st.lock.Unlock()
+	// The cgroup cannot be removed before the killed processes exited
+	st.waitChildrenExit()
+	st.removeAppsCgroup()
+	st.removeMetadata()
+	if st.config.CleanXpraWorkdir {
+		for display, x := range xpras {
+			st.removeXpraWorkdir(x.WorkDir, display)
+		}
+	}
+	if err := os.Remove(st.sockaddr); err != nil && !os.IsNotExist(err) {
+		st.log.Warning("Failed to remove oz-init control socket: %v", err)
+	}
+}
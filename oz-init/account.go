@@ -0,0 +1,58 @@
+package ozinit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+)
+
+// Home directory conventionally given to service accounts which have none
+const nonexistentHome = "/nonexistent"
+
+var nologinShells = map[string]bool{
+	"nologin": true,
+	"false":   true,
+}
+
+// hasHomeDir returns false for accounts without a usable home directory,
+// HOME is then left unset and no home is created inside the sandbox.
+func hasHomeDir(u *user.User) bool {
+	return u != nil && u.HomeDir != "" && u.HomeDir != nonexistentHome
+}
+
+func isNologinShell(shell string) bool {
+	return nologinShells[path.Base(shell)]
+}
+
+// lookupLoginShell returns the login shell of username in the passwd file
+func lookupLoginShell(passwd, username string) (string, error) {
+	f, err := os.Open(passwd)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) == 7 && fields[0] == username {
+			return fields[6], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("user (%s) not found in %s", username, passwd)
+}
+
+// checkShellAllowed rejects shells for accounts which are not allowed to log
+// in, unless allow_nologin_shell is set in the oz config.
+func (st *initState) checkShellAllowed() error {
+	if !isNologinShell(st.userShell) || st.config.AllowNologinShell {
+		return nil
+	}
+	return fmt.Errorf("Cannot open shell because the login shell of %s is %s", st.user.Username, st.userShell)
+}
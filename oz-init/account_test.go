@@ -0,0 +1,83 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+const testPasswd = `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+svc:x:998:998::/nonexistent:/bin/false
+alice:x:1000:1000:Alice,,,:/home/alice:/bin/zsh
+`
+
+func TestLookupLoginShell(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-init-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	passwd := path.Join(dir, "passwd")
+	if err := ioutil.WriteFile(passwd, []byte(testPasswd), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"daemon": "/usr/sbin/nologin",
+		"svc":    "/bin/false",
+		"alice":  "/bin/zsh",
+	} {
+		shell, err := lookupLoginShell(passwd, name)
+		if err != nil {
+			t.Errorf("unexpected error looking up %s: %v", name, err)
+		} else if shell != expected {
+			t.Errorf("expecting login shell %s for %s, got %s", expected, name, shell)
+		}
+	}
+	if _, err := lookupLoginShell(passwd, "bob"); err == nil {
+		t.Error("expecting an error for a user missing from passwd")
+	}
+}
+
+func TestCheckShellAllowed(t *testing.T) {
+	for shell, allowed := range map[string]bool{
+		"/usr/sbin/nologin": false,
+		"/sbin/nologin":     false,
+		"/bin/false":        false,
+		"/bin/bash":         true,
+		"":                  true,
+	} {
+		st := &initState{
+			config:    &oz.Config{},
+			user:      &user.User{Username: "svc"},
+			userShell: shell,
+		}
+		if err := st.checkShellAllowed(); (err == nil) != allowed {
+			t.Errorf("expecting shell request allowed = %v for login shell %q, got %v", allowed, shell, err)
+		}
+		st.config.AllowNologinShell = true
+		if err := st.checkShellAllowed(); err != nil {
+			t.Errorf("expecting allow_nologin_shell to override login shell %q, got %v", shell, err)
+		}
+	}
+}
+
+func TestHasHomeDir(t *testing.T) {
+	for home, expected := range map[string]bool{
+		"/home/alice":  true,
+		"/nonexistent": false,
+		"":             false,
+	} {
+		if hasHomeDir(&user.User{HomeDir: home}) != expected {
+			t.Errorf("expecting hasHomeDir = %v for home %q", expected, home)
+		}
+	}
+	if hasHomeDir(nil) {
+		t.Error("expecting no home directory without a user")
+	}
+}
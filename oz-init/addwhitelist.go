@@ -0,0 +1,117 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/fs"
+	"github.com/subgraph/oz/ipc"
+)
+
+// addWhitelistAllowed returns whether a client running as uid may add p to
+// the whitelist, the sandbox user is limited to its home and removable media
+// like the files passed as arguments.
+func (st *initState) addWhitelistAllowed(uid uint32, p string) bool {
+	if uid == 0 {
+		return true
+	}
+	if uid != st.uid || st.user == nil {
+		return false
+	}
+	for _, dir := range []string{st.user.HomeDir, "/media/user"} {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// inHostRoot runs f with the root of the host as its root, in the mount
+// namespace of the sandbox: the host paths are not reachable from the chroot.
+// f runs on a thread given its own root, which is never reused and exits
+// along with its goroutine.
+func (st *initState) inHostRoot(f func() error) error {
+	if st.config.UsePivotRoot {
+		return fmt.Errorf("adding whitelist items to a running sandbox is not supported with use_pivot_root")
+	}
+	if st.hostRoot == nil {
+		return fmt.Errorf("the filesystem of the sandbox is not set up")
+	}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := syscall.Unshare(syscall.CLONE_FS); err != nil {
+			errc <- fmt.Errorf("unable to unshare the filesystem attributes: %v", err)
+			return
+		}
+		if err := syscall.Fchdir(int(st.hostRoot.Fd())); err != nil {
+			errc <- fmt.Errorf("unable to enter the host root: %v", err)
+			return
+		}
+		if err := syscall.Chroot("."); err != nil {
+			errc <- fmt.Errorf("unable to enter the host root: %v", err)
+			return
+		}
+		errc <- f()
+	}()
+	return <-errc
+}
+
+// bindWhitelistItems binds whitelist items into the running sandbox
+func (st *initState) bindWhitelistItems(items []oz.WhitelistItem) error {
+	return st.inHostRoot(func() error {
+		fsys := fs.NewFilesystem(st.config, st.log, st.user, st.profile)
+		return st.bindWhitelist(fsys, items)
+	})
+}
+
+func (st *initState) handleAddWhitelist(aw *AddWhitelistMsg, msg *ipc.Message) error {
+	if msg.Ucred == nil {
+		return msg.Respond(&ErrorMsg{"No credentials received for add whitelist command"})
+	}
+	if !path.IsAbs(aw.Path) {
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("Whitelist path (%s) must be absolute", aw.Path)})
+	}
+	p := path.Clean(aw.Path)
+	if !st.addWhitelistAllowed(msg.Ucred.Uid, p) {
+		st.log.Warning("Rejecting whitelist request for %s from uid %d", p, msg.Ucred.Uid)
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("Not allowed to add %s to the whitelist", p)})
+	}
+	err := st.inHostRoot(func() error {
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("path (%s) does not exist", p)
+		}
+		fsys := fs.NewFilesystem(st.config, st.log, st.user, st.profile)
+		return st.bindWhitelist(fsys, []oz.WhitelistItem{{Path: p, ReadOnly: aw.ReadOnly}})
+	})
+	if err != nil {
+		st.log.Warning("Unable to add %s to the whitelist: %v", p, err)
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	st.log.Info("Added %s to the whitelist (read-only: %v)", p, aw.ReadOnly)
+	return msg.Respond(&OkMsg{})
+}
+
+// handleAddWhitelistItems binds the whitelist items added to the profile of
+// the running sandbox, the daemon pushes them when the profile file changes.
+func (st *initState) handleAddWhitelistItems(ai *AddWhitelistItemsMsg, msg *ipc.Message) error {
+	if msg.Ucred == nil || msg.Ucred.Uid != 0 {
+		return msg.Respond(&ErrorMsg{"Only root may add whitelist items of the profile"})
+	}
+	if err := st.bindWhitelistItems(ai.Items); err != nil {
+		st.log.Warning("Unable to add whitelist items of the profile: %v", err)
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	st.lock.Lock()
+	st.profile.Whitelist = append(st.profile.Whitelist, ai.Items...)
+	st.lock.Unlock()
+	for _, wl := range ai.Items {
+		st.log.Info("Added whitelist item %s of the reloaded profile", wl.Path)
+	}
+	return msg.Respond(&OkMsg{})
+}
@@ -0,0 +1,26 @@
+package ozinit
+
+import (
+	"os/user"
+	"testing"
+)
+
+func TestAddWhitelistAllowed(t *testing.T) {
+	st := &initState{uid: 1000, user: &user.User{HomeDir: "/home/user"}}
+	for _, p := range []string{"/home/user", "/home/user/Downloads/file.pdf", "/media/user/usb/file"} {
+		if !st.addWhitelistAllowed(1000, p) {
+			t.Errorf("expecting the sandbox user to be allowed to add %s", p)
+		}
+	}
+	for _, p := range []string{"/etc/shadow", "/home/username/file", "/home/other/file"} {
+		if st.addWhitelistAllowed(1000, p) {
+			t.Errorf("expecting the sandbox user not to be allowed to add %s", p)
+		}
+	}
+	if st.addWhitelistAllowed(1001, "/home/user/file") {
+		t.Error("expecting another user not to be allowed to add a path")
+	}
+	if !st.addWhitelistAllowed(0, "/etc/hosts") {
+		t.Error("expecting root to be allowed to add any path")
+	}
+}
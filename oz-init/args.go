@@ -0,0 +1,67 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// expandFixedArg replaces the ${HOME}, ${USER}, ${UID}, ${SANDBOXNAME} and
+// ${DISPLAY} variables anywhere in a fixed argument of the profile.
+func (st *initState) expandFixedArg(arg string) (string, error) {
+	vars := []string{
+		"${SANDBOXNAME}", st.profile.Name,
+		"${DISPLAY}", strconv.Itoa(st.display),
+	}
+	if st.user != nil {
+		vars = append(vars,
+			"${HOME}", st.user.HomeDir,
+			"${USER}", st.user.Username,
+			"${UID}", st.user.Uid,
+		)
+	}
+	expanded := strings.NewReplacer(vars...).Replace(arg)
+	if strings.Contains(expanded, "${") {
+		return "", fmt.Errorf("unable to expand fixed argument (%s)", arg)
+	}
+	return expanded, nil
+}
+
+// applyFixedArgs adds the fixed arguments of the profile before or after
+// cmdArgs, which already hold the default params and the caller arguments.
+// They are added even when the caller arguments are rejected.
+func (st *initState) applyFixedArgs(cmdArgs []string) ([]string, error) {
+	if len(st.profile.FixedArgs) == 0 {
+		return cmdArgs, nil
+	}
+	fixed := make([]string, 0, len(st.profile.FixedArgs))
+	for _, arg := range st.profile.FixedArgs {
+		a, err := st.expandFixedArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		fixed = append(fixed, a)
+	}
+	if st.profile.FixedArgsPosition == "after" {
+		return append(cmdArgs, fixed...), nil
+	}
+	return append(fixed, cmdArgs...), nil
+}
+
+// checkExecutable verifies that p is an absolute path to an executable file,
+// required when argv0 no longer tells which program runs.
+func checkExecutable(p string) error {
+	if !path.IsAbs(p) {
+		return fmt.Errorf("executable path (%s) must be absolute", p)
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%s is not an executable file", p)
+	}
+	return nil
+}
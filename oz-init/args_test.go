@@ -0,0 +1,108 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
+
+func TestApplyFixedArgs(t *testing.T) {
+	st := &initState{
+		profile: &oz.Profile{
+			Name:      "firefox",
+			FixedArgs: []string{"--no-remote", "--profile=${HOME}/.mozilla/${SANDBOXNAME}"},
+		},
+		user: &user.User{Username: "alice", Uid: "1000", HomeDir: "/home/alice"},
+	}
+	fixed := []string{"--no-remote", "--profile=/home/alice/.mozilla/firefox"}
+
+	args, err := st.applyFixedArgs([]string{"https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := append(append([]string{}, fixed...), "https://example.com"); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expecting fixed args before the caller args %v, got %v", expected, args)
+	}
+
+	st.profile.FixedArgsPosition = "after"
+	args, err = st.applyFixedArgs([]string{"https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := append([]string{"https://example.com"}, fixed...); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expecting fixed args after the caller args %v, got %v", expected, args)
+	}
+
+	st.profile.FixedArgs = []string{"--cache=${XDG_CACHE_HOME}"}
+	if _, err := st.applyFixedArgs(nil); err == nil {
+		t.Error("expecting an error for an unknown variable")
+	}
+}
+
+func TestCheckExecutable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-args-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := path.Join(dir, "multicall")
+	data := path.Join(dir, "data")
+	ioutil.WriteFile(exe, []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(data, []byte("x"), 0644)
+
+	if err := checkExecutable(exe); err != nil {
+		t.Errorf("expecting %s to be accepted: %v", exe, err)
+	}
+	for _, p := range []string{data, dir, path.Join(dir, "missing"), "multicall"} {
+		if err := checkExecutable(p); err == nil {
+			t.Errorf("expecting %s to be rejected", p)
+		}
+	}
+}
+
+func TestProgramAllowed(t *testing.T) {
+	st := &initState{
+		profile: &oz.Profile{
+			Path:         "/usr/bin/libreoffice",
+			Paths:        []string{"/usr/bin/lowriter"},
+			AllowedPaths: []string{"/usr/lib/libreoffice/program/soffice.bin"},
+		},
+	}
+	for _, p := range []string{"/usr/bin/libreoffice", "/usr/bin/lowriter", "/usr/lib/libreoffice/program//soffice.bin"} {
+		if !st.programAllowed(p) {
+			t.Errorf("expecting %s to be allowed", p)
+		}
+	}
+	for _, p := range []string{"", "/bin/sh", "/usr/bin/../bin/sh", "lowriter"} {
+		if st.programAllowed(p) {
+			t.Errorf("expecting %s to be rejected", p)
+		}
+	}
+}
+
+func TestExecProgramAllowed(t *testing.T) {
+	const addr = "@oz-init-test-exec"
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{Path: "/usr/bin/libreoffice"},
+	}
+	s, err := ipc.NewServer(addr, messageFactory, nil, st.handleExec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	_, err = Exec(addr, "/bin/sh", "", []string{"-c", "true"}, ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expecting exec of a program not allowed by the profile to be rejected, got %v", err)
+	}
+}
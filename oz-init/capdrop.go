@@ -0,0 +1,145 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/subgraph/oz"
+)
+
+const (
+	PR_CAPBSET_READ          = 23
+	PR_CAPBSET_DROP          = 24
+	PR_CAP_AMBIENT           = 47
+	PR_CAP_AMBIENT_CLEAR_ALL = 4
+	linuxCapabilityVersion3  = 0x20080522
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// lastCapability returns the highest capability known to the kernel
+func lastCapability() (uint, error) {
+	data, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	return uint(n), err
+}
+
+// capabilitiesToDrop returns the names of the capabilities dropped from the
+// applications, sorted by number, the unknown names of the profile and the
+// granted capabilities it drops explicitly. Every capability up to last is
+// dropped unless the profile lists the ones to drop, except those kept by the
+// profile and those granted to binaries with file capabilities, which exec
+// can only give within the bounding set. An explicit drop list always wins
+// over the granted capabilities so that they never widen it.
+func capabilitiesToDrop(p *oz.Profile, granted map[string]bool, last uint) ([]string, []string, []string) {
+	unknown := []string{}
+	known := func(names []string) map[string]bool {
+		m := make(map[string]bool)
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if _, ok := capabilityNames[name]; !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+			m[name] = true
+		}
+		return m
+	}
+	keep := known(p.KeepCapabilities)
+	drop := known(p.DropCapabilities)
+	explicit := len(p.DropCapabilities) > 0
+	overridden := []string{}
+	for name := range granted {
+		if explicit && drop[name] && !keep[name] {
+			overridden = append(overridden, name)
+			continue
+		}
+		keep[name] = true
+	}
+	if !explicit {
+		for name := range capabilityNames {
+			drop[name] = true
+		}
+	}
+	names := []string{}
+	for name := range drop {
+		if !keep[name] && capabilityNames[name] <= last {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(capsByNumber(names))
+	sort.Sort(capsByNumber(overridden))
+	return names, unknown, overridden
+}
+
+type capsByNumber []string
+
+func (c capsByNumber) Len() int           { return len(c) }
+func (c capsByNumber) Less(i, j int) bool { return capabilityNames[c[i]] < capabilityNames[c[j]] }
+func (c capsByNumber) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// dropThreadCapabilities removes the capabilities from the bounding, ambient
+// and inheritable sets of the calling thread, so that the processes it forks
+// can never gain them back through exec. The thread must not be reused.
+func dropThreadCapabilities(names []string) error {
+	for _, name := range names {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_CAPBSET_DROP, uintptr(capabilityNames[name]), 0); errno != 0 {
+			return fmt.Errorf("unable to drop %s from the bounding set: %v", name, errno)
+		}
+	}
+	// Ambient capabilities are not supported before Linux 4.3
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, PR_CAP_AMBIENT, PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+		return fmt.Errorf("unable to clear the ambient capabilities: %v", errno)
+	}
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("unable to read the capabilities: %v", errno)
+	}
+	for _, name := range names {
+		n := capabilityNames[name]
+		data[n/32].inheritable &^= 1 << (n % 32)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("unable to clear the inheritable capabilities: %v", errno)
+	}
+	return nil
+}
+
+// applicationCapabilities returns the capabilities to drop on the thread
+// forking an application, logging the unknown names of the profile.
+func (st *initState) applicationCapabilities() []string {
+	last, err := lastCapability()
+	if err != nil {
+		st.log.Warning("Unable to read the last capability of the kernel: %v", err)
+		last = capabilityNames["cap_checkpoint_restore"]
+	}
+	st.lock.Lock()
+	granted := st.grantedCaps
+	st.lock.Unlock()
+	names, unknown, overridden := capabilitiesToDrop(st.profile, granted, last)
+	for _, name := range unknown {
+		st.log.Warning("Unknown capability (%s) in profile capabilities, ignored", name)
+	}
+	for _, name := range overridden {
+		st.log.Warning("Capability (%s) granted with file capabilities is dropped by drop_capabilities", name)
+	}
+	return names
+}
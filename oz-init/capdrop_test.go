@@ -0,0 +1,87 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestCapabilitiesToDrop(t *testing.T) {
+	last := capabilityNames["cap_audit_read"]
+	p := &oz.Profile{
+		KeepCapabilities: []string{"CAP_NET_BIND_SERVICE"},
+		FileCapabilities: map[string][]string{"/bin/ping": {"cap_net_raw"}, "/bin/missing": {"cap_sys_admin"}},
+	}
+	granted := map[string]bool{"cap_net_raw": true}
+	names, unknown, overridden := capabilitiesToDrop(p, granted, last)
+	if len(unknown) != 0 || len(overridden) != 0 {
+		t.Errorf("expecting no unknown or overridden capability, got %v %v", unknown, overridden)
+	}
+	if len(names) != int(last)+1-2 {
+		t.Errorf("expecting every capability but 2 to be dropped, got %v", names)
+	}
+	for _, name := range names {
+		switch name {
+		case "cap_net_bind_service", "cap_net_raw":
+			t.Errorf("expecting %s to be kept", name)
+		case "cap_perfmon", "cap_bpf", "cap_checkpoint_restore":
+			t.Errorf("expecting %s unknown to the kernel not to be dropped", name)
+		}
+	}
+	if names[0] != "cap_chown" {
+		t.Errorf("expecting capabilities sorted by number, got %v", names)
+	}
+
+	// Only the capabilities of the binaries actually granted are kept
+	dropped := false
+	for _, name := range names {
+		dropped = dropped || name == "cap_sys_admin"
+	}
+	if !dropped {
+		t.Error("expecting cap_sys_admin of a binary which was not granted to be dropped")
+	}
+
+	// The explicit drop list wins over the granted capabilities
+	p.DropCapabilities = []string{"cap_sys_admin", "cap_net_raw", "cap_chwon", "cap_chown"}
+	names, unknown, overridden = capabilitiesToDrop(p, granted, last)
+	if expected := []string{"cap_chown", "cap_net_raw", "cap_sys_admin"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expecting %v to be dropped, got %v", expected, names)
+	}
+	if !reflect.DeepEqual(unknown, []string{"cap_chwon"}) {
+		t.Errorf("expecting cap_chwon to be reported unknown, got %v", unknown)
+	}
+	if !reflect.DeepEqual(overridden, []string{"cap_net_raw"}) {
+		t.Errorf("expecting cap_net_raw to be reported dropped despite being granted, got %v", overridden)
+	}
+}
+
+func TestDropThreadCapabilities(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("dropping capabilities requires root")
+	}
+	res := make(chan error)
+	go func() {
+		// Never unlocked, the thread exits with this goroutine
+		runtime.LockOSThread()
+		if err := dropThreadCapabilities([]string{"cap_sys_boot"}); err != nil {
+			res <- err
+			return
+		}
+		r, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_CAPBSET_READ, uintptr(capabilityNames["cap_sys_boot"]), 0)
+		if errno != 0 {
+			res <- errno
+		} else if r != 0 {
+			res <- fmt.Errorf("expecting cap_sys_boot to be dropped from the bounding set")
+		} else {
+			res <- nil
+		}
+	}()
+	if err := <-res; err != nil {
+		t.Error(err)
+	}
+}
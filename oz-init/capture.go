@@ -0,0 +1,160 @@
+package ozinit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/subgraph/oz"
+)
+
+// captureLimit bounds how much output of one application stream is logged,
+// the output past the limit is read and discarded so the application keeps
+// running. A zero limit is unlimited.
+type captureLimit struct {
+	max       uint64
+	captured  uint64
+	truncated bool
+}
+
+func (st *initState) newCaptureLimit() *captureLimit {
+	if st.config == nil {
+		return &captureLimit{}
+	}
+	return &captureLimit{max: st.config.MaxCapturedOutputBytes}
+}
+
+// accept returns whether n more bytes are captured, and whether the limit was
+// just reached so the truncation marker is logged once.
+func (c *captureLimit) accept(n int) (bool, bool) {
+	if c.max == 0 {
+		return true, false
+	}
+	if c.truncated {
+		return false, false
+	}
+	if c.captured+uint64(n) > c.max {
+		c.truncated = true
+		return false, true
+	}
+	c.captured += uint64(n)
+	return true, false
+}
+
+func (st *initState) captureLine(limit *captureLimit, label, line string) {
+	if st.profile != nil && st.profile.CaptureCrashDiagnostics {
+		st.outputTail.add(label, line)
+	}
+	ok, truncated := limit.accept(len(line) + 1)
+	if ok {
+		// A NUL would cut the line short in syslog
+		st.log.Debug("(%s) %s", label, strings.Replace(line, "\x00", "\\x00", -1))
+	} else if truncated {
+		st.log.Warning("(%s) [output truncated after %d bytes]", label, limit.captured)
+	}
+}
+
+// openOutputCapture opens the file in log_dir receiving the output of the
+// application pid, nil is returned when its output is logged instead.
+func (st *initState) openOutputCapture(name string, pid int) *os.File {
+	if !st.profile.CaptureOutput {
+		return nil
+	}
+	dir := st.profile.LogDir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		st.log.Warning("Unable to create log directory, logging the output of %s: %v", name, err)
+		return nil
+	}
+	p := path.Join(dir, fmt.Sprintf("%s-%d.log", name, pid))
+	// The directory may be writable by the sandbox user, never follow a link
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND|syscall.O_NOFOLLOW, 0600)
+	if os.IsExist(err) {
+		f, err = st.openCaptureFile(p)
+	}
+	if err != nil {
+		st.log.Warning("Unable to open output capture file, logging the output of %s: %v", name, err)
+		return nil
+	}
+	if err := f.Chown(int(st.uid), int(st.gid)); err != nil {
+		st.log.Warning("Unable to give the output capture file %s to the sandbox user: %v", p, err)
+	}
+	st.log.Info("Output of %s (pid %d) captured to %s", name, pid, p)
+	return f
+}
+
+// openCaptureFile opens an existing capture file for appending. The pids are
+// predictable, so it must be a file of the sandbox user rather than a hard
+// link planted to have a file of root appended to and given away.
+func (st *initState) openCaptureFile(p string) (*os.File, error) {
+	// A fifo would block until a reader opens it
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sys := fi.Sys().(*syscall.Stat_t)
+	if !fi.Mode().IsRegular() || sys.Nlink != 1 || sys.Uid != st.uid {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a regular file of the sandbox user with a single link", p)
+	}
+	return f, nil
+}
+
+// writeCapturedLine appends a line of output to the capture file of its
+// application instead of logging it, within the same limit as logged output
+func (st *initState) writeCapturedLine(out *os.File, limit *captureLimit, label, line string) error {
+	if st.profile != nil && st.profile.CaptureCrashDiagnostics {
+		st.outputTail.add(label, line)
+	}
+	ok, truncated := limit.accept(len(line) + 1)
+	if ok {
+		_, err := out.WriteString(line + "\n")
+		return err
+	} else if truncated {
+		st.log.Warning("(%s) [captured output truncated after %d bytes]", label, limit.captured)
+		_, err := fmt.Fprintf(out, "[%s output truncated after %d bytes]\n", label, limit.captured)
+		return err
+	}
+	return nil
+}
+
+func (st *initState) maxLogLine() int {
+	if st.config == nil || st.config.MaxLogLineBytes <= 0 {
+		return oz.DefaultMaxLogLineBytes
+	}
+	return st.config.MaxLogLineBytes
+}
+
+// readLogLine reads the next line keeping at most max bytes of it, the rest
+// of a longer line is read and discarded. It returns the line, the number of
+// bytes discarded and the read error once no more input is left.
+func readLogLine(br *bufio.Reader, max int) (string, int, error) {
+	line := []byte{}
+	dropped := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if room := max - len(line); len(chunk) > room {
+			dropped += len(chunk) - room
+			chunk = chunk[:room]
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (len(line) > 0 || dropped > 0) {
+			// Return the last unterminated line first
+			return string(line), dropped, nil
+		}
+		return string(line), dropped, err
+	}
+}
@@ -0,0 +1,196 @@
+package ozinit
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestCaptureLimit(t *testing.T) {
+	c := &captureLimit{max: 10}
+	if ok, _ := c.accept(6); !ok {
+		t.Error("expecting output below the limit to be captured")
+	}
+	if ok, truncated := c.accept(6); ok || !truncated {
+		t.Errorf("expecting output past the limit to be truncated, got %v %v", ok, truncated)
+	}
+	if ok, truncated := c.accept(1); ok || truncated {
+		t.Errorf("expecting output after truncation to be discarded silently, got %v %v", ok, truncated)
+	}
+	if c.captured != 6 {
+		t.Errorf("expecting 6 bytes captured, got %d", c.captured)
+	}
+}
+
+func TestCaptureLimitUnlimited(t *testing.T) {
+	c := &captureLimit{}
+	for i := 0; i < 100; i++ {
+		if ok, _ := c.accept(4096); !ok {
+			t.Fatal("expecting a zero limit to capture all output")
+		}
+	}
+}
+
+func TestReadLogLine(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	br := bufio.NewReader(strings.NewReader("short\n" + long + "\na\x00b\nlast"))
+	expected := []struct {
+		line    string
+		dropped int
+	}{
+		{"short", 0},
+		{long[:1024], len(long) - 1024},
+		{"a\x00b", 0},
+		{"last", 0},
+	}
+	for _, e := range expected {
+		line, dropped, err := readLogLine(br, 1024)
+		if err != nil {
+			t.Fatalf("unexpected error reading %q: %v", e.line, err)
+		}
+		if line != e.line || dropped != e.dropped {
+			t.Errorf("expecting %d bytes with %d discarded, got %d bytes with %d discarded", len(e.line), e.dropped, len(line), dropped)
+		}
+	}
+	if _, _, err := readLogLine(br, 1024); err != io.EOF {
+		t.Errorf("expecting EOF after the last line, got %v", err)
+	}
+}
+
+func TestReadApplicationOutputLongLine(t *testing.T) {
+	be := logging.InitForTesting(logging.DEBUG)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{},
+		config:  &oz.Config{MaxLogLineBytes: 1024},
+	}
+	out := "before\n" + strings.Repeat("x", 100*1024) + "\na\x00b\nafter\n"
+	st.readApplicationOutput(ioutil.NopCloser(strings.NewReader(out)), "stdout", nil)
+
+	logged := []string{}
+	for n := be.Head(); n != nil; n = n.Next() {
+		logged = append(logged, n.Record.Message())
+	}
+	all := strings.Join(logged, "\n")
+	for _, s := range []string{"(stdout) before", "[line truncated to 1024 bytes", `(stdout) a\x00b`, "(stdout) after"} {
+		if !strings.Contains(all, s) {
+			t.Errorf("expecting %q to be logged, got:\n%s", s, all)
+		}
+	}
+	if strings.Contains(all, strings.Repeat("x", 1025)) {
+		t.Error("expecting the long line to be truncated")
+	}
+}
+
+func TestMaxLogLineDefault(t *testing.T) {
+	for _, st := range []*initState{{}, {config: &oz.Config{}}, {config: oz.NewDefaultConfig()}} {
+		if n := st.maxLogLine(); n != oz.DefaultMaxLogLineBytes {
+			t.Errorf("expecting the default line length of %d bytes, got %d", oz.DefaultMaxLogLineBytes, n)
+		}
+	}
+}
+
+func TestReadApplicationOutputCaptured(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-capture-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	be := logging.InitForTesting(logging.DEBUG)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{CaptureOutput: true, LogDir: path.Join(dir, "log")},
+		config:  &oz.Config{},
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	for _, run := range []string{"first\n", "second\n"} {
+		f := st.openOutputCapture("app", 42)
+		if f == nil {
+			t.Fatal("expecting the output capture file to be opened")
+		}
+		st.readApplicationOutput(ioutil.NopCloser(strings.NewReader(run)), "stdout", f)
+		f.Close()
+	}
+	data, err := ioutil.ReadFile(path.Join(dir, "log", "app-42.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("expecting the output appended to the capture file, got %q", data)
+	}
+	for n := be.Head(); n != nil; n = n.Next() {
+		if strings.Contains(n.Record.Message(), "(stdout)") {
+			t.Errorf("expecting captured output not to be logged, got %q", n.Record.Message())
+		}
+	}
+}
+
+func TestOutputCaptureHardLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-capture-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{CaptureOutput: true, LogDir: dir},
+		config:  &oz.Config{},
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	target := path.Join(dir, "target")
+	if err := ioutil.WriteFile(target, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(target, path.Join(dir, "app-42.log")); err != nil {
+		t.Fatal(err)
+	}
+	if f := st.openOutputCapture("app", 42); f != nil {
+		f.Close()
+		t.Fatal("expecting a hard link planted as the capture file to be refused")
+	}
+	if err := syscall.Mkfifo(path.Join(dir, "app-43.log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if f := st.openOutputCapture("app", 43); f != nil {
+		f.Close()
+		t.Fatal("expecting a fifo planted as the capture file to be refused")
+	}
+}
+
+func TestReadApplicationOutputCapturedLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-capture-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{CaptureOutput: true, LogDir: dir},
+		config:  &oz.Config{MaxCapturedOutputBytes: 12},
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	f := st.openOutputCapture("app", 42)
+	if f == nil {
+		t.Fatal("expecting the output capture file to be opened")
+	}
+	st.readApplicationOutput(ioutil.NopCloser(strings.NewReader("first\nsecond\nthird\n")), "stdout", f)
+	f.Close()
+	data, err := ioutil.ReadFile(path.Join(dir, "app-42.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "first\n[stdout output truncated after 6 bytes]\n"; string(data) != expected {
+		t.Errorf("expecting %q in the capture file, got %q", expected, data)
+	}
+}
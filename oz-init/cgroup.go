@@ -0,0 +1,148 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"syscall"
+
+	"github.com/subgraph/oz"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Leaf cgroup oz-init moves to, processes cannot live in a cgroup with
+	// controllers enabled for its children
+	initCgroupLeaf = "oz-init"
+	// Cgroup the applications are placed in, with the limits of the profile
+	appsCgroupLeaf = "apps"
+	// Period of the cpu quota in microseconds
+	cpuQuotaPeriod = 100000
+)
+
+// appsCgroup is the cgroup enforcing the limits of the applications. /sys is
+// not available inside the sandbox, so its files are opened before the root
+// is changed and kept open.
+type appsCgroup struct {
+	path   string
+	parent *os.File
+	dir    *os.File
+	// memory.reclaim, nil without the memory controller
+	reclaim *os.File
+}
+
+// setupAppsCgroup creates the cgroup limiting the memory and cpu of the
+// applications to the limits of the profile. An out of memory condition then
+// only kills the processes of the sandbox, oz-init is kept out of the cgroup
+// to survive it. The daemon gives oz-init a cgroup of its own when a limit is
+// set, without limits no cgroup is created.
+func (st *initState) setupAppsCgroup() error {
+	limits := st.profile.Limits
+	if limits.MemoryMB == 0 && limits.CPUPercent == 0 {
+		return nil
+	}
+	base, err := oz.OwnCgroup()
+	if err != nil {
+		return err
+	}
+	leaf := path.Join(base, initCgroupLeaf)
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err := oz.WriteCgroupFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+		return err
+	}
+	apps := path.Join(base, appsCgroupLeaf)
+	if limits.MemoryMB != 0 {
+		if err := oz.WriteCgroupFile(base, "cgroup.subtree_control", "+memory"); err != nil {
+			return fmt.Errorf("memory controller unavailable: %v", err)
+		}
+	}
+	if limits.CPUPercent != 0 {
+		if err := oz.WriteCgroupFile(base, "cgroup.subtree_control", "+cpu"); err != nil {
+			return fmt.Errorf("cpu controller unavailable: %v", err)
+		}
+	}
+	if err := os.Mkdir(apps, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if limits.MemoryMB != 0 {
+		if err := oz.WriteCgroupFile(apps, "memory.max", strconv.FormatUint(limits.MemoryMB*1024*1024, 10)); err != nil {
+			return err
+		}
+		// Kill the whole cgroup rather than a single process when out of memory
+		if err := oz.WriteCgroupFile(apps, "memory.oom.group", "1"); err != nil {
+			st.log.Warning("%v", err)
+		}
+		st.log.Info("Applications limited to %d MiB of memory in cgroup %s", limits.MemoryMB, apps)
+	}
+	if limits.CPUPercent != 0 {
+		quota := limits.CPUPercent * cpuQuotaPeriod / 100
+		if err := oz.WriteCgroupFile(apps, "cpu.max", fmt.Sprintf("%d %d", quota, cpuQuotaPeriod)); err != nil {
+			return err
+		}
+		st.log.Info("Applications limited to %d%% of a cpu in cgroup %s", limits.CPUPercent, apps)
+	}
+	parent, err := os.Open(base)
+	if err != nil {
+		return err
+	}
+	dir, err := os.Open(apps)
+	if err != nil {
+		parent.Close()
+		return err
+	}
+	cg := &appsCgroup{path: apps, parent: parent, dir: dir}
+	if limits.MemoryMB != 0 {
+		if f, err := os.OpenFile(path.Join(apps, "memory.reclaim"), os.O_WRONLY, 0); err != nil {
+			st.log.Info("Memory reclaim through the cgroup is unavailable: %v", err)
+		} else {
+			cg.reclaim = f
+		}
+	}
+	st.appsCgroup = cg
+	return nil
+}
+
+// intoAppsCgroup makes cmd start in the cgroup of the applications with
+// CLONE_INTO_CGROUP, so that the limits apply from its first instruction
+// rather than once it was moved there after being started.
+func (st *initState) intoAppsCgroup(cmd *exec.Cmd) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.appsCgroup == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(st.appsCgroup.dir.Fd())
+}
+
+// removeAppsCgroup removes the cgroup of the applications once they exited,
+// the daemon removes it with the cgroup of oz-init otherwise.
+func (st *initState) removeAppsCgroup() {
+	st.lock.Lock()
+	cg := st.appsCgroup
+	st.appsCgroup = nil
+	st.lock.Unlock()
+	if cg == nil {
+		return
+	}
+	cg.dir.Close()
+	if cg.reclaim != nil {
+		cg.reclaim.Close()
+	}
+	defer cg.parent.Close()
+	err := unix.Unlinkat(int(cg.parent.Fd()), appsCgroupLeaf, unix.AT_REMOVEDIR)
+	if err == syscall.EBUSY {
+		st.log.Debug("Cgroup %s still has processes, leaving it to the daemon", cg.path)
+	} else if err != nil {
+		st.log.Warning("Unable to remove cgroup %s: %v", cg.path, err)
+	} else {
+		st.log.Info("Removed cgroup %s", cg.path)
+	}
+}
@@ -0,0 +1,79 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+// cgroup2Mount returns where the cgroup v2 hierarchy is mounted
+func cgroup2Mount() string {
+	data, err := ioutil.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 2 && fields[2] == "cgroup2" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+func TestIntoAppsCgroup(t *testing.T) {
+	mnt := cgroup2Mount()
+	if mnt == "" {
+		t.Skip("no cgroup v2 hierarchy")
+	}
+	base := path.Join(mnt, fmt.Sprintf("oz-init-test-%d", os.Getpid()))
+	apps := path.Join(base, appsCgroupLeaf)
+	if err := os.MkdirAll(apps, 0755); err != nil {
+		t.Skipf("unable to create a cgroup: %v", err)
+	}
+	defer os.Remove(base)
+	defer os.Remove(apps)
+	parent, err := os.Open(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.Open(apps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &initState{
+		log:        logging.MustGetLogger("oz-init-test"),
+		appsCgroup: &appsCgroup{path: apps, parent: parent, dir: dir},
+	}
+
+	cmd := exec.Command("sleep", "10")
+	st.intoAppsCgroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start a process into a cgroup: %v", err)
+	}
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", cmd.Process.Pid))
+	cmd.Process.Kill()
+	cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "0::" + strings.TrimPrefix(apps, mnt) + "\n"
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("expecting the process to start in %s, got:\n%s", apps, data)
+	}
+
+	st.removeAppsCgroup()
+	if _, err := os.Stat(apps); err == nil {
+		t.Error("expecting the cgroup of the applications to be removed")
+	}
+	cmd = exec.Command("true")
+	st.intoAppsCgroup(cmd)
+	if cmd.SysProcAttr != nil {
+		t.Error("expecting no cgroup once it was removed")
+	}
+}
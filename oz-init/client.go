@@ -3,6 +3,9 @@ package ozinit
 import (
 	"errors"
 	"fmt"
+	"io"
+
+	"github.com/subgraph/oz"
 	"github.com/subgraph/oz/ipc"
 )
 
@@ -41,18 +44,19 @@ func Ping(addr string) error {
 	}
 }
 
-func RunProgram(addr, cpath, pwd string, args []string) error {
+// RunProgram runs a program on display, the main display of the sandbox when 0
+func RunProgram(addr, cpath, pwd, term string, args []string, display int) error {
 	c, err := clientConnect(addr)
 	if err != nil {
 		return err
 	}
-	rr, err := c.ExchangeMsg(&RunProgramMsg{Path: cpath, Args: args, Pwd: pwd})
-	resp := <-rr.Chan()
-	rr.Done()
-	c.Close()
+	defer c.Close()
+	rr, err := c.ExchangeMsg(&RunProgramMsg{Path: cpath, Args: args, Pwd: pwd, Term: term, Display: display})
 	if err != nil {
 		return err
 	}
+	resp := <-rr.Chan()
+	rr.Done()
 	switch body := resp.Body.(type) {
 	case *ErrorMsg:
 		return errors.New(body.Msg)
@@ -63,18 +67,100 @@ func RunProgram(addr, cpath, pwd string, args []string) error {
 	}
 }
 
-func RunShell(addr, term string) (int, error) {
+// RunProgramWait runs a program on display and waits for it to exit, returning
+// its exit code or the negated number of the signal which killed it
+func RunProgramWait(addr, cpath, pwd, term string, args []string, display int) (int, error) {
 	c, err := clientConnect(addr)
 	if err != nil {
 		return 0, err
 	}
-	rr, err := c.ExchangeMsg(&RunShellMsg{Term: term})
+	defer c.Close()
+	rr, err := c.ExchangeMsg(&RunProgramMsg{Path: cpath, Args: args, Pwd: pwd, Term: term, Wait: true, Display: display})
+	if err != nil {
+		return 0, err
+	}
 	resp := <-rr.Chan()
 	rr.Done()
-	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return 0, errors.New(body.Msg)
+	case *ExitMsg:
+		return body.Code, nil
+	default:
+		return 0, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+// Exec runs a program without a pty and copies its combined output to out,
+// returning its exit code or the negated number of the signal which killed it
+func Exec(addr, cpath, pwd string, args []string, out io.Writer) (int, error) {
+	c, err := clientConnect(addr)
 	if err != nil {
 		return 0, err
 	}
+	defer c.Close()
+	rr, err := c.ExchangeMsg(&ExecMsg{Path: cpath, Args: args, Pwd: pwd})
+	if err != nil {
+		return 0, err
+	}
+	defer rr.Done()
+	for resp := range rr.Chan() {
+		switch body := resp.Body.(type) {
+		case *ExecOutputMsg:
+			out.Write(body.Data)
+		case *ExitMsg:
+			return body.Code, nil
+		case *ErrorMsg:
+			return 0, errors.New(body.Msg)
+		default:
+			return 0, fmt.Errorf("Unexpected message type received: %+v", body)
+		}
+	}
+	return 0, errors.New("Connection closed before the command exited")
+}
+
+// RunProgramPty runs a program attached to a new pty and returns its fd,
+// isolateNet runs it without access to the network of the sandbox and display
+// selects the display it uses, the main one when 0
+func RunProgramPty(addr, cpath, pwd, term string, args []string, isolateNet bool, display int) (int, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	rr, err := c.ExchangeMsg(&RunProgramMsg{Path: cpath, Args: args, Pwd: pwd, Term: term, Pty: true, IsolateNet: isolateNet, Display: display})
+	if err != nil {
+		return 0, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return 0, errors.New(body.Msg)
+	case *OkMsg:
+		if len(resp.Fds) == 0 {
+			return 0, errors.New("RunProgram message returned Ok, but no file descriptor received")
+		}
+		return resp.Fds[0], nil
+	default:
+		return 0, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+// RunShell opens a shell attached to a new pty and returns its fd, the shell
+// starts in pwd when it is set and exists inside the sandbox
+func RunShell(addr, term, pwd string) (int, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	rr, err := c.ExchangeMsg(&RunShellMsg{Term: term, Pwd: pwd})
+	if err != nil {
+		return 0, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
 	switch body := resp.Body.(type) {
 	case *ErrorMsg:
 		return 0, errors.New(body.Msg)
@@ -88,6 +174,127 @@ func RunShell(addr, term string) (int, error) {
 	}
 }
 
+func RunDebugger(addr string, pid int, term string) (int, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return 0, err
+	}
+	rr, err := c.ExchangeMsg(&RunDebuggerMsg{Pid: pid, Term: term})
+	if err != nil {
+		c.Close()
+		return 0, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return 0, errors.New(body.Msg)
+	case *OkMsg:
+		if len(resp.Fds) == 0 {
+			return 0, errors.New("RunDebugger message returned Ok, but no file descriptor received")
+		}
+		return resp.Fds[0], nil
+	default:
+		return 0, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+func GetInfo(addr string) (*InfoMsg, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := c.ExchangeMsg(&GetInfoMsg{})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	case *InfoMsg:
+		return body, nil
+	default:
+		return nil, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+func GetStatus(addr string) (*StatusMsg, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := c.ExchangeMsg(&GetStatusMsg{})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	case *StatusMsg:
+		return body, nil
+	default:
+		return nil, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+func DiskUsage(addr string) ([]MountUsage, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := c.ExchangeMsg(&DiskUsageMsg{})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	case *DiskUsageResp:
+		return body.Mounts, nil
+	default:
+		return nil, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
+func SubscribeOutput(addr string) (int, int, error) {
+	c, err := clientConnect(addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	rr, err := c.ExchangeMsg(&SubscribeOutputMsg{})
+	if err != nil {
+		c.Close()
+		return 0, 0, err
+	}
+	resp := <-rr.Chan()
+	rr.Done()
+	c.Close()
+	switch body := resp.Body.(type) {
+	case *ErrorMsg:
+		return 0, 0, errors.New(body.Msg)
+	case *OkMsg:
+		if len(resp.Fds) != 2 {
+			return 0, 0, errors.New("SubscribeOutput message returned Ok, but stdout and stderr file descriptors were not received")
+		}
+		return resp.Fds[0], resp.Fds[1], nil
+	default:
+		return 0, 0, fmt.Errorf("Unexpected message type received: %+v", body)
+	}
+}
+
 func SetupForwarder(addr, proto, daddr string, fd uintptr) error {
 	c, err := clientConnect(addr)
 	if err != nil {
@@ -108,3 +315,168 @@ func SetupForwarder(addr, proto, daddr string, fd uintptr) error {
 	}
 
 }
+
+func TrimMemory(addr string) (*TrimMemoryResp, error) {
+	resp, err := clientSend(addr, &TrimMemoryMsg{})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *TrimMemoryResp:
+		return body, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func GetRlimits(addr string, pid int) ([]ProcessRlimits, error) {
+	resp, err := clientSend(addr, &GetRlimitsMsg{Pid: pid})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *RlimitsResp:
+		return body.Processes, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func ListProcesses(addr string) ([]ProcessInfo, error) {
+	resp, err := clientSend(addr, &ListProcessesMsg{})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *ListProcessesResp:
+		return body.Processes, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func ListMounts(addr string) ([]MountEntry, error) {
+	resp, err := clientSend(addr, &ListMountsMsg{})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *ListMountsResp:
+		return body.Mounts, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func KillProcess(addr string, pid, sig int) error {
+	resp, err := clientSend(addr, &KillProcessMsg{Pid: pid, Signal: sig})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *OkMsg:
+		return nil
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	default:
+		return fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func SetRlimits(addr string, pid int, limits []Rlimit) ([]RlimitResult, error) {
+	resp, err := clientSend(addr, &SetRlimitsMsg{Pid: pid, Limits: limits})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *SetRlimitsResp:
+		return body.Results, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func SetGroups(addr string, groups map[string]uint32) error {
+	resp, err := clientSend(addr, &SetGroupsMsg{Groups: groups})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *OkMsg:
+		return nil
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	default:
+		return fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func ReloadSeccomp(addr string, conf oz.SeccompConf) error {
+	resp, err := clientSend(addr, &ReloadSeccompMsg{Seccomp: conf})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *OkMsg:
+		return nil
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	default:
+		return fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func AddWhitelist(addr, path string, readonly bool) error {
+	resp, err := clientSend(addr, &AddWhitelistMsg{Path: path, ReadOnly: readonly})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *OkMsg:
+		return nil
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	default:
+		return fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func AddWhitelistItems(addr string, items []oz.WhitelistItem) error {
+	resp, err := clientSend(addr, &AddWhitelistItemsMsg{Items: items})
+	if err != nil {
+		return err
+	}
+	switch body := resp.Body.(type) {
+	case *OkMsg:
+		return nil
+	case *ErrorMsg:
+		return errors.New(body.Msg)
+	default:
+		return fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
+
+func DebugDump(addr string) (*DebugDumpResp, error) {
+	resp, err := clientSend(addr, &DebugDumpMsg{})
+	if err != nil {
+		return nil, err
+	}
+	switch body := resp.Body.(type) {
+	case *DebugDumpResp:
+		return body, nil
+	case *ErrorMsg:
+		return nil, errors.New(body.Msg)
+	default:
+		return nil, fmt.Errorf("Unexpected message received: %+v", body)
+	}
+}
@@ -0,0 +1,46 @@
+package ozinit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/subgraph/oz/ipc"
+)
+
+func TestRunProgramWait(t *testing.T) {
+	const addr = "@oz-init-test-run-wait"
+	exited := make(chan struct{})
+	handler := func(rp *RunProgramMsg, msg *ipc.Message) error {
+		if !rp.Wait {
+			return msg.Respond(&ErrorMsg{"expecting the program to be waited for"})
+		}
+		if rp.Path == "/bin/false" {
+			return msg.Respond(&ErrorMsg{"unable to launch " + rp.Path})
+		}
+		// The exit status is sent once the program exited, like handleRunProgram
+		msg.Detach()
+		go func() {
+			<-exited
+			msg.Respond(&ExitMsg{Code: 3})
+		}()
+		return nil
+	}
+	s, err := ipc.NewServer(addr, messageFactory, nil, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	time.AfterFunc(50*time.Millisecond, func() { close(exited) })
+	code, err := RunProgramWait(addr, "/bin/true", "/", "", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Errorf("expecting exit code 3, got %d", code)
+	}
+	if _, err := RunProgramWait(addr, "/bin/false", "/", "", nil, 0); err == nil {
+		t.Error("expecting the launch error to be returned")
+	}
+}
@@ -0,0 +1,21 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// allowedCpus returns the cpus oz-init may run on, the effective cpuset of its
+// cgroup intersected with its affinity, as inherited by the applications.
+func allowedCpus() string {
+	data, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "Cpus_allowed_list:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Cpus_allowed_list:"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package ozinit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Bytes of recent application output kept for the crash diagnostics
+	crashOutputMax = 64 * 1024
+	// Crash diagnostics kept in the log directory, older ones are removed
+	crashBundlesKept  = 10
+	crashBundlePrefix = "crash-"
+)
+
+// outputTail keeps the most recent lines of output of the applications
+type outputTail struct {
+	lock  sync.Mutex
+	lines []string
+	size  int
+}
+
+func (t *outputTail) add(label, line string) {
+	prefix := fmt.Sprintf("(%s) ", label)
+	if max := crashOutputMax - len(prefix); len(line) > max {
+		line = line[len(line)-max:]
+	}
+	line = prefix + line
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.lines = append(t.lines, line)
+	t.size += len(line)
+	for t.size > crashOutputMax {
+		t.size -= len(t.lines[0])
+		t.lines = t.lines[1:]
+	}
+}
+
+func (t *outputTail) snapshot() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]string{}, t.lines...)
+}
+
+// describeWaitStatus returns how a process ended, in the words of a shell
+func describeWaitStatus(wstatus syscall.WaitStatus) string {
+	if wstatus.Signaled() {
+		desc := fmt.Sprintf("killed by signal %d (%v)", wstatus.Signal(), wstatus.Signal())
+		if wstatus.CoreDump() {
+			desc += ", core dumped"
+		}
+		return desc
+	}
+	return fmt.Sprintf("exited with status %d", wstatus.ExitStatus())
+}
+
+// writeCrashDiagnostics writes the context of an application killed by a
+// signal to the log directory of the profile: its exit status, command line,
+// environment and the recent output of the applications.
+func (st *initState) writeCrashDiagnostics(proc procState, wstatus syscall.WaitStatus) {
+	dir := st.profile.LogDir
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		st.log.Warning("Unable to create log directory for crash diagnostics: %v", err)
+		return
+	}
+	now := time.Now()
+	pid := proc.cmd.Process.Pid
+	name := fmt.Sprintf("%s%s-%d.txt", crashBundlePrefix, now.UTC().Format("20060102T150405.000000000"), pid)
+	p := path.Join(dir, name)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Profile: %s\n", st.profile.Name)
+	fmt.Fprintf(&b, "Pid: %d\n", pid)
+	fmt.Fprintf(&b, "Command: %s\n", strings.Join(proc.cmd.Args, " "))
+	fmt.Fprintf(&b, "Status: %s\n", describeWaitStatus(wstatus))
+	b.WriteString("\nEnvironment:\n")
+	for _, e := range proc.cmd.Env {
+		fmt.Fprintf(&b, "  %s\n", e)
+	}
+	b.WriteString("\nRecent output:\n")
+	for _, line := range st.outputTail.snapshot() {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+
+	// The directory may be writable by the sandbox user, never follow a link
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		st.log.Warning("Unable to write crash diagnostics: %v", err)
+		return
+	}
+	_, err = f.WriteString(b.String())
+	if err == nil {
+		err = f.Chown(int(st.uid), int(st.gid))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		st.log.Warning("Unable to write crash diagnostics to %s: %v", p, err)
+		return
+	}
+	st.log.Notice("Application pid=%d %s, crash diagnostics written to %s", pid, describeWaitStatus(wstatus), p)
+	st.rotateCrashBundles(dir)
+}
+
+// rotateCrashBundles removes the oldest crash diagnostics past crashBundlesKept,
+// the names sort in the order they were written.
+func (st *initState) rotateCrashBundles(dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	bundles := []string{}
+	for _, e := range entries {
+		if e.Mode().IsRegular() && strings.HasPrefix(e.Name(), crashBundlePrefix) {
+			bundles = append(bundles, e.Name())
+		}
+	}
+	sort.Strings(bundles)
+	for len(bundles) > crashBundlesKept {
+		if err := os.Remove(path.Join(dir, bundles[0])); err != nil {
+			st.log.Warning("Unable to remove old crash diagnostics: %v", err)
+		}
+		bundles = bundles[1:]
+	}
+}
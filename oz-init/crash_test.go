@@ -0,0 +1,78 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestOutputTail(t *testing.T) {
+	tail := &outputTail{}
+	line := strings.Repeat("x", 1000)
+	for i := 0; i < 200; i++ {
+		tail.add("stdout", line)
+	}
+	lines := tail.snapshot()
+	if tail.size > crashOutputMax {
+		t.Errorf("expecting at most %d bytes kept, got %d", crashOutputMax, tail.size)
+	}
+	if len(lines) == 0 || len(lines) == 200 {
+		t.Errorf("expecting the oldest lines to be dropped, got %d lines", len(lines))
+	}
+	tail.add("stderr", strings.Repeat("y", 2*crashOutputMax))
+	if lines := tail.snapshot(); len(lines) != 1 || tail.size > crashOutputMax {
+		t.Errorf("expecting a single truncated line, got %d lines of %d bytes", len(lines), tail.size)
+	}
+}
+
+func TestWriteCrashDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-crash-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{Name: "test", LogDir: dir, CaptureCrashDiagnostics: true},
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	st.captureLine(&captureLimit{}, "stderr", "about to crash")
+	cmd := &exec.Cmd{
+		Args:    []string{"/usr/bin/app", "--flag"},
+		Env:     []string{"HOME=/home/user"},
+		Process: &os.Process{Pid: 1234},
+	}
+	for i := 0; i < crashBundlesKept+2; i++ {
+		st.writeCrashDiagnostics(procState{cmd: cmd, track: true}, syscall.WaitStatus(syscall.SIGSEGV))
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != crashBundlesKept {
+		t.Fatalf("expecting %d crash diagnostics kept, got %d", crashBundlesKept, len(entries))
+	}
+	data, err := ioutil.ReadFile(path.Join(dir, entries[len(entries)-1].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Command: /usr/bin/app --flag",
+		"Status: killed by signal 11 (segmentation fault)",
+		"HOME=/home/user",
+		"(stderr) about to crash",
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expecting crash diagnostics to contain %q:\n%s", expected, data)
+		}
+	}
+}
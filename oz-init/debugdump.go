@@ -0,0 +1,89 @@
+package ozinit
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz/ipc"
+)
+
+const (
+	// Number of recent log lines of oz-init kept for debug dumps
+	debugLogLinesKept = 200
+	// Largest goroutine dump returned, the stacks are truncated beyond it
+	debugStacksMax = 1 << 20
+)
+
+// logTail is a logging backend keeping the most recent log lines of oz-init
+type logTail struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (lt *logTail) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	line := fmt.Sprintf("%s %.1s %s", rec.Time.Format("15:04:05.000"), level, rec.Message())
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	lt.lines = append(lt.lines, line)
+	if len(lt.lines) > debugLogLinesKept {
+		lt.lines = lt.lines[len(lt.lines)-debugLogLinesKept:]
+	}
+	return nil
+}
+
+func (lt *logTail) list() []string {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	return append([]string{}, lt.lines...)
+}
+
+// goroutineStacks returns the stacks of every goroutine of oz-init
+func goroutineStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= debugStacksMax {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// debugProcesses lists the children of oz-init in launch order, shells are
+// the untracked children running the shell of the oz config.
+func (st *initState) debugProcesses() []DebugProcess {
+	procs := []DebugProcess{}
+	for _, c := range st.childrenVector() {
+		procs = append(procs, DebugProcess{
+			Pid:     c.cmd.Process.Pid,
+			Path:    c.cmd.Path,
+			Args:    append([]string{}, c.cmd.Args...),
+			Tracked: c.track,
+			Shell:   !c.track && c.cmd.Path == st.config.ShellPath,
+			Seq:     c.seq,
+		})
+	}
+	return procs
+}
+
+// handleDebugDump returns the internal state of oz-init to diagnose it when it
+// hangs. It is only available to root when enable_debug_dump is set.
+func (st *initState) handleDebugDump(dd *DebugDumpMsg, msg *ipc.Message) error {
+	if !st.config.EnableDebugDump {
+		return msg.Respond(&ErrorMsg{"Debug dumps are disabled, enable_debug_dump is not set in the oz config"})
+	}
+	if msg.Ucred == nil || msg.Ucred.Uid != 0 {
+		return msg.Respond(&ErrorMsg{"Only root may request a debug dump"})
+	}
+	st.log.Notice("Dumping the internal state of oz-init")
+	resp := &DebugDumpResp{
+		Goroutines: goroutineStacks(),
+		Processes:  st.debugProcesses(),
+	}
+	if st.recentLog != nil {
+		resp.Log = st.recentLog.list()
+	}
+	return msg.Respond(resp)
+}
@@ -0,0 +1,34 @@
+package ozinit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestLogTail(t *testing.T) {
+	tail := &logTail{}
+	logging.SetBackend(tail)
+	log := logging.MustGetLogger("oz-init-test")
+	for i := 0; i < debugLogLinesKept+10; i++ {
+		log.Info("line %d", i)
+	}
+	lines := tail.list()
+	if len(lines) != debugLogLinesKept {
+		t.Fatalf("expecting %d lines kept, got %d", debugLogLinesKept, len(lines))
+	}
+	if last := fmt.Sprintf("I line %d", debugLogLinesKept+9); !strings.HasSuffix(lines[len(lines)-1], last) {
+		t.Errorf("expecting the last line to end with %q, got %q", last, lines[len(lines)-1])
+	}
+	if first := "I line 10"; !strings.HasSuffix(lines[0], first) {
+		t.Errorf("expecting the oldest lines to be dropped, got %q", lines[0])
+	}
+}
+
+func TestGoroutineStacks(t *testing.T) {
+	if s := goroutineStacks(); !strings.Contains(s, "TestGoroutineStacks") {
+		t.Error("expecting the goroutine dump to contain the stack of the test")
+	}
+}
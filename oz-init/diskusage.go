@@ -0,0 +1,48 @@
+package ozinit
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/subgraph/oz/ipc"
+)
+
+// Writable areas of the sandbox reported by DiskUsage. Only statfs is used
+// so the cost does not depend on how many files the sandbox has written.
+func (st *initState) diskUsagePaths() []string {
+	ps := []string{"/tmp", "/dev/shm"}
+	if hasHomeDir(st.user) {
+		ps = append(ps, st.user.HomeDir)
+	}
+	return ps
+}
+
+func mountUsage(p string) (*MountUsage, error) {
+	var sfs syscall.Statfs_t
+	if err := syscall.Statfs(p, &sfs); err != nil {
+		return nil, err
+	}
+	bsize := uint64(sfs.Bsize)
+	return &MountUsage{
+		Path:  p,
+		Size:  sfs.Blocks * bsize,
+		Used:  (sfs.Blocks - sfs.Bfree) * bsize,
+		Avail: sfs.Bavail * bsize,
+	}, nil
+}
+
+func (st *initState) handleDiskUsage(du *DiskUsageMsg, msg *ipc.Message) error {
+	resp := &DiskUsageResp{}
+	for _, p := range st.diskUsagePaths() {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		mu, err := mountUsage(p)
+		if err != nil {
+			st.log.Warning("Unable to read disk usage of %s: %v", p, err)
+			continue
+		}
+		resp.Mounts = append(resp.Mounts, *mu)
+	}
+	return msg.Respond(resp)
+}
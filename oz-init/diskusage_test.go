@@ -0,0 +1,41 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"testing"
+)
+
+func TestDiskUsagePaths(t *testing.T) {
+	st := &initState{}
+	if ps := st.diskUsagePaths(); len(ps) != 2 || ps[0] != "/tmp" || ps[1] != "/dev/shm" {
+		t.Errorf("expecting only /tmp and /dev/shm without a user, got %v", ps)
+	}
+	st.user = &user.User{HomeDir: "/home/user"}
+	if ps := st.diskUsagePaths(); len(ps) != 3 || ps[2] != "/home/user" {
+		t.Errorf("expecting the home directory to be reported, got %v", ps)
+	}
+}
+
+func TestMountUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-du-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mu, err := mountUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mu.Path != dir || mu.Size == 0 {
+		t.Errorf("expecting the size of the mount holding %s, got %+v", dir, mu)
+	}
+	if mu.Used > mu.Size || mu.Avail > mu.Size {
+		t.Errorf("expecting used and available space within the size, got %+v", mu)
+	}
+	if _, err := mountUsage(path.Join(dir, "missing")); err == nil {
+		t.Error("expecting an error for a missing path")
+	}
+}
@@ -0,0 +1,34 @@
+package ozinit
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+)
+
+// Number of random bytes mixed into the random pool when seed_entropy is set
+const entropySeedSize = 512
+
+// seedEntropy mixes fresh random bytes from the host into /dev/urandom so
+// that applications started early in the sandbox do not depend on whatever
+// state the pool had. The kernel pool is shared with the host, the write
+// only adds to it and never credits entropy or makes the output predictable.
+func (st *initState) seedEntropy() error {
+	if !st.profile.SeedEntropy {
+		return nil
+	}
+	seed := make([]byte, entropySeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return fmt.Errorf("unable to read random bytes: %v", err)
+	}
+	f, err := os.OpenFile("/dev/urandom", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(seed); err != nil {
+		return fmt.Errorf("unable to write to /dev/urandom: %v", err)
+	}
+	st.log.Info("Seeded /dev/urandom with %d bytes of fresh entropy", entropySeedSize)
+	return nil
+}
@@ -0,0 +1,52 @@
+package ozinit
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/subgraph/oz"
+)
+
+// envVars returns the values of the variables of the profile, those without a
+// value are copied from inherited when it has them.
+func envVars(vars []oz.EnvVar, inherited []string) map[string]string {
+	values := make(map[string]string)
+	for _, v := range vars {
+		if v.Name == "" {
+			continue
+		}
+		if v.Value != "" {
+			values[v.Name] = v.Value
+			continue
+		}
+		for _, e := range inherited {
+			if strings.HasPrefix(e, v.Name+"=") {
+				values[v.Name] = e[len(v.Name)+1:]
+			}
+		}
+	}
+	return values
+}
+
+// overrideEnv returns env with the variables of vars appended in the order of
+// their names, any variable of env with the same name is removed.
+func overrideEnv(env []string, vars map[string]string) []string {
+	if len(vars) == 0 {
+		return env
+	}
+	result := []string{}
+	for _, e := range env {
+		if _, ok := vars[strings.SplitN(e, "=", 2)[0]]; !ok {
+			result = append(result, e)
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result, name+"="+vars[name])
+	}
+	return result
+}
@@ -0,0 +1,29 @@
+package ozinit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestOverrideEnv(t *testing.T) {
+	env := []string{"LANG=C", "HOME=/home/user", "LANGUAGE=en"}
+	vars := map[string]string{"LANG": "fr_FR.UTF-8", "GDK_BACKEND": "x11"}
+	expected := []string{"HOME=/home/user", "LANGUAGE=en", "GDK_BACKEND=x11", "LANG=fr_FR.UTF-8"}
+	if got := overrideEnv(env, vars); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expecting %v, got %v", expected, got)
+	}
+	if got := overrideEnv(env, nil); !reflect.DeepEqual(got, env) {
+		t.Errorf("expecting the environment unchanged without variables, got %v", got)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	vars := []oz.EnvVar{{Name: "LANG", Value: "fr_FR.UTF-8"}, {Name: "TZ"}, {Name: "MISSING"}, {Value: "ignored"}}
+	inherited := []string{"LANG=C", "TZ=UTC", "TZDIR=/usr/share/zoneinfo"}
+	expected := map[string]string{"LANG": "fr_FR.UTF-8", "TZ": "UTC"}
+	if got := envVars(vars, inherited); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expecting %v, got %v", expected, got)
+	}
+}
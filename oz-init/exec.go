@@ -0,0 +1,93 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
+
+const (
+	execOutputChunk = 4096
+	// Time the output of an exited command is still read, a process it left
+	// running in the background may keep the pipe open
+	execOutputDrain = time.Second
+)
+
+// handleExec runs a program as the sandbox user with its stdout and stderr
+// relayed to the client, the response is completed once the reaper collected it.
+func (st *initState) handleExec(em *ExecMsg, msg *ipc.Message) error {
+	st.log.Info("Exec message received: %+v", em)
+	if !st.programAllowed(em.Path) {
+		st.log.Warning("Rejecting exec request for %s, it is not allowed by the profile", em.Path)
+		return msg.Respond(&ErrorMsg{Msg: fmt.Sprintf("Program (%s) is not allowed in this sandbox", em.Path)})
+	}
+	if !st.runLimiter.allow(time.Now()) {
+		st.log.Warning("Rejecting exec request, rate limit of %v per second exceeded", st.config.RunProgramRateLimit)
+		return msg.Respond(&ErrorMsg{Msg: "Program launch rate limit exceeded, try again later"})
+	}
+	switch st.profile.Seccomp.Mode {
+	case oz.PROFILE_SECCOMP_TRAIN, oz.PROFILE_SECCOMP_WHITELIST, oz.PROFILE_SECCOMP_BLACKLIST:
+		// The command would not be confined by the seccomp policy of the profile
+		return msg.Respond(&ErrorMsg{Msg: "Cannot execute a command in a sandbox using seccomp"})
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return msg.Respond(&ErrorMsg{Msg: err.Error()})
+	}
+	cpath, args := em.Path, em.Args
+	if limits := st.applicationRlimits(); len(limits) > 0 {
+		cpath, args = st.rlimitCommand(cpath, "", args, limits)
+	}
+	cmd := exec.Command(cpath, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	st.setupApplicationCommand(cmd, em.Pwd, "")
+	st.cancelIdleTimer()
+	st.intoAppsCgroup(cmd)
+	exited, err := st.exits.startWith(cmd, func(c *exec.Cmd) error {
+		return st.startRestricted(c, false)
+	})
+	w.Close()
+	if err != nil {
+		r.Close()
+		return msg.Respond(&ErrorMsg{Msg: fmt.Sprintf("Failed to execute %s: %v", em.Path, err)})
+	}
+	st.addChildProcess(cmd, false)
+	// Messages are dispatched one at a time, the output is relayed from
+	// another goroutine
+	msg.Detach()
+	go st.relayExecOutput(r, exited, msg)
+	return nil
+}
+
+func (st *initState) relayExecOutput(r *os.File, exited <-chan syscall.WaitStatus, msg *ipc.Message) {
+	defer r.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, execOutputChunk)
+		var sendErr error
+		for {
+			n, err := r.Read(buf)
+			// The output is still drained once the client is gone, the
+			// command would otherwise block writing to a full pipe
+			if n > 0 && sendErr == nil {
+				sendErr = msg.Respond(&ExecOutputMsg{Data: append([]byte{}, buf[:n]...)})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	wstatus := <-exited
+	r.SetReadDeadline(time.Now().Add(execOutputDrain))
+	<-done
+	if err := msg.Respond(&ExitMsg{Code: exitCode(wstatus)}); err != nil {
+		st.log.Warning("Unable to send the exit status of a command: %v", err)
+	}
+}
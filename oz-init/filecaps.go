@@ -0,0 +1,182 @@
+package ozinit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/subgraph/oz/fs"
+)
+
+// Directory holding the copies of the binaries given file capabilities.
+// It is a separate tmpfs because the rootfs is mounted nosuid, which also
+// makes the kernel ignore file capabilities.
+const fileCapsDir = "/run/oz-filecaps"
+
+const (
+	vfsCapRevision2      = 0x02000000
+	vfsCapFlagsEffective = 0x000001
+)
+
+var capabilityNames = map[string]uint{
+	"cap_chown":              0,
+	"cap_dac_override":       1,
+	"cap_dac_read_search":    2,
+	"cap_fowner":             3,
+	"cap_fsetid":             4,
+	"cap_kill":               5,
+	"cap_setgid":             6,
+	"cap_setuid":             7,
+	"cap_setpcap":            8,
+	"cap_linux_immutable":    9,
+	"cap_net_bind_service":   10,
+	"cap_net_broadcast":      11,
+	"cap_net_admin":          12,
+	"cap_net_raw":            13,
+	"cap_ipc_lock":           14,
+	"cap_ipc_owner":          15,
+	"cap_sys_module":         16,
+	"cap_sys_rawio":          17,
+	"cap_sys_chroot":         18,
+	"cap_sys_ptrace":         19,
+	"cap_sys_pacct":          20,
+	"cap_sys_admin":          21,
+	"cap_sys_boot":           22,
+	"cap_sys_nice":           23,
+	"cap_sys_resource":       24,
+	"cap_sys_time":           25,
+	"cap_sys_tty_config":     26,
+	"cap_mknod":              27,
+	"cap_lease":              28,
+	"cap_audit_write":        29,
+	"cap_audit_control":      30,
+	"cap_setfcap":            31,
+	"cap_mac_override":       32,
+	"cap_mac_admin":          33,
+	"cap_syslog":             34,
+	"cap_wake_alarm":         35,
+	"cap_block_suspend":      36,
+	"cap_audit_read":         37,
+	"cap_perfmon":            38,
+	"cap_bpf":                39,
+	"cap_checkpoint_restore": 40,
+}
+
+// fileCapabilityData encodes caps as a security.capability extended attribute
+// with the capabilities permitted and effective.
+func fileCapabilityData(caps []string) ([]byte, error) {
+	var permitted [2]uint32
+	for _, c := range caps {
+		n, ok := capabilityNames[strings.ToLower(c)]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability (%s)", c)
+		}
+		permitted[n/32] |= 1 << (n % 32)
+	}
+	data := make([]byte, 20)
+	binary.LittleEndian.PutUint32(data[0:], vfsCapRevision2|vfsCapFlagsEffective)
+	binary.LittleEndian.PutUint32(data[4:], permitted[0])
+	binary.LittleEndian.PutUint32(data[12:], permitted[1])
+	return data, nil
+}
+
+func copyBinary(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// setupFileCapabilities gives the binaries listed in the profile their file
+// capabilities. Each binary is copied so the host file is never modified and
+// the copy is bound read-only over the binary inside the sandbox.
+func (st *initState) setupFileCapabilities(fsys *fs.Filesystem) error {
+	if len(st.profile.FileCapabilities) == 0 {
+		return nil
+	}
+	bins := make([]string, 0, len(st.profile.FileCapabilities))
+	for bin := range st.profile.FileCapabilities {
+		bins = append(bins, bin)
+	}
+	sort.Strings(bins)
+
+	capsdir := path.Join(fsys.Root(), fileCapsDir)
+	if err := os.MkdirAll(capsdir, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("", capsdir, "tmpfs", syscall.MS_NODEV, "mode=755"); err != nil {
+		return fmt.Errorf("failed to mount tmpfs on '%s': %v", capsdir, err)
+	}
+
+	for i, bin := range bins {
+		caps := st.profile.FileCapabilities[bin]
+		data, err := fileCapabilityData(caps)
+		if err != nil {
+			return fmt.Errorf("file capabilities of %s: %v", bin, err)
+		}
+		if !path.IsAbs(bin) {
+			return fmt.Errorf("file capabilities binary path (%s) must be absolute", bin)
+		}
+		resolved, err := filepath.EvalSymlinks(bin)
+		if err != nil {
+			return fmt.Errorf("unable to resolve binary (%s) for file capabilities: %v", bin, err)
+		}
+		target := path.Join(fsys.Root(), resolved)
+		if fi, err := os.Stat(target); err != nil || !fi.Mode().IsRegular() {
+			return fmt.Errorf("binary (%s) for file capabilities does not exist inside the sandbox", bin)
+		}
+		cpath := path.Join(capsdir, fmt.Sprintf("%d-%s", i, path.Base(resolved)))
+		if err := copyBinary(target, cpath); err != nil {
+			return fmt.Errorf("failed to copy %s for file capabilities: %v", bin, err)
+		}
+		// Keep the labels of the binary, its capability attribute is replaced below
+		if err := fs.CopyXattrs(target, cpath); err != nil {
+			st.log.Warning("%v", err)
+		}
+		if err := syscall.Setxattr(cpath, "security.capability", data, 0); err != nil {
+			return fmt.Errorf("failed to set file capabilities on %s: %v", bin, err)
+		}
+		if err := syscall.Mount(cpath, target, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to bind %s with file capabilities: %v", bin, err)
+		}
+		roflags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NODEV)
+		if err := syscall.Mount("", target, "", roflags, ""); err != nil {
+			return fmt.Errorf("failed to remount %s read-only: %v", bin, err)
+		}
+		st.log.Info("File capabilities set on %s: %s", bin, strings.Join(caps, ","))
+		st.lock.Lock()
+		if st.grantedCaps == nil {
+			st.grantedCaps = make(map[string]bool)
+		}
+		for _, c := range caps {
+			st.grantedCaps[strings.ToLower(c)] = true
+		}
+		st.lock.Unlock()
+	}
+
+	roflags := uintptr(syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NODEV)
+	if err := syscall.Mount("", capsdir, "tmpfs", roflags, "mode=755"); err != nil {
+		return fmt.Errorf("failed to remount '%s' read-only: %v", capsdir, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package ozinit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/fs"
+)
+
+func TestFileCapabilityData(t *testing.T) {
+	for _, test := range []struct {
+		caps []string
+		low  uint32
+		high uint32
+	}{
+		{[]string{"cap_net_raw"}, 1 << 13, 0},
+		{[]string{"CAP_NET_BIND_SERVICE", "cap_net_admin"}, 1<<10 | 1<<12, 0},
+		{[]string{"cap_syslog", "cap_chown"}, 1, 1 << 2},
+	} {
+		data, err := fileCapabilityData(test.caps)
+		if err != nil {
+			t.Errorf("unexpected error encoding %v: %v", test.caps, err)
+			continue
+		}
+		if len(data) != 20 {
+			t.Errorf("expecting a revision 2 attribute of 20 bytes, got %d", len(data))
+			continue
+		}
+		if v := binary.LittleEndian.Uint32(data[0:]); v != vfsCapRevision2|vfsCapFlagsEffective {
+			t.Errorf("unexpected magic %x for %v", v, test.caps)
+		}
+		low, high := binary.LittleEndian.Uint32(data[4:]), binary.LittleEndian.Uint32(data[12:])
+		if low != test.low || high != test.high {
+			t.Errorf("expecting permitted %x/%x for %v, got %x/%x", test.low, test.high, test.caps, low, high)
+		}
+	}
+	if _, err := fileCapabilityData([]string{"cap_everything"}); err == nil {
+		t.Error("expecting an unknown capability to be rejected")
+	}
+}
+
+// inMountNamespace runs fn on a thread with a private mount namespace which
+// is discarded afterwards. fn must not call t.Fatal.
+func inMountNamespace(t *testing.T, fn func()) {
+	if os.Getuid() != 0 {
+		t.Skip("mount tests must run as root")
+	}
+	setup := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		if err := syscall.Unshare(syscall.CLONE_NEWNS); err != nil {
+			setup <- err
+			return
+		}
+		if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+			setup <- err
+			return
+		}
+		setup <- nil
+		fn()
+	}()
+	if err := <-setup; err != nil {
+		t.Skipf("unable to create private mount namespace: %v", err)
+	}
+	<-done
+}
+
+func TestSetupFileCapabilities(t *testing.T) {
+	inMountNamespace(t, func() {
+		base, err := ioutil.TempDir("", "oz-filecaps-test")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer os.RemoveAll(base)
+		if err := syscall.Mount("", base, "tmpfs", 0, "mode=755"); err != nil {
+			t.Error(err)
+			return
+		}
+		defer syscall.Unmount(base, syscall.MNT_DETACH)
+
+		bin := path.Join(base, "host", "tool")
+		fsys := fs.NewFilesystem(&oz.Config{SandboxPath: base}, logging.MustGetLogger("oz-init-test"), nil, nil)
+		target := path.Join(fsys.Root(), bin)
+		for _, p := range []string{bin, target} {
+			if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"), 0755); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		st := &initState{
+			log:     logging.MustGetLogger("oz-init-test"),
+			profile: &oz.Profile{FileCapabilities: map[string][]string{bin: {"cap_net_raw"}}},
+		}
+		if err := st.setupFileCapabilities(fsys); err != nil {
+			t.Error(err)
+			return
+		}
+		expected, _ := fileCapabilityData([]string{"cap_net_raw"})
+		data := make([]byte, 64)
+		n, err := syscall.Getxattr(target, "security.capability", data)
+		if err != nil || !bytes.Equal(data[:n], expected) {
+			t.Errorf("expecting the file capabilities on the sandbox binary, got %x %v", data[:n], err)
+		}
+		if !reflect.DeepEqual(st.grantedCaps, map[string]bool{"cap_net_raw": true}) {
+			t.Errorf("expecting cap_net_raw to be recorded as granted, got %v", st.grantedCaps)
+		}
+		if _, err := syscall.Getxattr(bin, "security.capability", data); err == nil {
+			t.Error("expecting the host binary to be left without file capabilities")
+		}
+		if err := ioutil.WriteFile(target, []byte("x"), 0755); err == nil {
+			t.Error("expecting the binary with file capabilities to be read-only")
+		}
+	})
+}
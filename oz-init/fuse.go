@@ -0,0 +1,31 @@
+package ozinit
+
+import (
+	"os"
+	"syscall"
+)
+
+var fuseDevice = fsDeviceDefinition{path: "/dev/fuse", mode: syscall.S_IFCHR | ugorw, dev: _makedev(10, 229)}
+
+var fusermountPaths = []string{
+	"/bin/fusermount3", "/usr/bin/fusermount3",
+	"/bin/fusermount", "/usr/bin/fusermount",
+}
+
+// setupFuseCapabilities gives fusermount the capability to mount, the
+// sandbox filesystem is mounted nosuid so its setuid bit has no effect.
+func (st *initState) setupFuseCapabilities() {
+	for _, p := range fusermountPaths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if st.profile.FileCapabilities == nil {
+			st.profile.FileCapabilities = make(map[string][]string)
+		}
+		if _, ok := st.profile.FileCapabilities[p]; !ok {
+			st.profile.FileCapabilities[p] = []string{"cap_sys_admin"}
+		}
+		return
+	}
+	st.log.Warning("FUSE is allowed but no fusermount binary was found")
+}
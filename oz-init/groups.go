@@ -0,0 +1,54 @@
+package ozinit
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/subgraph/oz/ipc"
+)
+
+// userGroups returns the primary gid followed by the supplementary groups
+// given to processes of the sandbox user. The supplementary groups may be
+// replaced with SetGroups while the sandbox runs.
+// The slice is empty but never nil with no_supplementary_groups, so the
+// credential switch calls setgroups(0) and drops the groups of oz-init.
+func (st *initState) userGroups() []uint32 {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.profile != nil && st.profile.NoSupplementaryGroups {
+		return []uint32{}
+	}
+	groups := append([]uint32{}, st.gid)
+	for _, gid := range st.gids {
+		groups = append(groups, gid)
+	}
+	return groups
+}
+
+func (st *initState) groupGid(name string) (uint32, bool) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	gid, ok := st.gids[name]
+	return gid, ok
+}
+
+// handleSetGroups replaces the supplementary groups of the processes launched
+// afterwards, the kernel does not allow changing the groups of a running
+// process so those keep the groups they were started with.
+func (st *initState) handleSetGroups(sg *SetGroupsMsg, msg *ipc.Message) error {
+	if msg.Ucred == nil || msg.Ucred.Uid != 0 {
+		return msg.Respond(&ErrorMsg{"Only root may change the groups of the sandbox"})
+	}
+	gids := make(map[string]uint32, len(sg.Groups))
+	names := make([]string, 0, len(sg.Groups))
+	for name, gid := range sg.Groups {
+		gids[name] = gid
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	st.lock.Lock()
+	st.gids = gids
+	st.lock.Unlock()
+	st.log.Info("Supplementary groups of new processes set to: %s", strings.Join(names, ","))
+	return msg.Respond(&OkMsg{})
+}
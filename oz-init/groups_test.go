@@ -0,0 +1,71 @@
+package ozinit
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestUserGroups(t *testing.T) {
+	st := &initState{gid: 1000, gids: map[string]uint32{"audio": 29, "video": 44}}
+	groups := st.userGroups()
+	if len(groups) != 3 || groups[0] != 1000 {
+		t.Fatalf("expecting the primary gid followed by 2 groups, got %v", groups)
+	}
+	sort.Sort(gidList(groups[1:]))
+	if groups[1] != 29 || groups[2] != 44 {
+		t.Errorf("expecting supplementary groups 29 and 44, got %v", groups[1:])
+	}
+
+	st.gids = map[string]uint32{"plugdev": 46}
+	if gid, ok := st.groupGid("plugdev"); !ok || gid != 46 {
+		t.Errorf("expecting plugdev (46) after replacing the groups, got %d %v", gid, ok)
+	}
+	if _, ok := st.groupGid("audio"); ok {
+		t.Error("expecting audio to be dropped after replacing the groups")
+	}
+}
+
+type gidList []uint32
+
+func (l gidList) Len() int           { return len(l) }
+func (l gidList) Less(i, j int) bool { return l[i] < l[j] }
+func (l gidList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+func runGroups(t *testing.T, groups []uint32) string {
+	cmd := exec.Command("id", "-G")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 65534, Gid: 65534, Groups: groups}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("unable to run id: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestNoSupplementaryGroups(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("credential tests must run as root")
+	}
+	st := &initState{
+		profile: &oz.Profile{},
+		gid:     65534,
+		gids:    map[string]uint32{"audio": 29},
+	}
+	if out := runGroups(t, st.userGroups()); out != "65534 29" {
+		t.Errorf("expecting groups 65534 29, got %s", out)
+	}
+	st.profile.NoSupplementaryGroups = true
+	groups := st.userGroups()
+	if groups == nil || len(groups) != 0 {
+		t.Fatalf("expecting an empty non-nil groups slice, got %#v", groups)
+	}
+	if out := runGroups(t, groups); out != "65534" {
+		t.Errorf("expecting only the primary group 65534, got %s", out)
+	}
+}
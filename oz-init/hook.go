@@ -0,0 +1,41 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// runPostSetupHook runs the post setup hook of the profile as the sandbox user
+// and waits for it to exit. It runs before the reaper is started, so the
+// child is collected with exec.Cmd.Wait rather than through st.exits.
+func (st *initState) runPostSetupHook() error {
+	hook := st.profile.PostSetupHook
+	out, err := ioutil.TempFile("", "oz-post-setup-hook")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	cmd := exec.Command(hook)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	st.setupApplicationCommand(cmd, "", "")
+	st.log.Info("Running post setup hook (%s)", hook)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cmd.Wait()
+	wstatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if wstatus.Exited() && wstatus.ExitStatus() == 0 {
+		return nil
+	}
+	result := describeWaitStatus(wstatus)
+	if output := probeOutput(out); output != "" {
+		return fmt.Errorf("%s: %s", result, output)
+	}
+	return fmt.Errorf("%s", result)
+}
@@ -0,0 +1,47 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestRunPostSetupHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-hook-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hook := path.Join(dir, "hook.sh")
+	marker := path.Join(dir, "marker")
+	script := "#!/bin/sh\nif [ -n \"$FAIL\" ]; then echo \"cannot create\"; exit 2; fi\ntouch " + marker + "\n"
+	if err := ioutil.WriteFile(hook, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{PostSetupHook: hook, NoSupplementaryGroups: true},
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+	}
+	if err := st.runPostSetupHook(); err != nil {
+		t.Fatalf("expecting the hook to succeed, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("expecting the hook to have run: %v", err)
+	}
+
+	st.profile.Environment = []oz.EnvVar{{Name: "FAIL", Value: "1"}}
+	err = st.runPostSetupHook()
+	if err == nil {
+		t.Fatal("expecting a failing hook to return an error")
+	}
+	if !strings.Contains(err.Error(), "cannot create") {
+		t.Errorf("expecting the hook output in the error, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package ozinit
+
+import (
+	"time"
+)
+
+// startIdleTimer shuts the sandbox down after the idle timeout of the profile
+// once its last process exited, unless a program is run in the meantime. A
+// pre-warmed sandbox is kept alive until it has run a program.
+func (st *initState) startIdleTimer() {
+	timeout := st.profile.IdleTimeoutSeconds
+	if timeout <= 0 {
+		return
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.prewarmed || len(st.children) > 0 || st.idleTimer != nil {
+		return
+	}
+	st.log.Info("No process left in sandbox, shutting down in %d seconds unless a program is run", timeout)
+	var t *time.Timer
+	t = time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		st.lock.Lock()
+		// The timer may have been cancelled while this function was waiting for the lock
+		idle := st.idleTimer == t && len(st.children) == 0
+		if st.idleTimer == t {
+			st.idleTimer = nil
+		}
+		st.lock.Unlock()
+		if idle {
+			st.log.Info("Shutting down sandbox after %d seconds without any process", timeout)
+			st.shutdown()
+		}
+	})
+	st.idleTimer = t
+}
+
+func (st *initState) cancelIdleTimer() {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.idleTimer != nil {
+		st.idleTimer.Stop()
+		st.idleTimer = nil
+		st.log.Info("Idle shutdown cancelled")
+	}
+}
@@ -0,0 +1,55 @@
+package ozinit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestIdleTimer(t *testing.T) {
+	st := &initState{
+		log:      logging.MustGetLogger("oz-init-test"),
+		profile:  &oz.Profile{IdleTimeoutSeconds: 1},
+		children: make(map[int]procState),
+		workers:  newWorkerGroup(),
+	}
+	// Returns once shutdown stops the workers
+	st.workers.Go(func(done <-chan struct{}) {
+		<-done
+	})
+	st.startIdleTimer()
+	st.cancelIdleTimer()
+	time.Sleep(1200 * time.Millisecond)
+	if st.shutdownRequested {
+		t.Fatal("expecting a cancelled idle timer not to shut down the sandbox")
+	}
+
+	st.startIdleTimer()
+	if !st.workers.Wait(2 * time.Second) {
+		t.Fatal("expecting the idle timer to shut down the sandbox")
+	}
+}
+
+func TestIdleTimerPreWarmed(t *testing.T) {
+	st := &initState{
+		log:       logging.MustGetLogger("oz-init-test"),
+		profile:   &oz.Profile{IdleTimeoutSeconds: 1},
+		children:  make(map[int]procState),
+		workers:   newWorkerGroup(),
+		prewarmed: true,
+	}
+	// The ready probe or an orphan reaped before any program was run
+	st.handleChildExit(200, 0)
+	if st.idleTimer != nil {
+		t.Fatal("expecting no idle timer while the sandbox is pre-warmed")
+	}
+
+	st.prewarmed = false
+	st.startIdleTimer()
+	if st.idleTimer == nil {
+		t.Fatal("expecting the idle timer to start once a program was run")
+	}
+	st.cancelIdleTimer()
+}
@@ -15,11 +15,13 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
-	//"time"
+	"time"
 
 	"github.com/subgraph/oz"
 	"github.com/subgraph/oz/fs"
@@ -34,28 +36,62 @@ import (
 type procState struct {
 	cmd   *exec.Cmd
 	track bool
+	// Launch order of the process, children are always listed in this order
+	seq uint64
 }
 
 type initState struct {
 	log               *logging.Logger
+	recentLog         *logTail
 	profile           *oz.Profile
 	config            *oz.Config
 	sockaddr          string
 	launchEnv         []string
 	lock              sync.Mutex
 	children          map[int]procState
+	childSeq          uint64
+	childrenDrained   chan struct{}
+	childExits        map[int]chan struct{}
+	grantedCaps       map[string]bool
+	idleTimer         *time.Timer
 	uid               uint32
 	gid               uint32
 	gids              map[string]uint32
 	user              *user.User
+	userShell         string
+	groupName         string
+	appsCgroup        *appsCgroup
 	display           int
+	extraDisplays     []int
+	reserved          []int
 	fs                *fs.Filesystem
+	hostRoot          *os.File
 	ipcServer         *ipc.MsgServer
-	xpra              *xpra.Xpra
+	xpras             map[int]*xpra.Xpra
 	xpraReady         sync.WaitGroup
+	xpraReadySeen     bool
+	networkUp         bool
+	startTime         time.Time
+	xpraConflict      bool
 	dbusUuid          string
 	shutdownRequested bool
 	ephemeral         bool
+	workers           *workerGroup
+	streams           map[string]*outputStream
+	reaped            reapStats
+	prewarmed         bool
+	seccompVersion    int
+	seccompHash       string
+	runLimiter        *tokenBucket
+	readyPattern      *regexp.Regexp
+	appReady          chan struct{}
+	readyTimeout      sync.Once
+	readySignal       sync.Once
+	shutdownSignals   map[os.Signal]bool
+	xpraClients       xpraClients
+	outputTail        outputTail
+	xpraOutput        outputTail
+	exits             exitWaiters
 }
 
 type InitData struct {
@@ -68,11 +104,21 @@ type InitData struct {
 	Gids      map[string]uint32
 	User      user.User
 	Display   int
+	// Displays of the other sandboxes, skipped when a display is in use
+	Reserved  []int
 	Ephemeral bool
 }
 
 const (
 	DBUS_VAR_REGEXP = "[A-Za-z_]+=[a-zA-Z_:-@]+=/tmp/.+"
+	// Maximum number of displays tried when xpra reports the display is in use
+	MAX_XPRA_DISPLAY_RETRIES = 5
+	// Used when xpra_stop_timeout is not set in the oz config
+	DEFAULT_XPRA_STOP_TIMEOUT = 10 * time.Second
+	// Used when xpra_ready_timeout is not set in the oz config
+	DEFAULT_XPRA_READY_TIMEOUT = 60 * time.Second
+	// Used when shutdown_grace_seconds is not set in the oz config
+	DEFAULT_SHUTDOWN_GRACE = 5 * time.Second
 )
 
 var dbusValidVar = regexp.MustCompile(DBUS_VAR_REGEXP)
@@ -80,13 +126,23 @@ var dbusValidVar = regexp.MustCompile(DBUS_VAR_REGEXP)
 // By convention oz-init writes log messages to stderr with a single character
 // prefix indicating the logging level.  These messages are read one line at a time
 // over a pipe by oz-daemon and translated into appropriate log events.
-func createLogger() *logging.Logger {
+// The recent lines are also kept for debug dumps.
+func createLogger() (*logging.Logger, *logTail) {
 	l := logging.MustGetLogger("oz-init")
 	be := logging.NewLogBackend(os.Stderr, "", 0)
 	f := logging.MustStringFormatter("%{level:.1s} %{message}")
 	fbe := logging.NewBackendFormatter(be, f)
-	logging.SetBackend(fbe)
-	return l
+	tail := &logTail{}
+	logging.SetBackend(fbe, tail)
+	return l, tail
+}
+
+func init() {
+	// Keep the main goroutine on the main thread. The threads restricted to
+	// start an application (no_new_privs, landlock, dropped capabilities)
+	// are discarded with their goroutine, which the runtime cannot do with
+	// the main thread: it would be left behind with the restrictions.
+	runtime.LockOSThread()
 }
 
 func Main() {
@@ -94,7 +150,7 @@ func Main() {
 }
 
 func parseArgs() *initState {
-	log := createLogger()
+	log, recentLog := createLogger()
 
 	if os.Getuid() != 0 {
 		log.Error("oz-init must run as root\n")
@@ -111,6 +167,10 @@ func parseArgs() *initState {
 		log.Error("unable to decode init data: %v", err)
 		os.Exit(1)
 	}
+	if initData.Config.LogFormat == "json" {
+		setJSONLogBackend(os.Stderr, initData.Profile.Name, recentLog)
+	}
+	setLogLevels(log, initData.Config.LogLevels)
 	log.Debug("Init state: %+v", initData)
 
 	if (initData.User.Uid != strconv.Itoa(int(initData.Uid))) || (initData.Uid == 0) {
@@ -118,6 +178,11 @@ func parseArgs() *initState {
 		os.Exit(1)
 	}
 
+	shell, err := lookupLoginShell("/etc/passwd", initData.User.Username)
+	if err != nil {
+		log.Warning("Unable to find the login shell of %s: %v", initData.User.Username, err)
+	}
+
 	env := []string{}
 	env = append(env, initData.LaunchEnv...)
 	env = append(env, "PATH=/usr/bin:/bin")
@@ -127,19 +192,27 @@ func parseArgs() *initState {
 	}
 
 	return &initState{
-		log:       log,
-		config:    &initData.Config,
-		sockaddr:  initData.Sockaddr,
-		launchEnv: env,
-		profile:   &initData.Profile,
-		children:  make(map[int]procState),
-		uid:       initData.Uid,
-		gid:       initData.Gid,
-		gids:      initData.Gids,
-		user:      &initData.User,
-		display:   initData.Display,
-		fs:        fs.NewFilesystem(&initData.Config, log, &initData.User, &initData.Profile),
-		ephemeral: initData.Ephemeral,
+		log:        log,
+		recentLog:  recentLog,
+		config:     &initData.Config,
+		sockaddr:   initData.Sockaddr,
+		launchEnv:  env,
+		profile:    &initData.Profile,
+		children:   make(map[int]procState),
+		uid:        initData.Uid,
+		gid:        initData.Gid,
+		gids:       initData.Gids,
+		user:       &initData.User,
+		userShell:  shell,
+		groupName:  lookupGroupName(initData.Gid),
+		display:    initData.Display,
+		reserved:   initData.Reserved,
+		xpras:      make(map[int]*xpra.Xpra),
+		fs:         fs.NewFilesystem(&initData.Config, subsystemLogger("fs"), &initData.User, &initData.Profile),
+		ephemeral:  initData.Ephemeral,
+		workers:    newWorkerGroup(),
+		streams:    newOutputStreams(),
+		runLimiter: newTokenBucket(initData.Config.RunProgramRateLimit, initData.Config.RunProgramBurst),
 	}
 }
 
@@ -158,14 +231,54 @@ func (st *initState) waitForParentReady() *initState {
 }
 
 func (st *initState) runInit() {
+	st.startTime = time.Now()
 	st.log.Info("Starting oz-init for profile: %s", st.profile.Name)
-	sigs := make(chan os.Signal)
-	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	st.applyRuntimeLimits()
+	if err := st.setupReadyPattern(); err != nil {
+		st.log.Error("%v", err)
+		os.Exit(1)
+	}
+	if st.profile.AllowFuse {
+		st.log.Warning("Profile %s allows FUSE mounts, sandboxed processes can mount filesystems!", st.profile.Name)
+		st.setupFuseCapabilities()
+	}
+	if st.profile.AllowPtrace {
+		st.log.Warning("Profile %s allows ptrace, debuggers can be attached to sandboxed processes and isolation is reduced!", st.profile.Name)
+	}
+	handled, err := st.setupSignals()
+	if err != nil {
+		st.log.Error("%v", err)
+		os.Exit(1)
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, handled...)
+	stopAbortWatch := st.watchStartupAbort(sigs)
 
-	s, err := ipc.NewServer(st.sockaddr, messageFactory, st.log,
+	ipcOpts := ipc.ServerOptions{
+		Backlog:  st.config.IPCBacklog,
+		MaxConns: st.config.IPCMaxConnections,
+	}
+	s, err := ipc.NewServerWithOptions(st.sockaddr, ipcOpts, messageFactory, subsystemLogger("ipc"),
 		handlePing,
 		st.handleRunProgram,
+		st.handleExec,
 		st.handleRunShell,
+		st.handleRunDebugger,
+		st.handleGetInfo,
+		st.handleDiskUsage,
+		st.handleSubscribeOutput,
+		st.handleReloadSeccomp,
+		st.handleTrimMemory,
+		st.handleDebugDump,
+		st.handleListProcesses,
+		st.handleListMounts,
+		st.handleKillProcess,
+		st.handleGetStatus,
+		st.handleGetRlimits,
+		st.handleSetRlimits,
+		st.handleSetGroups,
+		st.handleAddWhitelist,
+		st.handleAddWhitelistItems,
 		st.handleSetupForwarder,
 	)
 	if err != nil {
@@ -201,16 +314,53 @@ func (st *initState) runInit() {
 	if len(st.profile.SharedFolders) > 0 {
 		wlExtras = st.addSharedFolders(wlExtras)
 	}
+	if st.ephemeral {
+		st.profile.AppDataDirs = nil
+		st.profile.ShareDownloads = false
+	}
+	wlExtras = addAppDataDirs(wlExtras, st.profile.AppDataDirs)
+	if st.profile.ShareDownloads && hasHomeDir(st.user) {
+		wlExtras = append(wlExtras, oz.WhitelistItem{Path: st.downloadsDir(), CanCreate: true})
+	}
+
+	// The cgroup files must be opened before the root is changed
+	if err := st.setupAppsCgroup(); err != nil {
+		st.log.Error("Unable to setup the cgroup limits: %v", err)
+		os.Exit(1)
+	}
+
+	if len(st.profile.Networking.AllowedPorts) > 0 {
+		// nft is blacklisted inside the sandbox, the rules are loaded in its
+		// network namespace before the root is changed
+		rules, err := network.NetRestrictPorts(st.profile.Networking.AllowedPorts)
+		if err != nil {
+			st.log.Error("Unable to restrict the egress ports: %v", err)
+			os.Exit(1)
+		}
+		subsystemLogger("network").Info("Egress rules applied:\n%s", rules)
+	}
 
 	if err := st.setupFilesystem(wlExtras, blExtras); err != nil {
 		st.log.Error("Failed to setup filesytem: %v", err)
 		os.Exit(1)
 	}
 
-	if st.user != nil && st.user.HomeDir != "" {
+	if hasHomeDir(st.user) {
 		st.launchEnv = append(st.launchEnv, "HOME="+st.user.HomeDir)
 	}
 
+	if st.profile.PostSetupHook != "" {
+		if err := st.runPostSetupHook(); err != nil {
+			st.log.Error("Post setup hook (%s) failed: %v", st.profile.PostSetupHook, err)
+			os.Exit(1)
+		}
+	}
+
+	if st.profile.ReadyWhen == "mounts" {
+		// The daemon only reaches the IPC server once the setup below is done
+		os.Stderr.WriteString("OK\n")
+	}
+
 	if st.profile.Networking.Nettype != network.TYPE_HOST ||
 		st.profile.Networking.Nettype != network.TYPE_NONE {
 		err := network.NetSetup()
@@ -218,8 +368,11 @@ func (st *initState) runInit() {
 			st.log.Error("Unable to setup networking: %+v", err)
 			os.Exit(1)
 		}
+		st.lock.Lock()
+		st.networkUp = true
+		st.lock.Unlock()
 	}
-	network.NetPrint(st.log)
+	network.NetPrint(subsystemLogger("network"))
 
 	if syscall.Sethostname([]byte(st.profile.Name)) != nil {
 		st.log.Error("Failed to set hostname to (%s)", st.profile.Name)
@@ -230,19 +383,29 @@ func (st *initState) runInit() {
 	}
 	st.log.Info("Hostname set to (%s.local)", st.profile.Name)
 
+	if err := st.setupKernelTunables(); err != nil {
+		st.log.Error("Unable to setup kernel tunables: %v", err)
+		os.Exit(1)
+	}
+
+	if err := st.seedEntropy(); err != nil {
+		st.log.Warning("Unable to seed entropy: %v", err)
+	}
+
+	st.initSeccompVersion()
+
 	if err := st.setupDbus(); err != nil {
 		st.log.Error("Unable to setup dbus: %v", err)
 		os.Exit(1)
 	}
 
 	st.setupEtcFiles()
+	st.setupResolvConf()
 
 	oz.ReapChildProcs(st.log, st.handleChildExit)
 
 	if st.profile.XServer.Enabled {
-		st.xpraReady.Add(1)
-		st.startXpraServer()
-		st.xpraReady.Wait()
+		st.runXpraServers()
 		st.log.Info("XPRA started")
 	}
 
@@ -256,16 +419,57 @@ func (st *initState) runInit() {
 	fsbx := path.Join("/tmp", "oz-sandbox")
 	err = ioutil.WriteFile(fsbx, []byte(st.profile.Name), 0644)
 
-	// Signal the daemon we are ready
-	os.Stderr.WriteString("OK\n")
+	if err := st.writeMetadata(); err != nil {
+		st.log.Error("Unable to write sandbox metadata: %v", err)
+		os.Exit(1)
+	}
+
+	if st.profile.ReadOnlyRoot {
+		// Done once the etc files, the dbus machine id and the metadata
+		// are written to the root rather than right after the mounts
+		if err := st.fs.RemountRootReadOnly(); err != nil {
+			st.log.Error("Unable to remount the root read-only: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if st.profile.PreWarm {
+		// Filesystem, network and xpra are all ready at this point, hold the
+		// sandbox idle until the first RunProgram arrives
+		st.lock.Lock()
+		st.prewarmed = true
+		st.lock.Unlock()
+		st.log.Info("Sandbox is pre-warmed and waiting for a program to run")
+	}
 
-	go st.processSignals(sigs, s)
+	if len(st.profile.ReadyProbe) > 0 {
+		st.waitReadyProbe()
+	}
+
+	pendingSigs := stopAbortWatch()
+
+	if st.profile.ReadyWhen != "mounts" {
+		// Signal the daemon we are ready
+		os.Stderr.WriteString("OK\n")
+	}
+
+	st.workers.Go(func(done <-chan struct{}) {
+		for _, sig := range pendingSigs {
+			st.handleSignal(sig)
+		}
+		st.processSignals(sigs, done)
+	})
 
 	st.ipcServer = s
 
 	if err := s.Run(); err != nil {
 		st.log.Warning("MsgServer.Run() return err: %v", err)
 	}
+	st.workers.Stop()
+	if !st.workers.Wait(workerJoinTimeout) {
+		st.log.Warning("Timed out waiting for background tasks to finish")
+	}
+	st.removeMetadata()
 	st.log.Info("oz-init exiting...")
 }
 
@@ -292,6 +496,31 @@ func (st *initState) addSharedFolders(wlExtras []oz.WhitelistItem) []oz.Whitelis
 	return wlExtras
 }
 
+// Home subdirectories expanded for each application listed in app_data_dirs
+var appDataBases = []string{".config", ".local/share", ".cache"}
+
+func addAppDataDirs(wlExtras []oz.WhitelistItem, apps []string) []oz.WhitelistItem {
+	for _, app := range apps {
+		for _, base := range appDataBases {
+			wlExtras = append(wlExtras, oz.WhitelistItem{
+				Path:      path.Join("${HOME}", base, app),
+				CanCreate: true})
+		}
+	}
+	return wlExtras
+}
+
+// downloadsDir returns the XDG Downloads directory of the user, or
+// ~/Downloads when the user-dirs configuration does not define one.
+func (st *initState) downloadsDir() string {
+	if xdg := st.fs.GetXDGDirs(); xdg != nil {
+		if d := xdg.GetDir("DOWNLOAD"); d != "" {
+			return d
+		}
+	}
+	return path.Join(st.user.HomeDir, "Downloads")
+}
+
 const hostsfile = `127.0.0.1	localhost
 127.0.1.1	%HOSTNAME% %HOSTNAME%.%DOMAINNAME%
 ::1     localhost ip6-localhost ip6-loopback
@@ -317,6 +546,10 @@ func (st *initState) setupEtcFiles() {
 		"machine-id": st.dbusUuid,
 		"fstab":      "# This fstab file is empty",
 	}
+	if st.config.SyntheticPasswd {
+		etcfiles["passwd"] = st.syntheticPasswd()
+		etcfiles["group"] = st.syntheticGroup()
+	}
 	for fpath, fcontents := range etcfiles {
 		fpath = path.Join("/etc", fpath)
 		if err := ioutil.WriteFile(fpath, []byte(fcontents+"\n"), 0644); err != nil {
@@ -382,15 +615,104 @@ func (st *initState) getDbusSession() error {
 	return nil
 }
 
-func (st *initState) startXpraServer() {
+// runXpraServers starts the xpra server of the main display, then one for
+// each extra display of the profile numbered after the main display, skipping
+// the displays reserved for the other sandboxes.
+func (st *initState) runXpraServers() {
+	if display := st.runXpraServer(st.display); display != st.display {
+		st.setDisplay(display)
+	}
+	next := st.nextDisplay(st.display)
+	for i := 0; i < st.profile.XServer.ExtraDisplays; i++ {
+		display := st.runXpraServer(next)
+		st.lock.Lock()
+		st.extraDisplays = append(st.extraDisplays, display)
+		st.lock.Unlock()
+		next = st.nextDisplay(display)
+	}
+}
+
+// runXpraServer starts an xpra server and moves to the next display number
+// when xpra reports the display as already in use, it returns the display
+// the server runs on.
+func (st *initState) runXpraServer(display int) int {
+	for attempt := 0; ; attempt++ {
+		st.xpraConflict = false
+		st.xpraReady.Add(1)
+		st.startXpraServer(display)
+		if !st.waitXpraReady() {
+			st.failXpraStartup(display)
+		}
+		if !st.xpraConflict {
+			return display
+		}
+		st.lock.Lock()
+		delete(st.xpras, display)
+		st.lock.Unlock()
+		if attempt >= MAX_XPRA_DISPLAY_RETRIES {
+			st.log.Error("Unable to find a free display for xpra after %d attempts", attempt+1)
+			os.Exit(1)
+		}
+		next := st.nextDisplay(display)
+		st.log.Warning("Display :%d is already in use, retrying xpra with display :%d", display, next)
+		display = next
+	}
+}
+
+// nextDisplay returns the display numbered after display which is not
+// reserved for another sandbox.
+func (st *initState) nextDisplay(display int) int {
+	for display++; containsDisplay(st.reserved, display); display++ {
+	}
+	return display
+}
+
+func containsDisplay(displays []int, display int) bool {
+	for _, d := range displays {
+		if d == display {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDisplay returns whether an xpra server of the sandbox runs on display
+func (st *initState) hasDisplay(display int) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return display == st.display || containsDisplay(st.extraDisplays, display)
+}
+
+func (st *initState) setDisplay(display int) {
+	st.display = display
+	for i, e := range st.launchEnv {
+		if strings.HasPrefix(e, "DISPLAY=") {
+			st.launchEnv[i] = "DISPLAY=:" + strconv.Itoa(display)
+		}
+	}
+}
+
+func isXpraDisplayConflict(line string) bool {
+	return strings.Contains(line, "already active") || strings.Contains(line, "already in use")
+}
+
+func (st *initState) startXpraServer(display int) {
 	if st.user == nil {
 		st.log.Warning("Cannot start xpra server because no user is set")
 		return
 	}
-	workdir := path.Join(st.user.HomeDir, ".Xoz", st.profile.Name)
+	home := st.user.HomeDir
+	if !hasHomeDir(st.user) {
+		home = "/tmp"
+	}
+	workdir := path.Join(home, ".Xoz", st.profile.Name)
 	st.log.Info("xpra work dir is %s", workdir)
+	st.cleanXpraWorkdir(workdir, display)
 	spath := path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
-	xpra := xpra.NewServer(&st.profile.XServer, uint64(st.display), spath, workdir)
+	if st.profile.XServer.Mode == oz.PROFILE_XPRA_DESKTOP {
+		st.log.Info("Starting xpra in desktop mode, the display is shown in a single window")
+	}
+	xpra := xpra.NewServer(&st.profile.XServer, uint64(display), spath, workdir)
 	//st.log.Debug("%s %s", strings.Join(xpra.Process.Env, " "), strings.Join(xpra.Process.Args, " "))
 	if xpra == nil {
 		st.log.Error("Error creating xpra server command")
@@ -401,18 +723,21 @@ func (st *initState) startXpraServer() {
 		st.log.Error("Error creating stderr pipe for xpra output: %v", err)
 		os.Exit(1)
 	}
-	go st.readXpraOutput(p)
+	st.workers.Go(func(<-chan struct{}) {
+		st.readXpraOutput(p)
+	})
 	xpra.Process.Env = []string{
-		"HOME=" + st.user.HomeDir,
+		"HOME=" + home,
 	}
 	xpra.Process.Env = setEnvironOverrides(xpra.Process.Env)
+	xpra.Process.Env = overrideEnv(xpra.Process.Env, envVars(st.profile.XServer.Environment, st.launchEnv))
 
 	groups := append([]uint32{}, st.gid)
-	if gid, gexists := st.gids["video"]; gexists {
+	if gid, gexists := st.groupGid("video"); gexists {
 		groups = append(groups, gid)
 	}
 	if st.profile.XServer.AudioMode != oz.PROFILE_AUDIO_NONE {
-		if gid, gexists := st.gids["audio"]; gexists {
+		if gid, gexists := st.groupGid("audio"); gexists {
 			groups = append(groups, gid)
 		}
 	}
@@ -423,41 +748,76 @@ func (st *initState) startXpraServer() {
 		Gid:    st.gid,
 		Groups: groups,
 	}
-	st.log.Info("Starting xpra server")
+	st.log.Info("Starting xpra server on display :%d", display)
 	if err := xpra.Process.Start(); err != nil {
 		st.log.Warning("Failed to start xpra server: %v", err)
 		st.xpraReady.Done()
 	}
-	st.xpra = xpra
+	st.lock.Lock()
+	st.xpras[display] = xpra
+	st.lock.Unlock()
 }
 
 func (st *initState) readXpraOutput(r io.ReadCloser) {
+	xlog := subsystemLogger("xpra")
 	sc := bufio.NewScanner(r)
 	seenReady := false
 	for sc.Scan() {
 		line := sc.Text()
 		if len(line) > 0 {
+			if !seenReady {
+				st.xpraOutput.add("xpra-server", line)
+			}
 			//if strings.Contains(line, "_OZ_XXSTARTEDXX") &&
 			//	strings.Contains(line, "has terminated") && !seenReady {
+			if isXpraDisplayConflict(line) && !seenReady {
+				xlog.Warning("(xpra-server) %s", line)
+				seenReady = true
+				st.xpraConflict = true
+				st.xpraReady.Done()
+				continue
+			}
 			if strings.Contains(line, "xpra is ready.") && !seenReady {
 				seenReady = true
+				st.lock.Lock()
+				st.xpraReadySeen = true
+				st.lock.Unlock()
 				st.xpraReady.Done()
-				if !st.config.LogXpra {
+				if !st.config.LogXpra && !st.profile.XServer.ExitOnDisconnect {
 					r.Close()
 					return
 				}
 			}
+			if seenReady {
+				st.trackXpraClients(line)
+			}
 			if st.config.LogXpra {
-				st.log.Debug("(xpra-server) %s", line)
+				xlog.Debug("(xpra-server) %s", line)
 			}
 		}
 	}
 }
 
-func (st *initState) launchApplication(cpath, pwd string, cmdArgs []string) (*exec.Cmd, error) {
+// Value of TERM for terminal applications when the launcher did not provide one
+const DEFAULT_TERM = "xterm-256color"
+
+// launchApplication starts the program with its output logged, or attached to
+// a new pty returned to the caller when usePty is set. With isolateNet the
+// program runs in its own network namespace with only a loopback interface.
+// A non empty argv0 is given to the program instead of its path. When wait is
+// set the exit status of the program is sent on the returned channel.
+func (st *initState) launchApplication(cpath, argv0, pwd, term string, cmdArgs []string, usePty, isolateNet, wait bool, display int) (*exec.Cmd, *os.File, <-chan syscall.WaitStatus, error) {
+	if wait && usePty {
+		return nil, nil, nil, fmt.Errorf("Cannot wait for a program running in a pty")
+	}
+	if isolateNet && !st.profile.AllowNetIsolation {
+		return nil, nil, nil, fmt.Errorf("Network isolation of programs is not enabled in profile")
+	}
 	if cpath == "" {
 		cpath = st.profile.Path
 	}
+	// Name of the output capture file, before the path is diverted or wrapped
+	name := path.Base(cpath)
 	if st.config.DivertSuffix != "" {
 		cpath += "." + st.config.DivertSuffix
 	}
@@ -471,93 +831,238 @@ func (st *initState) launchApplication(cpath, pwd string, cmdArgs []string) (*ex
 	if len(st.profile.DefaultParams) > 0 {
 		cmdArgs = append(st.profile.DefaultParams, cmdArgs...)
 	}
+	cmdArgs, err := st.applyFixedArgs(cmdArgs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if argv0 == "" {
+		argv0 = st.profile.Argv0
+	}
+	if argv0 != "" {
+		if err := checkExecutable(cpath); err != nil {
+			return nil, nil, nil, fmt.Errorf("Cannot run %s as %s: %v", cpath, argv0, err)
+		}
+	}
+	seccompArgs := func(mode string) []string {
+		if argv0 == "" {
+			return []string{mode, cpath}
+		}
+		return []string{mode, "-argv0=" + argv0, cpath}
+	}
 
+	// Run through oz-seccomp-tracer, which reports the syscalls hit by the policy
+	traced := false
 	switch st.profile.Seccomp.Mode {
 	case oz.PROFILE_SECCOMP_TRAIN:
 		st.log.Notice("Enabling seccomp training mode for : %s", cpath)
 		spath := path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
-		cmdArgs = append([]string{spath, "-mode=whitelist", cpath}, cmdArgs...)
+		cmdArgs = append(append([]string{spath}, seccompArgs("-mode=whitelist")...), cmdArgs...)
 		cpath = path.Join(st.config.PrefixPath, "bin", "oz-seccomp-tracer")
+		traced = true
 	case oz.PROFILE_SECCOMP_WHITELIST:
 		st.log.Notice("Enabling seccomp whitelist for: %s", cpath)
 		if st.profile.Seccomp.Enforce == false {
 			spath := path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
-			cmdArgs = append([]string{"-r", "-p", "-", spath, "-mode=whitelist", cpath}, cmdArgs...)
+			cmdArgs = append(append([]string{"-r", "-p", "-", spath}, seccompArgs("-mode=whitelist")...), cmdArgs...)
 			cpath = path.Join(st.config.PrefixPath, "bin", "oz-seccomp-tracer")
+			traced = true
 			 
 		} else {
-			cmdArgs = append([]string{"-mode=whitelist", cpath}, cmdArgs...)
+			cmdArgs = append(seccompArgs("-mode=whitelist"), cmdArgs...)
 			cpath = path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
 		}
 	case oz.PROFILE_SECCOMP_BLACKLIST:
 		st.log.Notice("Enabling seccomp blacklist for: %s", cpath)
 		if st.profile.Seccomp.Enforce == false {
 			spath := path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
-			cmdArgs = append([]string{spath, "-mode=blacklist", cpath}, cmdArgs...)
+			cmdArgs = append(append([]string{spath}, seccompArgs("-mode=blacklist")...), cmdArgs...)
 			cpath = path.Join(st.config.PrefixPath, "bin", "oz-seccomp-tracer")
+			traced = true
 		} else {
-			cmdArgs = append([]string{"-mode=blacklist", cpath}, cmdArgs...)
+			cmdArgs = append(seccompArgs("-mode=blacklist"), cmdArgs...)
 			cpath = path.Join(st.config.PrefixPath, "bin", "oz-seccomp")
 		}
 	}
 
-	cmd := exec.Command(cpath)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		st.log.Warning("Failed to create stdout pipe: %v", err)
-		return nil, err
+	// The supervisor does not pass the descriptor on to the tracer
+	reportViolations := traced && !st.profile.UseInSandboxSupervisor
+	if reportViolations {
+		cmdArgs = append([]string{fmt.Sprintf("--violations-fd=%d", seccompViolationsFd)}, cmdArgs...)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		st.log.Warning("Failed to create stderr pipe: %v", err)
-		return nil, err
+
+	seccompWrapped := st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_WHITELIST ||
+		st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_BLACKLIST || st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_TRAIN
+	if usePty && seccompWrapped {
+		// oz-seccomp reads the profile from stdin, which the pty replaces
+		return nil, nil, nil, fmt.Errorf("Cannot allocate a pty for an application running under seccomp")
 	}
-	groups := append([]uint32{}, st.gid)
-	for _, gid := range st.gids {
-		groups = append(groups, gid)
+	if seccompWrapped {
+		// oz-seccomp gives argv0 to the program it executes
+		argv0 = ""
 	}
-	cmd.SysProcAttr = &syscall.SysProcAttr{}
-	cmd.SysProcAttr.Credential = &syscall.Credential{
-		Uid:    st.uid,
-		Gid:    st.gid,
-		Groups: groups,
+	if st.profile.UseInSandboxSupervisor {
+		cpath, cmdArgs = st.supervisedCommand(cpath, argv0, cmdArgs, seccompWrapped)
+		argv0 = ""
+	}
+	if limits := st.applicationRlimits(); len(limits) > 0 {
+		cpath, cmdArgs = st.rlimitCommand(cpath, argv0, cmdArgs, limits)
+		argv0 = ""
+	}
+
+	cmd := exec.Command(cpath)
+	var stdout, stderr io.ReadCloser
+	if !usePty {
+		var err error
+		stdout, err = cmd.StdoutPipe()
+		if err != nil {
+			st.log.Warning("Failed to create stdout pipe: %v", err)
+			return nil, nil, nil, err
+		}
+		stderr, err = cmd.StderrPipe()
+		if err != nil {
+			st.log.Warning("Failed to create stderr pipe: %v", err)
+			return nil, nil, nil, err
+		}
+	}
+	st.setupApplicationCommand(cmd, pwd, term)
+	if display != 0 {
+		cmd.Env = overrideEnv(cmd.Env, map[string]string{"DISPLAY": ":" + strconv.Itoa(display)})
+	}
+	// Only given to applications, not to the shells, probes and debuggers
+	if len(st.profile.Preload) > 0 {
+		preload, err := preloadEnv(st.profile.Preload)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		st.log.Info("Preloading %s into %s", strings.Join(st.profile.Preload, ", "), cpath)
+		cmd.Env = append(cmd.Env, preload)
 	}
-	cmd.Env = setEnvironOverrides(cmd.Env)
-	cmd.Env = append(cmd.Env, st.launchEnv...)
 
-	if st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_WHITELIST ||
-		st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_BLACKLIST || st.profile.Seccomp.Mode == oz.PROFILE_SECCOMP_TRAIN {
+	if seccompWrapped {
 		pi, err := cmd.StdinPipe()
 		if err != nil {
-			return nil, fmt.Errorf("error creating stdin pipe for seccomp process: %v", err)
+			return nil, nil, nil, fmt.Errorf("error creating stdin pipe for seccomp process: %v", err)
 		}
+		st.lock.Lock()
 		jdata, err := json.Marshal(st.profile)
+		st.lock.Unlock()
 		if err != nil {
-			return nil, fmt.Errorf("Unable to marshal seccomp state: %+v", err)
+			return nil, nil, nil, fmt.Errorf("Unable to marshal seccomp state: %+v", err)
 		}
 		io.Copy(pi, bytes.NewBuffer(jdata))
 		pi.Close()
 	}
 
+	var violations *os.File
+	if reportViolations {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error creating seccomp violations pipe: %v", err)
+		}
+		cmd.ExtraFiles = []*os.File{w}
+		violations = r
+	}
+
 	cmd.Args = append(cmd.Args, cmdArgs...)
+	if argv0 != "" {
+		cmd.Args[0] = argv0
+	}
 
-	if pwd == "" {
-		pwd = st.user.HomeDir
+	st.intoAppsCgroup(cmd)
+	start := func(c *exec.Cmd) error {
+		return st.startRestricted(c, isolateNet)
 	}
-	if _, err := os.Stat(pwd); err == nil {
-		cmd.Dir = pwd
+	if usePty {
+		ptty, err := ptyStartWith(cmd, start)
+		if err != nil {
+			st.log.Warning("Failed to start application (%s) in a pty: %v", st.profile.Path, err)
+			return nil, nil, nil, err
+		}
+		st.addChildProcess(cmd, true)
+		return cmd, ptty, nil, nil
 	}
 
-	if err := cmd.Start(); err != nil {
+	var exited <-chan syscall.WaitStatus
+	if wait {
+		exited, err = st.exits.startWith(cmd, start)
+	} else {
+		err = start(cmd)
+	}
+	if violations != nil {
+		cmd.ExtraFiles[0].Close()
+		if err != nil {
+			violations.Close()
+		}
+	}
+	if err != nil {
 		st.log.Warning("Failed to start application (%s): %v", st.profile.Path, err)
-		return nil, err
+		return nil, nil, nil, err
+	}
+	if violations != nil {
+		st.workers.Go(func(<-chan struct{}) {
+			st.readSeccompViolations(violations)
+		})
 	}
 	st.addChildProcess(cmd, true)
+	st.startReadyTimeout()
+
+	// Output readers are not cancelled, they return once the application
+	// exits so that its last lines of output are still logged
+	if st.profile.StreamOutput {
+		st.workers.Go(func(<-chan struct{}) {
+			st.relayApplicationOutput(stdout, st.streams["stdout"])
+		})
+		st.workers.Go(func(<-chan struct{}) {
+			st.relayApplicationOutput(stderr, st.streams["stderr"])
+		})
+		return cmd, nil, exited, nil
+	}
+	out := st.openOutputCapture(name, cmd.Process.Pid)
+	var readers sync.WaitGroup
+	readers.Add(2)
+	st.workers.Go(func(<-chan struct{}) {
+		st.readApplicationOutput(stdout, "stdout", out)
+		readers.Done()
+	})
+	st.workers.Go(func(<-chan struct{}) {
+		st.readApplicationOutput(stderr, "stderr", out)
+		readers.Done()
+	})
+	if out != nil {
+		go func() {
+			readers.Wait()
+			out.Close()
+		}()
+	}
+
+	return cmd, nil, exited, nil
+}
 
-	go st.readApplicationOutput(stdout, "stdout")
-	go st.readApplicationOutput(stderr, "stderr")
-
-	return cmd, nil
+// setupApplicationCommand gives cmd the credentials, environment and working
+// directory of the applications of the sandbox
+func (st *initState) setupApplicationCommand(cmd *exec.Cmd, pwd, term string) {
+	groups := st.userGroups()
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    st.uid,
+		Gid:    st.gid,
+		Groups: groups,
+	}
+	cmd.Env = setEnvironOverrides(cmd.Env)
+	cmd.Env = append(cmd.Env, st.launchEnv...)
+	if term == "" && st.profile.Terminal {
+		term = DEFAULT_TERM
+	}
+	if term != "" {
+		cmd.Env = append(cmd.Env, "TERM="+term)
+	}
+	cmd.Env = overrideEnv(cmd.Env, envVars(st.profile.Environment, st.launchEnv))
+	if pwd == "" && hasHomeDir(st.user) {
+		pwd = st.user.HomeDir
+	}
+	if _, err := os.Stat(pwd); err == nil {
+		cmd.Dir = pwd
+	}
 }
 
 func setEnvironOverrides(env []string) []string {
@@ -569,14 +1074,34 @@ func setEnvironOverrides(env []string) []string {
 	return env
 }
 
-func (st *initState) readApplicationOutput(r io.ReadCloser, label string) {
-	sc := bufio.NewScanner(r)
-	for sc.Scan() {
-		line := sc.Text()
-		st.log.Debug("(%s) %s", label, line)
-
+// readApplicationOutput logs the output of an application, or writes it to out
+// when its output is captured to a file.
+func (st *initState) readApplicationOutput(r io.ReadCloser, label string, out *os.File) {
+	limit := st.newCaptureLimit()
+	max := st.maxLogLine()
+	br := bufio.NewReader(r)
+	for {
+		line, dropped, err := readLogLine(br, max)
+		if err != nil {
+			if err != io.EOF {
+				st.log.Warning("(%s) stopped reading output: %v", label, err)
+			}
+			return
+		}
+		if out != nil {
+			if err := st.writeCapturedLine(out, limit, label, line); err != nil {
+				st.log.Warning("(%s) unable to write captured output, logging it instead: %v", label, err)
+				out = nil
+				st.captureLine(limit, label, line)
+			}
+		} else {
+			st.captureLine(limit, label, line)
+		}
+		if dropped > 0 {
+			st.log.Warning("(%s) [line truncated to %d bytes, %d bytes discarded]", label, max, dropped)
+		}
+		st.checkReadyLine(line)
 	}
-
 }
 
 func loadProfile(dir, name string) (*oz.Profile, error) {
@@ -596,31 +1121,145 @@ func handlePing(ping *PingMsg, msg *ipc.Message) error {
 	return msg.Respond(&PingMsg{Data: ping.Data})
 }
 
+func (st *initState) handleListProcesses(lp *ListProcessesMsg, msg *ipc.Message) error {
+	st.lock.Lock()
+	procs := make([]ProcessInfo, 0, len(st.children))
+	for pid, proc := range st.children {
+		procs = append(procs, ProcessInfo{
+			Pid:  pid,
+			Path: proc.cmd.Path,
+			Args: append([]string{}, proc.cmd.Args...),
+		})
+	}
+	st.lock.Unlock()
+	sort.Sort(processesByPid(procs))
+	return msg.Respond(&ListProcessesResp{Processes: procs})
+}
+
+func (st *initState) handleKillProcess(kp *KillProcessMsg, msg *ipc.Message) error {
+	if kp.Pid == 1 {
+		return msg.Respond(&ErrorMsg{"Refusing to signal oz-init"})
+	}
+	sig := syscall.Signal(kp.Signal)
+	if sig == 0 {
+		sig = syscall.SIGTERM
+	}
+	st.lock.Lock()
+	proc, known := st.children[kp.Pid]
+	st.lock.Unlock()
+	if !known {
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("No tracked process with pid %d", kp.Pid)})
+	}
+	if err := proc.cmd.Process.Signal(sig); err != nil {
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("Failed to signal pid %d: %v", kp.Pid, err)})
+	}
+	st.log.Info("Sent signal (%v) to pid %d", sig, kp.Pid)
+	return msg.Respond(&OkMsg{})
+}
+
+type processesByPid []ProcessInfo
+
+func (p processesByPid) Len() int           { return len(p) }
+func (p processesByPid) Less(i, j int) bool { return p[i].Pid < p[j].Pid }
+func (p processesByPid) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (st *initState) handleGetInfo(gi *GetInfoMsg, msg *ipc.Message) error {
+	st.lock.Lock()
+	info := &InfoMsg{
+		Display:           st.display,
+		ExtraDisplays:     append([]int{}, st.extraDisplays...),
+		PreWarmed:         st.prewarmed,
+		SeccompVersion:    st.seccompVersion,
+		SeccompPolicyHash: st.seccompHash,
+	}
+	st.lock.Unlock()
+	st.reaped.fill(info)
+	fillMemoryStats(info)
+	info.Cpus = allowedCpus()
+	return msg.Respond(info)
+}
+
+func (st *initState) handleGetStatus(gs *GetStatusMsg, msg *ipc.Message) error {
+	st.lock.Lock()
+	status := &StatusMsg{
+		Profile:    st.profile.Name,
+		Children:   len(st.children),
+		XpraReady:  st.xpraReadySeen,
+		Uptime:     time.Since(st.startTime),
+		Networking: st.networkUp,
+	}
+	st.lock.Unlock()
+	return msg.Respond(status)
+}
+
 func (st *initState) handleSetupForwarder(rp *ForwarderSuccessMsg, msg *ipc.Message) error {
 	st.log.Info("Setting up forwarder to: %s", rp.Addr)
 	if len(msg.Fds) == 0 {
 		return fmt.Errorf("SetupForwarder message received, but no file descriptor included")
 	}
-	go func() {
+	st.workers.Go(func(done <-chan struct{}) {
 		f := os.NewFile(uintptr(msg.Fds[0]), "")
 		l, err := net.FileListener(f)
 		if err != nil {
 			st.log.Warning(err.Error())
 			return
 		}
-		for {
-			conn, err := l.Accept()
-			if err != nil {
-				st.log.Error(err.Error())
-			}
-			st.log.Info("Forwarder to accepted incoming client.", rp.Addr)
-			go proxyForwarder(&conn, rp.Proto, rp.Addr)
-		}
-	}()
+		go func() {
+			<-done
+			l.Close()
+		}()
+		st.acceptForwarded(l, rp.Proto, rp.Addr, done)
+	})
 	err := msg.Respond(&OkMsg{})
 	return err
 }
 
+// Longest delay between two Accept calls after temporary errors
+const maxAcceptDelay = time.Second
+
+// acceptDelay returns the delay before accepting again after a temporary
+// error, doubling the previous delay up to maxAcceptDelay.
+func acceptDelay(last time.Duration) time.Duration {
+	if last == 0 {
+		return 5 * time.Millisecond
+	}
+	if last*2 > maxAcceptDelay {
+		return maxAcceptDelay
+	}
+	return last * 2
+}
+
+// acceptForwarded proxies the clients of l to addr until done is closed or
+// the listener fails with a permanent error.
+func (st *initState) acceptForwarded(l net.Listener, proto, addr string, done <-chan struct{}) {
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); !ok || !ne.Temporary() {
+				st.log.Error("Forwarder to %s stopped accepting clients: %v", addr, err)
+				return
+			}
+			delay = acceptDelay(delay)
+			st.log.Warning("Forwarder to %s failed to accept client, retrying in %v: %v", addr, delay, err)
+			select {
+			case <-done:
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		delay = 0
+		st.log.Info("Forwarder to accepted incoming client.", addr)
+		go proxyForwarder(&conn, proto, addr)
+	}
+}
+
 func proxyForwarder(conn *net.Conn, proto string, rAddr string) error {
 	rConn, err := net.Dial(proto, rAddr)
 	if err != nil {
@@ -642,12 +1281,57 @@ func proxyForwarder(conn *net.Conn, proto string, rAddr string) error {
 	return nil
 }
 
+// programAllowed returns whether the profile declares cpath as a program of
+// the sandbox, so a client cannot have oz-init run any other binary.
+func (st *initState) programAllowed(cpath string) bool {
+	allowed := append([]string{st.profile.Path}, st.profile.Paths...)
+	for _, ap := range append(allowed, st.profile.AllowedPaths...) {
+		if ap != "" && path.Clean(ap) == path.Clean(cpath) {
+			return true
+		}
+	}
+	return false
+}
+
 func (st *initState) handleRunProgram(rp *RunProgramMsg, msg *ipc.Message) error {
 	st.log.Info("Run program message received: %+v", rp)
-	_, err := st.launchApplication(rp.Path, rp.Pwd, rp.Args)
+	if rp.Path == "" {
+		rp.Path = st.profile.Path
+	}
+	if !st.programAllowed(rp.Path) {
+		st.log.Warning("Rejecting run program request for %s, it is not allowed by the profile", rp.Path)
+		return msg.Respond(&ErrorMsg{Msg: fmt.Sprintf("Program (%s) is not allowed in this sandbox", rp.Path)})
+	}
+	if rp.Display != 0 && !st.hasDisplay(rp.Display) {
+		return msg.Respond(&ErrorMsg{Msg: fmt.Sprintf("Display :%d is not a display of this sandbox", rp.Display)})
+	}
+	if !st.runLimiter.allow(time.Now()) {
+		st.log.Warning("Rejecting run program request, rate limit of %v per second exceeded", st.config.RunProgramRateLimit)
+		return msg.Respond(&ErrorMsg{Msg: "Program launch rate limit exceeded, try again later"})
+	}
+	st.cancelIdleTimer()
+	_, ptty, exited, err := st.launchApplication(rp.Path, rp.Argv0, rp.Pwd, rp.Term, rp.Args, rp.Pty, rp.IsolateNet, rp.Wait, rp.Display)
 	if err != nil {
 		err := msg.Respond(&ErrorMsg{Msg: err.Error()})
 		return err
+	}
+	// A failed launch keeps a pre-warmed sandbox waiting for a program
+	st.lock.Lock()
+	st.prewarmed = false
+	st.lock.Unlock()
+	if ptty != nil {
+		defer ptty.Close()
+		return msg.Respond(&OkMsg{}, int(ptty.Fd()))
+	} else if exited != nil {
+		// Messages are dispatched one at a time, the response is sent once
+		// the reaper collected the program
+		msg.Detach()
+		go func() {
+			if err := msg.Respond(&ExitMsg{Code: exitCode(<-exited)}); err != nil {
+				st.log.Warning("Unable to send the exit status of a program: %v", err)
+			}
+		}()
+		return nil
 	} else {
 		err := msg.Respond(&OkMsg{})
 		return err
@@ -661,11 +1345,14 @@ func (st *initState) handleRunShell(rs *RunShellMsg, msg *ipc.Message) error {
 	if (msg.Ucred.Uid == 0 || msg.Ucred.Gid == 0) && st.config.AllowRootShell != true {
 		return msg.Respond(&ErrorMsg{"Cannot open shell because allowRootShell is disabled"})
 	}
+	if msg.Ucred.Uid != 0 {
+		if err := st.checkShellAllowed(); err != nil {
+			return msg.Respond(&ErrorMsg{err.Error()})
+		}
+	}
 	groups := append([]uint32{}, st.gid)
 	if msg.Ucred.Uid != 0 && msg.Ucred.Gid != 0 {
-		for _, gid := range st.gids {
-			groups = append(groups, gid)
-		}
+		groups = st.userGroups()
 	}
 	st.log.Info("Starting shell with uid = %d, gid = %d", msg.Ucred.Uid, msg.Ucred.Gid)
 	cmd := exec.Command(st.config.ShellPath, "-i")
@@ -679,24 +1366,78 @@ func (st *initState) handleRunShell(rs *RunShellMsg, msg *ipc.Message) error {
 	if rs.Term != "" {
 		cmd.Env = append(cmd.Env, "TERM="+rs.Term)
 	}
-	if msg.Ucred.Uid != 0 && msg.Ucred.Gid != 0 {
-		if st.user != nil && st.user.HomeDir != "" {
+	if rs.Pwd != "" {
+		if fi, err := os.Stat(rs.Pwd); err == nil && fi.IsDir() {
+			cmd.Dir = rs.Pwd
+		} else {
+			st.log.Warning("Shell working directory (%s) does not exist in the sandbox, using the home directory", rs.Pwd)
+		}
+	}
+	if cmd.Dir == "" && msg.Ucred.Uid != 0 && msg.Ucred.Gid != 0 {
+		if hasHomeDir(st.user) {
 			cmd.Dir = st.user.HomeDir
 		}
 	}
 	cmd.Env = append(cmd.Env, fmt.Sprintf("PS1=[%s] $ ", st.profile.Name))
+	cmd.Env = overrideEnv(cmd.Env, envVars(st.profile.Environment, st.launchEnv))
 	st.log.Info("Executing shell...")
-	f, err := ptyStart(cmd)
+	st.intoAppsCgroup(cmd)
+	f, err := ptyStartWith(cmd, st.startNoNewPrivs)
+	if err != nil {
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	// The response carries a duplicate of the master, closing ours leaves the
+	// client the only holder so the shell gets SIGHUP once it hangs up
 	defer f.Close()
+	st.addChildProcess(cmd, false)
+	return msg.Respond(&OkMsg{}, int(f.Fd()))
+}
+
+func (st *initState) handleRunDebugger(rd *RunDebuggerMsg, msg *ipc.Message) error {
+	if !st.profile.AllowPtrace {
+		return msg.Respond(&ErrorMsg{"Cannot attach debugger because allow_ptrace is disabled in profile"})
+	}
+	if msg.Ucred == nil {
+		return msg.Respond(&ErrorMsg{"No credentials received for RunDebugger command"})
+	}
+	if (msg.Ucred.Uid == 0 || msg.Ucred.Gid == 0) && st.config.AllowRootShell != true {
+		return msg.Respond(&ErrorMsg{"Cannot attach debugger because allowRootShell is disabled"})
+	}
+	st.lock.Lock()
+	_, known := st.children[rd.Pid]
+	st.lock.Unlock()
+	if !known {
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("No tracked process with pid %d", rd.Pid)})
+	}
+	st.log.Warning("Attaching debugger (%s) to pid %d, process isolation is reduced!", st.config.DebuggerPath, rd.Pid)
+	cmd := exec.Command(st.config.DebuggerPath, "-p", strconv.Itoa(rd.Pid))
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	groups := st.userGroups()
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    st.uid,
+		Gid:    st.gid,
+		Groups: groups,
+	}
+	cmd.Env = append(cmd.Env, st.launchEnv...)
+	if rd.Term != "" {
+		cmd.Env = append(cmd.Env, "TERM="+rd.Term)
+	}
+	st.intoAppsCgroup(cmd)
+	f, err := ptyStart(cmd)
 	if err != nil {
 		return msg.Respond(&ErrorMsg{err.Error()})
 	}
+	defer f.Close()
 	st.addChildProcess(cmd, false)
-	err = msg.Respond(&OkMsg{}, int(f.Fd()))
-	return err
+	return msg.Respond(&OkMsg{}, int(f.Fd()))
 }
 
 func ptyStart(c *exec.Cmd) (ptty *os.File, err error) {
+	return ptyStartWith(c, (*exec.Cmd).Start)
+}
+
+// ptyStartWith attaches c to a new pty and starts it with start
+func ptyStartWith(c *exec.Cmd, start func(*exec.Cmd) error) (ptty *os.File, err error) {
 	ptty, tty, err := pty.Open()
 	if err != nil {
 		return nil, err
@@ -710,7 +1451,7 @@ func ptyStart(c *exec.Cmd) (ptty *os.File, err error) {
 	}
 	c.SysProcAttr.Setctty = true
 	c.SysProcAttr.Setsid = true
-	if err := c.Start(); err != nil {
+	if err := start(c); err != nil {
 		ptty.Close()
 		return nil, err
 	}
@@ -720,7 +1461,8 @@ func ptyStart(c *exec.Cmd) (ptty *os.File, err error) {
 func (st *initState) addChildProcess(cmd *exec.Cmd, track bool) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
-	st.children[cmd.Process.Pid] = procState{cmd: cmd, track: track}
+	st.childSeq++
+	st.children[cmd.Process.Pid] = procState{cmd: cmd, track: track, seq: st.childSeq}
 }
 
 func (st *initState) removeChildProcess(pid int) bool {
@@ -728,6 +1470,14 @@ func (st *initState) removeChildProcess(pid int) bool {
 	defer st.lock.Unlock()
 	if _, ok := st.children[pid]; ok {
 		delete(st.children, pid)
+		if exited, ok := st.childExits[pid]; ok {
+			close(exited)
+			delete(st.childExits, pid)
+		}
+		if len(st.children) == 0 && st.childrenDrained != nil {
+			close(st.childrenDrained)
+			st.childrenDrained = nil
+		}
 		return true
 	}
 	return false
@@ -735,8 +1485,26 @@ func (st *initState) removeChildProcess(pid int) bool {
 
 func (st *initState) handleChildExit(pid int, wstatus syscall.WaitStatus) {
 	st.log.Debug("Child process pid=%d exited from init with status %d", pid, wstatus.ExitStatus())
-	track := st.children[pid].track
+	waited := st.exits.notify(pid, wstatus)
+	proc, known := st.children[pid]
+	track := proc.track
+	if known && track && wstatus.Signaled() && st.profile.CaptureCrashDiagnostics {
+		st.writeCrashDiagnostics(proc, wstatus)
+	}
+	st.reaped.add(pid, wstatus, !known && !waited)
+	if !known && !waited {
+		st.log.Debug("Reaped orphan process pid=%d", pid)
+	}
 	st.removeChildProcess(pid)
+	st.startIdleTimer()
+
+	// Keep a pre-warmed sandbox alive until it has run a program
+	st.lock.Lock()
+	prewarmed := st.prewarmed
+	st.lock.Unlock()
+	if prewarmed {
+		return
+	}
 
 	for _, proc := range st.children {
 		if proc.track {
@@ -791,11 +1559,24 @@ func (st *initState) getProcessExists(pnames []string) bool {
 	return false
 }
 
-func (st *initState) processSignals(c <-chan os.Signal, s *ipc.MsgServer) {
+func (st *initState) processSignals(c chan os.Signal, done <-chan struct{}) {
+	defer signal.Stop(c)
 	for {
-		sig := <-c
-		st.log.Info("Received signal (%v)", sig)
+		select {
+		case sig := <-c:
+			st.handleSignal(sig)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (st *initState) handleSignal(sig os.Signal) {
+	st.log.Info("Received signal (%v)", sig)
+	if st.shutdownSignals[sig] {
 		st.shutdown()
+	} else {
+		st.forwardSignal(sig)
 	}
 }
 
@@ -804,36 +1585,164 @@ func (st *initState) shutdown() {
 		return
 	}
 	st.shutdownRequested = true
+	children := st.childrenVector()
+	switch st.profile.ShutdownOrder {
+	case "xpra-first":
+		st.shutdownXpra()
+		signalChildren(children, os.Interrupt)
+	case "reverse-launch-order":
+		// Interrupted one at a time below, as waiting for each of them to
+		// exit cannot be done from the reaper
+	default:
+		signalChildren(children, os.Interrupt)
+		st.shutdownXpra()
+	}
+
+	// Children are removed by the reaper, which may be the caller
+	go func() {
+		if st.profile.ShutdownOrder == "reverse-launch-order" {
+			st.interruptInReverseOrder(children)
+			st.shutdownXpra()
+		}
+		st.waitChildrenExit()
+		st.removeAppsCgroup()
+
+		if st.ipcServer != nil {
+			st.ipcServer.Close()
+		}
+		st.workers.Stop()
+	}()
+}
+
+func (st *initState) shutdownGrace() time.Duration {
+	grace := time.Duration(st.config.ShutdownGraceSeconds) * time.Second
+	if grace <= 0 {
+		grace = DEFAULT_SHUTDOWN_GRACE
+	}
+	return grace
+}
+
+// waitChildrenExit waits for the children to exit, those still running after
+// the shutdown grace period are killed.
+func (st *initState) waitChildrenExit() {
+	st.lock.Lock()
+	if len(st.children) == 0 {
+		st.lock.Unlock()
+		return
+	}
+	drained := make(chan struct{})
+	st.childrenDrained = drained
+	st.lock.Unlock()
+
+	grace := st.shutdownGrace()
+	select {
+	case <-drained:
+		return
+	case <-time.After(grace):
+	}
 	for _, c := range st.childrenVector() {
+		st.log.Warning("Killing process pid=%d (%s) which did not exit within %v of shutdown",
+			c.cmd.Process.Pid, strings.Join(c.cmd.Args, " "), grace)
+		c.cmd.Process.Kill()
+	}
+}
+
+// interruptInReverseOrder interrupts the children from the most recently
+// launched to the first one, each of them once the previous one exited or
+// the shutdown grace period elapsed.
+func (st *initState) interruptInReverseOrder(children []procState) {
+	grace := st.shutdownGrace()
+	for i := len(children) - 1; i >= 0; i-- {
+		c := children[i]
+		exited := st.childExit(c.cmd.Process.Pid)
 		c.cmd.Process.Signal(os.Interrupt)
+		select {
+		case <-exited:
+		case <-time.After(grace):
+			st.log.Warning("Process pid=%d (%s) did not exit within %v of its interrupt, interrupting the next one",
+				c.cmd.Process.Pid, strings.Join(c.cmd.Args, " "), grace)
+		}
 	}
+}
 
-	st.shutdownXpra()
+// childExit returns a channel closed once the reaper collected the child pid
+func (st *initState) childExit(pid int) <-chan struct{} {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if exited, ok := st.childExits[pid]; ok {
+		return exited
+	}
+	exited := make(chan struct{})
+	if _, ok := st.children[pid]; !ok {
+		close(exited)
+		return exited
+	}
+	if st.childExits == nil {
+		st.childExits = make(map[int]chan struct{})
+	}
+	st.childExits[pid] = exited
+	return exited
+}
 
-	if st.ipcServer != nil {
-		st.ipcServer.Close()
+func signalChildren(children []procState, sig os.Signal) {
+	for _, c := range children {
+		c.cmd.Process.Signal(sig)
 	}
 }
 
+// shutdownXpra stops the xpra servers of all the displays at once
 func (st *initState) shutdownXpra() {
-	if st.xpra == nil {
-		return
+	st.lock.Lock()
+	xpras := make(map[int]*xpra.Xpra, len(st.xpras))
+	for display, x := range st.xpras {
+		xpras[display] = x
 	}
+	st.lock.Unlock()
+	var wg sync.WaitGroup
+	for display, x := range xpras {
+		wg.Add(1)
+		go func(display int, x *xpra.Xpra) {
+			defer wg.Done()
+			st.stopXpra(display, x)
+		}(display, x)
+	}
+	wg.Wait()
+}
+
+func (st *initState) stopXpra(display int, x *xpra.Xpra) {
 	creds := &syscall.Credential{
 		Uid: uint32(st.uid),
 		Gid: uint32(st.gid),
 	}
-	out, err := st.xpra.Stop(creds)
-	if err != nil {
-		st.log.Warning("Error running xpra stop: %v", err)
+	timeout := time.Duration(st.config.XpraStopTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DEFAULT_XPRA_STOP_TIMEOUT
+	}
+
+	out, err := x.Stop(creds, timeout)
+	if err == xpra.ErrStopTimeout {
+		st.log.Warning("Xpra did not stop on display :%d after %v, killing it", display, timeout)
+		if x.Process != nil && x.Process.Process != nil {
+			if err := x.Process.Process.Kill(); err != nil {
+				st.log.Warning("Failed to kill xpra: %v", err)
+			}
+		}
+		return
+	} else if err != nil {
+		st.log.Warning("Error running xpra stop on display :%d: %v", display, err)
 		return
 	}
+	st.log.Info("Xpra stopped gracefully on display :%d", display)
 
+	xlog := subsystemLogger("xpra")
 	for _, line := range strings.Split(string(out), "\n") {
 		if len(line) > 0 {
-			st.log.Debug("(xpra stop) %s", line)
+			xlog.Debug("(xpra stop) %s", line)
 		}
 	}
+	if st.config.CleanXpraWorkdir {
+		st.removeXpraWorkdir(x.WorkDir, display)
+	}
 }
 
 func (st *initState) childrenVector() []procState {
@@ -843,14 +1752,21 @@ func (st *initState) childrenVector() []procState {
 	for _, v := range st.children {
 		cs = append(cs, v)
 	}
+	sort.Sort(procsByLaunch(cs))
 	return cs
 }
 
+type procsByLaunch []procState
+
+func (p procsByLaunch) Len() int           { return len(p) }
+func (p procsByLaunch) Less(i, j int) bool { return p[i].seq < p[j].seq }
+func (p procsByLaunch) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
 func (st *initState) setupFilesystem(extra_whitelist []oz.WhitelistItem, extra_blacklist []oz.BlacklistItem) error {
 
 	//	fs := fs.NewFilesystem(st.config, st.log)
 
-	if err := setupRootfs(st.fs, st.user, st.uid, st.gid, st.display, st.config.UseFullDev, st.log, st.config.EtcIncludes); err != nil {
+	if err := setupRootfs(st.fs, st.user, st.uid, st.gid, st.display, st.profile.TmpfsSizeMB, st.config.UseFullDev, st.profile.AllowFuse, st.log, st.etcIncludes()); err != nil {
 		return err
 	}
 
@@ -866,15 +1782,17 @@ func (st *initState) setupFilesystem(extra_whitelist []oz.WhitelistItem, extra_b
 		}
 	}
 
-	if err := st.bindWhitelist(st.fs, extra_whitelist); err != nil {
+	// The items of the profile come last so they win over the extra items
+	wlist := append(append([]oz.WhitelistItem{}, extra_whitelist...), st.profile.Whitelist...)
+	if err := st.bindWhitelist(st.fs, wlist); err != nil {
 		return err
 	}
 
-	if err := st.bindWhitelist(st.fs, st.profile.Whitelist); err != nil {
+	if err := st.createBindSymlinks(st.fs, append(st.profile.Whitelist, extra_whitelist...)); err != nil {
 		return err
 	}
 
-	if err := st.createBindSymlinks(st.fs, append(st.profile.Whitelist, extra_whitelist...)); err != nil {
+	if err := st.setupFileCapabilities(st.fs); err != nil {
 		return err
 	}
 
@@ -891,11 +1809,25 @@ func (st *initState) setupFilesystem(extra_whitelist []oz.WhitelistItem, extra_b
 		if err != nil {
 			return err
 		}
-		if err := st.fs.BindPath(xprapath, 0, st.display); err != nil {
+		xflags := 0
+		if st.profile.NoExecWritable {
+			xflags |= fs.BindNoExec
+		}
+		if err := st.fs.BindPath(xprapath, xflags, st.display); err != nil {
 			return err
 		}
 	}
 
+	// Kept to bind whitelist items added once the sandbox runs, pivot_root
+	// detaches the host root so it must not be kept reachable
+	if !st.config.UsePivotRoot {
+		hostRoot, err := os.Open("/")
+		if err != nil {
+			return err
+		}
+		st.hostRoot = hostRoot
+	}
+
 	if err := st.fs.Chroot(); err != nil {
 		return err
 	}
@@ -908,7 +1840,22 @@ func (st *initState) setupFilesystem(extra_whitelist []oz.WhitelistItem, extra_b
 	if st.profile.NoSysProc != true {
 		mo.add(st.fs.MountProc, st.fs.MountSys)
 	}
-	return mo.run()
+	if err := mo.run(); err != nil {
+		return err
+	}
+
+	if st.profile.NoExecWritable {
+		writable := []string{"/tmp", "/dev/shm"}
+		if hasHomeDir(st.user) {
+			writable = append(writable, st.user.HomeDir)
+		}
+		for _, p := range writable {
+			if err := st.fs.RemountNoExec(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (st *initState) createBindSymlinks(fsys *fs.Filesystem, wlist []oz.WhitelistItem) error {
@@ -949,7 +1896,7 @@ func (st *initState) bindWhitelist(fsys *fs.Filesystem, wlist []oz.WhitelistItem
 	if wlist == nil {
 		return nil
 	}
-	for _, wl := range wlist {
+	for _, wl := range st.dedupWhitelist(fsys, wlist) {
 		flags := 0
 		if wl.CanCreate {
 			flags |= fs.BindCanCreate
@@ -970,6 +1917,18 @@ func (st *initState) bindWhitelist(fsys *fs.Filesystem, wlist []oz.WhitelistItem
 		if wl.NoFollow {
 			flags |= fs.BindNoFollow
 		}
+		if wl.PreserveMountFlags {
+			flags |= fs.BindPreserveMountFlags
+		}
+		if wl.PreserveXattrs {
+			flags |= fs.BindPreserveXattrs
+		}
+		if wl.Recursive {
+			flags |= fs.BindRecursive
+		}
+		if st.profile.NoExecWritable && flags&fs.BindReadOnly == 0 {
+			flags |= fs.BindNoExec
+		}
 		if wl.Path == "" {
 			continue
 		}
@@ -0,0 +1,48 @@
+package ozinit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// jsonLogBackend writes each log message as a JSON object on its own line,
+// oz-daemon reads them line by line as with the text format.
+type jsonLogBackend struct {
+	lock    sync.Mutex
+	w       io.Writer
+	profile string
+}
+
+type jsonLogRecord struct {
+	Level   string    `json:"level"`
+	Msg     string    `json:"msg"`
+	Profile string    `json:"profile"`
+	Ts      time.Time `json:"ts"`
+}
+
+func (b *jsonLogBackend) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	line, err := json.Marshal(&jsonLogRecord{
+		Level:   level.String(),
+		Msg:     rec.Message(),
+		Profile: b.profile,
+		Ts:      rec.Time,
+	})
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	_, err = b.w.Write(append(line, '\n'))
+	return err
+}
+
+// setJSONLogBackend replaces the text format of createLogger once the
+// profile is known, for log_format json. The other backends, like the recent
+// lines kept for debug dumps, keep receiving the messages.
+func setJSONLogBackend(w io.Writer, profile string, others ...logging.Backend) {
+	logging.SetBackend(append([]logging.Backend{&jsonLogBackend{w: w, profile: profile}}, others...)...)
+}
@@ -0,0 +1,37 @@
+package ozinit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestJSONLogBackend(t *testing.T) {
+	var buf bytes.Buffer
+	setJSONLogBackend(&buf, "test")
+	defer createLogger()
+	log := logging.MustGetLogger("oz-init")
+	log.Warning("first %d", 1)
+	log.Info("multi\nline")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expecting one line per message, got %q", buf.String())
+	}
+	var rec jsonLogRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Level != "WARNING" || rec.Msg != "first 1" || rec.Profile != "test" || rec.Ts.IsZero() {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Level != "INFO" || rec.Msg != "multi\nline" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
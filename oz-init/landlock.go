@@ -0,0 +1,218 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/fs"
+)
+
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	oPath = 0x200000
+)
+
+// Filesystem access rights of the first landlock ABI
+const (
+	llAccessExecute = 1 << iota
+	llAccessWriteFile
+	llAccessReadFile
+	llAccessReadDir
+	llAccessRemoveDir
+	llAccessRemoveFile
+	llAccessMakeChar
+	llAccessMakeDir
+	llAccessMakeReg
+	llAccessMakeSock
+	llAccessMakeFifo
+	llAccessMakeBlock
+	llAccessMakeSym
+
+	llAccessAll      = (llAccessMakeSym << 1) - 1
+	llAccessFileOnly = llAccessExecute | llAccessWriteFile | llAccessReadFile
+)
+
+var landlockAccess = map[string]uint64{
+	"read":    llAccessReadFile | llAccessReadDir,
+	"write":   llAccessWriteFile | llAccessRemoveDir | llAccessRemoveFile | llAccessMakeChar | llAccessMakeDir | llAccessMakeReg | llAccessMakeSock | llAccessMakeFifo | llAccessMakeBlock | llAccessMakeSym,
+	"execute": llAccessExecute,
+}
+
+type landlockRulesetAttr struct {
+	handledAccessFs uint64
+}
+
+func landlockABIVersion() (int, error) {
+	r, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+func landlockRuleAccess(access []string) (uint64, error) {
+	var mask uint64
+	for _, a := range access {
+		m, ok := landlockAccess[strings.ToLower(a)]
+		if !ok {
+			return 0, fmt.Errorf("unknown landlock access (%s), must be one of read, write, execute", a)
+		}
+		mask |= m
+	}
+	return mask, nil
+}
+
+func landlockAddPathRule(ruleset int, p string, access uint64) error {
+	fd, err := syscall.Open(p, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		access &= llAccessFileOnly
+	}
+	// struct landlock_path_beneath_attr is packed: allowed_access and parent_fd
+	var attr [12]byte
+	*(*uint64)(unsafe.Pointer(&attr[0])) = access
+	*(*int32)(unsafe.Pointer(&attr[8])) = int32(fd)
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// landlockRestrictThread restricts the calling thread, and every process it
+// creates afterwards, to the path rules. The restriction can never be lifted.
+func landlockRestrictThread(rules []oz.LandlockRule, resolve func(string) (string, error)) error {
+	attr := landlockRulesetAttr{handledAccessFs: llAccessAll}
+	r, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("unable to create landlock ruleset: %v", errno)
+	}
+	ruleset := int(r)
+	defer syscall.Close(ruleset)
+
+	for _, rule := range rules {
+		access, err := landlockRuleAccess(rule.Access)
+		if err != nil {
+			return err
+		}
+		p, err := resolve(rule.Path)
+		if err != nil {
+			return fmt.Errorf("unable to resolve landlock path (%s): %v", rule.Path, err)
+		}
+		if err := landlockAddPathRule(ruleset, p, access); err != nil {
+			return fmt.Errorf("unable to add landlock rule for %s: %v", p, err)
+		}
+	}
+	_, _, errno = syscall.Syscall(sysLandlockRestrictSelf, uintptr(ruleset), 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("unable to enforce landlock ruleset: %v", errno)
+	}
+	return nil
+}
+
+// startRestricted starts cmd restricted by the landlock rules of the profile,
+// without the capabilities it drops, in a new empty network namespace when
+// isolateNet is set and with no_new_privs when enabled. Restrictions apply to
+// a dedicated thread which forks the command and is then discarded, as oz-init
+// itself must keep its filesystem and network access and its capabilities.
+func (st *initState) startRestricted(cmd *exec.Cmd, isolateNet bool) error {
+	dropCaps := st.applicationCapabilities()
+	noNewPrivs := st.profile.NoNewPrivsEnabled()
+	useLandlock := len(st.profile.Landlock) > 0
+	if useLandlock {
+		v, err := landlockABIVersion()
+		if err != nil {
+			st.log.Warning("Landlock is not supported by the kernel (%v), starting application without landlock rules", err)
+			useLandlock = false
+		} else {
+			st.log.Debug("Landlock ABI version %d", v)
+		}
+	}
+	if !useLandlock && !isolateNet && len(dropCaps) == 0 && !noNewPrivs {
+		return st.startWithSchedPolicy(cmd)
+	}
+
+	// exec opens /dev/null for missing standard streams from the forking
+	// thread, which the ruleset may not allow
+	if useLandlock && (cmd.Stdin == nil || cmd.Stdout == nil || cmd.Stderr == nil) {
+		devnull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer devnull.Close()
+		if cmd.Stdin == nil {
+			cmd.Stdin = devnull
+		}
+		if cmd.Stdout == nil {
+			cmd.Stdout = devnull
+		}
+		if cmd.Stderr == nil {
+			cmd.Stderr = devnull
+		}
+	}
+	resolve := func(p string) (string, error) {
+		return fs.ResolvePathNoGlob(p, -1, st.user, st.fs.GetXDGDirs(), st.profile)
+	}
+	res := make(chan error)
+	go func() {
+		// Never unlocked, the thread exits with this goroutine
+		runtime.LockOSThread()
+		if len(dropCaps) > 0 {
+			if err := dropThreadCapabilities(dropCaps); err != nil {
+				res <- err
+				return
+			}
+		}
+		if noNewPrivs {
+			if err := setThreadNoNewPrivs(); err != nil {
+				res <- err
+				return
+			}
+		}
+		if isolateNet {
+			if err := isolateThreadNetwork(); err != nil {
+				res <- err
+				return
+			}
+		}
+		if useLandlock {
+			if err := landlockRestrictThread(st.profile.Landlock, resolve); err != nil {
+				res <- err
+				return
+			}
+		}
+		res <- st.startWithSchedPolicy(cmd)
+	}()
+	if err := <-res; err != nil {
+		return err
+	}
+	if len(dropCaps) > 0 {
+		st.log.Info("Application started without capabilities: %s", strings.Join(dropCaps, ", "))
+	}
+	if isolateNet {
+		st.log.Info("Application started in an isolated network namespace")
+	}
+	if useLandlock {
+		st.log.Info("Application started with %d landlock rules", len(st.profile.Landlock))
+	}
+	return nil
+}
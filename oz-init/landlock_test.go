@@ -0,0 +1,71 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestLandlockRuleAccess(t *testing.T) {
+	mask, err := landlockRuleAccess([]string{"read", "execute"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mask != llAccessReadFile|llAccessReadDir|llAccessExecute {
+		t.Errorf("unexpected access mask for read and execute: %#x", mask)
+	}
+	if _, err := landlockRuleAccess([]string{"delete"}); err == nil {
+		t.Error("expecting an error for an unknown access")
+	}
+}
+
+func TestLandlockRestrictThread(t *testing.T) {
+	if _, err := landlockABIVersion(); err != nil {
+		t.Skipf("landlock is not supported: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "oz-init-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ro := path.Join(dir, "ro")
+	rw := path.Join(dir, "rw")
+	for _, d := range []string{ro, rw} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := []oz.LandlockRule{
+		{Path: ro, Access: []string{"read"}},
+		{Path: rw, Access: []string{"read", "write"}},
+	}
+	res := make(chan [3]error)
+	go func() {
+		// The restricted thread is discarded with this goroutine
+		runtime.LockOSThread()
+		if err := landlockRestrictThread(rules, func(p string) (string, error) { return p, nil }); err != nil {
+			res <- [3]error{err, nil, nil}
+			return
+		}
+		res <- [3]error{
+			nil,
+			ioutil.WriteFile(path.Join(ro, "data"), []byte("x"), 0644),
+			ioutil.WriteFile(path.Join(rw, "data"), []byte("x"), 0644),
+		}
+	}()
+	errs := <-res
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	if errs[1] == nil {
+		t.Error("expecting write outside of the writable rules to fail")
+	}
+	if errs[2] != nil {
+		t.Errorf("expecting write allowed by a rule to succeed: %v", errs[2])
+	}
+}
@@ -0,0 +1,53 @@
+package ozinit
+
+import (
+	"sync"
+	"time"
+)
+
+// Time to wait for background goroutines to return once shutdown is requested
+const workerJoinTimeout = 5 * time.Second
+
+// workerGroup tracks the background goroutines of oz-init so they can be
+// cancelled and joined when the sandbox shuts down.
+type workerGroup struct {
+	wg   sync.WaitGroup
+	done chan struct{}
+	once sync.Once
+}
+
+func newWorkerGroup() *workerGroup {
+	return &workerGroup{done: make(chan struct{})}
+}
+
+// Go runs fn in a new goroutine, fn must return once done is closed.
+func (w *workerGroup) Go(fn func(done <-chan struct{})) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.done)
+	}()
+}
+
+// Stop requests all workers to return, it is safe to call more than once.
+func (w *workerGroup) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
+
+// Wait joins all workers and returns false if some are still running
+// after timeout.
+func (w *workerGroup) Wait(timeout time.Duration) bool {
+	c := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(c)
+	}()
+	select {
+	case <-c:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,295 @@
+package ozinit
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
+
+func TestWorkerGroupStop(t *testing.T) {
+	w := newWorkerGroup()
+	for i := 0; i < 4; i++ {
+		w.Go(func(done <-chan struct{}) {
+			<-done
+		})
+	}
+	w.Stop()
+	w.Stop()
+	if !w.Wait(time.Second) {
+		t.Error("workers did not return after Stop")
+	}
+}
+
+func TestWorkerGroupWaitTimeout(t *testing.T) {
+	w := newWorkerGroup()
+	block := make(chan struct{})
+	w.Go(func(<-chan struct{}) {
+		<-block
+	})
+	w.Stop()
+	if w.Wait(10 * time.Millisecond) {
+		t.Error("expecting Wait to time out on a blocked worker")
+	}
+	close(block)
+	if !w.Wait(time.Second) {
+		t.Error("worker did not return once unblocked")
+	}
+}
+
+func TestShutdownJoinsWorkers(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	st := &initState{
+		log:      logging.MustGetLogger("oz-init-test"),
+		profile:  &oz.Profile{ReadyPattern: "listening"},
+		children: make(map[int]procState),
+		workers:  newWorkerGroup(),
+	}
+	if err := st.setupReadyPattern(); err != nil {
+		t.Fatal(err)
+	}
+	sigs := make(chan os.Signal)
+	st.workers.Go(func(done <-chan struct{}) {
+		st.processSignals(sigs, done)
+	})
+	st.startReadyTimeout()
+	r, w := io.Pipe()
+	st.workers.Go(func(<-chan struct{}) {
+		st.readApplicationOutput(r, "stdout", nil)
+	})
+
+	io.WriteString(w, "still starting\n")
+	w.Close()
+	st.shutdown()
+	if !st.workers.Wait(time.Second) {
+		t.Fatal("workers did not return after shutdown")
+	}
+
+	// Goroutines may take a moment to be accounted as exited
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("expecting at most %d goroutines after shutdown, got %d", before, n)
+	}
+}
+
+func TestChildrenVectorLaunchOrder(t *testing.T) {
+	st := &initState{children: make(map[int]procState)}
+	pids := []int{300, 20, 4000, 1}
+	for _, pid := range pids {
+		st.addChildProcess(&exec.Cmd{Process: &os.Process{Pid: pid}}, false)
+	}
+	children := st.childrenVector()
+	if len(children) != len(pids) {
+		t.Fatalf("expecting %d children, got %d", len(pids), len(children))
+	}
+	for i, c := range children {
+		if c.cmd.Process.Pid != pids[i] {
+			t.Errorf("expecting child %d to be pid %d, got %d", i, pids[i], c.cmd.Process.Pid)
+		}
+	}
+}
+
+func TestWaitChildrenExit(t *testing.T) {
+	config := oz.NewDefaultConfig()
+	config.ShutdownGraceSeconds = 1
+	st := &initState{
+		log:      logging.MustGetLogger("oz-init-test"),
+		config:   config,
+		children: make(map[int]procState),
+	}
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start sleep: %v", err)
+	}
+	st.addChildProcess(cmd, true)
+
+	// A child exiting within the grace period is left alone
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.removeChildProcess(cmd.Process.Pid)
+	}()
+	start := time.Now()
+	st.waitChildrenExit()
+	if time.Since(start) >= time.Second {
+		t.Error("expecting the wait to end once the children exited")
+	}
+
+	// Survivors are killed once the grace period elapsed
+	st.addChildProcess(cmd, true)
+	st.waitChildrenExit()
+	err := cmd.Wait()
+	if ee, ok := err.(*exec.ExitError); !ok || ee.Sys().(syscall.WaitStatus).Signal() != syscall.SIGKILL {
+		t.Errorf("expecting the surviving child to be killed, got %v", err)
+	}
+}
+
+func TestInterruptInReverseOrder(t *testing.T) {
+	config := oz.NewDefaultConfig()
+	config.ShutdownGraceSeconds = 5
+	st := &initState{
+		log:      logging.MustGetLogger("oz-init-test"),
+		config:   config,
+		children: make(map[int]procState),
+	}
+	// The last one takes a moment to exit, the first one would otherwise
+	// exit before it when interrupted at the same time
+	first := exec.Command("sleep", "10")
+	last := exec.Command("sh", "-c", "trap 'sleep 0.2; exit 0' INT; echo ready; while true; do sleep 0.05; done")
+	out, err := last.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exits := make(chan *exec.Cmd, 2)
+	for _, cmd := range []*exec.Cmd{first, last} {
+		if err := cmd.Start(); err != nil {
+			t.Skipf("unable to start %s: %v", cmd.Path, err)
+		}
+		defer cmd.Process.Kill()
+		st.addChildProcess(cmd, true)
+	}
+	// Interrupted once its trap is set
+	if _, err := out.Read(make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []*exec.Cmd{first, last} {
+		// Stands in for the reaper
+		go func(cmd *exec.Cmd) {
+			cmd.Wait()
+			exits <- cmd
+			st.removeChildProcess(cmd.Process.Pid)
+		}(cmd)
+	}
+	st.interruptInReverseOrder(st.childrenVector())
+	for _, expected := range []*exec.Cmd{last, first} {
+		select {
+		case cmd := <-exits:
+			if cmd != expected {
+				t.Errorf("expecting pid %d to exit before pid %d", expected.Process.Pid, cmd.Process.Pid)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expecting the interrupted children to exit")
+		}
+	}
+}
+
+func TestPtyHangupOnClientClose(t *testing.T) {
+	const addr = "@oz-init-test-pty"
+	cmd := exec.Command("sh", "-c", "read line; echo got $line; sleep 10")
+	// Sends the master like handleRunShell, closing its copy once sent
+	handler := func(rs *RunShellMsg, msg *ipc.Message) error {
+		f, err := ptyStart(cmd)
+		if err != nil {
+			return msg.Respond(&ErrorMsg{err.Error()})
+		}
+		defer f.Close()
+		return msg.Respond(&OkMsg{}, int(f.Fd()))
+	}
+	s, err := ipc.NewServer(addr, messageFactory, nil, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	fd, err := RunShell(addr, "", "")
+	if err != nil {
+		t.Skipf("unable to start sh in a pty: %v", err)
+	}
+	defer cmd.Process.Kill()
+	client := os.NewFile(uintptr(fd), "pty")
+	// The descriptor received with the response still works once the
+	// sender closed its copy
+	if _, err := client.Write([]byte("hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	// The reader returns before the pty is closed, a pending read would keep
+	// it open
+	answered := make(chan bool)
+	go func() {
+		br := bufio.NewReader(client)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				answered <- false
+				return
+			}
+			if strings.TrimSpace(line) == "got hi" {
+				answered <- true
+				return
+			}
+		}
+	}()
+	select {
+	case ok := <-answered:
+		if !ok {
+			t.Fatal("pty closed before the shell answered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the shell to answer on the pty")
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	client.Close()
+	select {
+	case err := <-exited:
+		ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGHUP {
+			t.Errorf("expecting the shell to be hung up once the client closed the pty, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expecting the shell to exit once the client closed the pty")
+	}
+}
+
+func TestAcceptDelay(t *testing.T) {
+	var delay time.Duration
+	for _, expected := range []time.Duration{
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+	} {
+		delay = acceptDelay(delay)
+		if delay != expected {
+			t.Errorf("expecting a delay of %v, got %v", expected, delay)
+		}
+	}
+	if delay = acceptDelay(800 * time.Millisecond); delay != maxAcceptDelay {
+		t.Errorf("expecting the delay to be capped at %v, got %v", maxAcceptDelay, delay)
+	}
+}
+
+func TestAcceptForwardedClosedListener(t *testing.T) {
+	st := &initState{log: logging.MustGetLogger("oz-init-test")}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("unable to listen: %v", err)
+	}
+	l.Close()
+	returned := make(chan struct{})
+	go func() {
+		st.acceptForwarded(l, "tcp", "127.0.0.1:1", make(chan struct{}))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Error("expecting the forwarder to stop on a closed listener")
+	}
+}
@@ -0,0 +1,24 @@
+package ozinit
+
+import (
+	"github.com/op/go-logging"
+)
+
+// subsystemLogger returns the logger of a subsystem of oz-init, whose level
+// is set with log_levels in the oz config.
+func subsystemLogger(name string) *logging.Logger {
+	return logging.MustGetLogger("oz-init." + name)
+}
+
+// setLogLevels applies log_levels to the subsystem loggers, levels are reset
+// by logging.SetBackend so it is called once the backend is chosen.
+func setLogLevels(log *logging.Logger, levels map[string]string) {
+	for name, l := range levels {
+		level, err := logging.LogLevel(l)
+		if err != nil {
+			log.Warning("Invalid log level (%s) for %s: %v", l, name, err)
+			continue
+		}
+		logging.SetLevel(level, "oz-init."+name)
+	}
+}
@@ -0,0 +1,23 @@
+package ozinit
+
+import (
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestSetLogLevels(t *testing.T) {
+	createLogger()
+	defer createLogger()
+	log := logging.MustGetLogger("oz-init")
+	setLogLevels(log, map[string]string{"xpra": "INFO", "fs": "bogus"})
+	if subsystemLogger("xpra").IsEnabledFor(logging.DEBUG) {
+		t.Error("expecting debug messages of xpra to be disabled")
+	}
+	if !subsystemLogger("xpra").IsEnabledFor(logging.INFO) {
+		t.Error("expecting info messages of xpra to be enabled")
+	}
+	if !subsystemLogger("fs").IsEnabledFor(logging.DEBUG) || !log.IsEnabledFor(logging.DEBUG) {
+		t.Error("expecting the other loggers to keep the debug level")
+	}
+}
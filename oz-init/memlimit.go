@@ -0,0 +1,33 @@
+package ozinit
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+// applyRuntimeLimits tunes the Go runtime of oz-init itself so its memory
+// footprint stays predictable when many sandboxes run, the sandboxed
+// applications are not affected.
+func (st *initState) applyRuntimeLimits() {
+	if st.config.InitMemoryLimitMB > 0 {
+		limit := int64(st.config.InitMemoryLimitMB) << 20
+		debug.SetMemoryLimit(limit)
+		st.log.Info("oz-init memory limit set to %d MiB", st.config.InitMemoryLimitMB)
+	}
+	if st.config.InitGCPercent > 0 {
+		debug.SetGCPercent(st.config.InitGCPercent)
+		st.log.Info("oz-init GC target set to %d%%", st.config.InitGCPercent)
+	}
+}
+
+func fillMemoryStats(info *InfoMsg) {
+	// A negative input only reads the current limit
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		info.InitMemoryLimit = limit
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	info.InitMemorySys = ms.Sys
+	info.InitHeapInuse = ms.HeapInuse
+}
@@ -0,0 +1,59 @@
+package ozinit
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+)
+
+// SandboxMetadata is written as JSON to the metadata_path of the config so
+// applications can find out they run inside a sandbox and adapt.
+type SandboxMetadata struct {
+	Profile   string `json:"profile"`
+	Uid       uint32 `json:"uid"`
+	User      string `json:"user"`
+	Display   int    `json:"display"`
+	Nettype   string `json:"nettype"`
+	Ephemeral bool   `json:"ephemeral"`
+}
+
+func (st *initState) writeMetadata() error {
+	if st.config.MetadataPath == "" {
+		return nil
+	}
+	md := SandboxMetadata{
+		Profile:   st.profile.Name,
+		Uid:       st.uid,
+		Display:   st.display,
+		Nettype:   string(st.profile.Networking.Nettype),
+		Ephemeral: st.ephemeral,
+	}
+	if st.user != nil {
+		md.User = st.user.Username
+	}
+	data, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(st.config.MetadataPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(st.config.MetadataPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (st *initState) removeMetadata() {
+	if st.config.MetadataPath == "" {
+		return
+	}
+	if err := os.Remove(st.config.MetadataPath); err != nil && !os.IsNotExist(err) {
+		st.log.Warning("Failed to remove sandbox metadata file: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package ozinit
+
+import (
+	"strings"
+
+	"github.com/subgraph/oz/fs"
+	"github.com/subgraph/oz/ipc"
+)
+
+func (st *initState) handleListMounts(lm *ListMountsMsg, msg *ipc.Message) error {
+	infos, err := fs.ReadMountInfo("/proc/self/mountinfo")
+	if err != nil {
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	mounts := make([]MountEntry, 0, len(infos))
+	for _, m := range infos {
+		mounts = append(mounts, MountEntry{
+			Source:  m.Source,
+			Target:  m.MountPoint,
+			FSType:  m.FSType,
+			Options: strings.Join(m.Options, ","),
+		})
+	}
+	return msg.Respond(&ListMountsResp{Mounts: mounts})
+}
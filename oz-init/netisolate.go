@@ -0,0 +1,22 @@
+package ozinit
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/subgraph/oz/network"
+)
+
+// isolateThreadNetwork moves the calling thread to a new network namespace
+// holding only a loopback interface, the thread must be locked and discarded
+// once the isolated process is forked. There is no way back to the network
+// namespace of the sandbox for the processes started from it.
+func isolateThreadNetwork() error {
+	if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("unable to create network namespace: %v", err)
+	}
+	if err := network.NetSetup(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package ozinit
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+const PR_SET_NO_NEW_PRIVS = 38
+
+// setThreadNoNewPrivs sets no_new_privs on the calling thread, it is
+// inherited by the processes the thread forks and cannot be cleared.
+func setThreadNoNewPrivs() error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// startNoNewPrivs starts cmd with no_new_privs when enabled by the profile.
+// It is set on a dedicated thread which forks the command and is then
+// discarded, as oz-init itself must keep its privileges.
+func (st *initState) startNoNewPrivs(cmd *exec.Cmd) error {
+	if !st.profile.NoNewPrivsEnabled() {
+		return cmd.Start()
+	}
+	res := make(chan error)
+	go func() {
+		// Never unlocked, the thread exits with this goroutine
+		runtime.LockOSThread()
+		if err := setThreadNoNewPrivs(); err != nil {
+			res <- err
+			return
+		}
+		res <- cmd.Start()
+	}()
+	return <-res
+}
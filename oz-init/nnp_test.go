@@ -0,0 +1,69 @@
+package ozinit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func noNewPrivsOf(t *testing.T, st *initState) string {
+	var out bytes.Buffer
+	cmd := exec.Command("grep", "NoNewPrivs", "/proc/self/status")
+	cmd.Stdout = &out
+	if err := st.startNoNewPrivs(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Skipf("unable to read no_new_privs: %v", err)
+	}
+	return string(bytes.Fields(out.Bytes())[1])
+}
+
+func TestStartNoNewPrivs(t *testing.T) {
+	status, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(status, []byte("NoNewPrivs:\t0")) {
+		t.Skip("no_new_privs is already set on the test process")
+	}
+	st := &initState{profile: &oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_WHITELIST}}}
+	if v := noNewPrivsOf(t, st); v != "1" {
+		t.Errorf("expecting no_new_privs to default to set with seccomp, got %s", v)
+	}
+	disabled := false
+	st.profile.NoNewPrivs = &disabled
+	if v := noNewPrivsOf(t, st); v != "0" {
+		t.Errorf("expecting no_new_privs to be unset when disabled, got %s", v)
+	}
+	if status, err = ioutil.ReadFile("/proc/self/status"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(status, []byte("NoNewPrivs:\t0")) {
+		t.Error("expecting no_new_privs to be left unset on the calling process")
+	}
+}
+
+func TestNoNewPrivsEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	for _, test := range []struct {
+		profile  oz.Profile
+		expected bool
+	}{
+		{oz.Profile{}, false},
+		{oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_WHITELIST}}, true},
+		{oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_DISABLED}}, false},
+		{oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_WHITELIST}, FileCapabilities: map[string][]string{"/bin/ping": {"cap_net_raw"}}}, false},
+		// fusermount is given its capability at runtime
+		{oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_WHITELIST}, AllowFuse: true}, false},
+		{oz.Profile{NoNewPrivs: &enabled}, true},
+		{oz.Profile{Seccomp: oz.SeccompConf{Mode: oz.PROFILE_SECCOMP_WHITELIST}, NoNewPrivs: &disabled}, false},
+	} {
+		if v := test.profile.NoNewPrivsEnabled(); v != test.expected {
+			t.Errorf("expecting no_new_privs %v for %+v, got %v", test.expected, test.profile, v)
+		}
+	}
+}
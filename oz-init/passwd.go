@@ -0,0 +1,76 @@
+package ozinit
+
+import (
+	"fmt"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Host files replaced by synthetic_passwd
+var hostAccountFiles = map[string]bool{
+	"/etc/passwd": true,
+	"/etc/group":  true,
+}
+
+// etcIncludes returns the etc items bound from the host, without the account
+// databases when they are generated for the sandbox.
+func (st *initState) etcIncludes() []string {
+	if !st.config.SyntheticPasswd {
+		return st.config.EtcIncludes
+	}
+	incs := make([]string, 0, len(st.config.EtcIncludes))
+	for _, inc := range st.config.EtcIncludes {
+		if !hostAccountFiles[inc] {
+			incs = append(incs, inc)
+		}
+	}
+	return incs
+}
+
+// lookupGroupName returns the name of gid, it must be called before the root
+// is changed as the sandbox does not have the host group database.
+func lookupGroupName(gid uint32) string {
+	g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10))
+	if err != nil {
+		return ""
+	}
+	return g.Name
+}
+
+// syntheticPasswd lists root and the sandbox user only
+func (st *initState) syntheticPasswd() string {
+	home := st.user.HomeDir
+	if !hasHomeDir(st.user) {
+		home = nonexistentHome
+	}
+	shell := st.userShell
+	if shell == "" {
+		shell = st.config.ShellPath
+	}
+	gecos := strings.Replace(st.user.Name, ":", " ", -1)
+	return fmt.Sprintf("root:x:0:0:root:/root:/bin/sh\n%s:x:%d:%d:%s:%s:%s\n",
+		st.user.Username, st.uid, st.gid, gecos, home, shell)
+}
+
+// syntheticGroup lists root, the primary group of the sandbox user and the
+// groups granted to the sandbox with the user as member.
+func (st *initState) syntheticGroup() string {
+	name := st.groupName
+	if name == "" {
+		name = st.user.Username
+	}
+	lines := []string{"root:x:0:", fmt.Sprintf("%s:x:%d:", name, st.gid)}
+	names := make([]string, 0, len(st.gids))
+	for gname, gid := range st.gids {
+		if gid != st.gid && gid != 0 {
+			names = append(names, gname)
+		}
+	}
+	sort.Strings(names)
+	for _, gname := range names {
+		lines = append(lines, fmt.Sprintf("%s:x:%d:%s", gname, st.gids[gname], st.user.Username))
+	}
+	return strings.Join(lines, "\n")
+}
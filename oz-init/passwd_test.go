@@ -0,0 +1,74 @@
+package ozinit
+
+import (
+	"os/user"
+	"reflect"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestEtcIncludes(t *testing.T) {
+	includes := []string{"/etc/fonts/", "/etc/group", "/etc/passwd"}
+	for _, test := range []struct {
+		synthetic bool
+		expected  []string
+	}{
+		{false, includes},
+		{true, []string{"/etc/fonts/"}},
+	} {
+		st := &initState{config: &oz.Config{SyntheticPasswd: test.synthetic, EtcIncludes: includes}}
+		if incs := st.etcIncludes(); !reflect.DeepEqual(incs, test.expected) {
+			t.Errorf("expecting %v with synthetic_passwd %v, got %v", test.expected, test.synthetic, incs)
+		}
+	}
+	if !oz.NewDefaultConfig().SyntheticPasswd {
+		t.Error("expecting synthetic_passwd to be enabled by default")
+	}
+}
+
+func TestSyntheticPasswd(t *testing.T) {
+	for _, test := range []struct {
+		home     string
+		shell    string
+		expected string
+	}{
+		{"/home/alice", "", "root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000:Alice:/home/alice:/bin/bash\n"},
+		{"/home/alice", "/bin/zsh", "root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000:Alice:/home/alice:/bin/zsh\n"},
+		{"", "/usr/sbin/nologin", "root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000:Alice:/nonexistent:/usr/sbin/nologin\n"},
+	} {
+		st := &initState{
+			config:    &oz.Config{ShellPath: "/bin/bash"},
+			user:      &user.User{Username: "alice", Name: "Alice", HomeDir: test.home},
+			uid:       1000,
+			gid:       1000,
+			userShell: test.shell,
+		}
+		if p := st.syntheticPasswd(); p != test.expected {
+			t.Errorf("unexpected passwd for home %q and shell %q:\n%s", test.home, test.shell, p)
+		}
+	}
+}
+
+func TestSyntheticGroup(t *testing.T) {
+	for _, test := range []struct {
+		groupName string
+		gids      map[string]uint32
+		expected  string
+	}{
+		{"alice", nil, "root:x:0:\nalice:x:1000:"},
+		{"", nil, "root:x:0:\nalice:x:1000:"},
+		{"staff", map[string]uint32{"video": 44, "audio": 29}, "root:x:0:\nstaff:x:1000:\naudio:x:29:alice\nvideo:x:44:alice"},
+		{"alice", map[string]uint32{"alice": 1000, "root": 0}, "root:x:0:\nalice:x:1000:"},
+	} {
+		st := &initState{
+			user:      &user.User{Username: "alice"},
+			gid:       1000,
+			gids:      test.gids,
+			groupName: test.groupName,
+		}
+		if g := st.syntheticGroup(); g != test.expected {
+			t.Errorf("unexpected group for %q and %v:\n%s", test.groupName, test.gids, g)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// preloadEnv returns the LD_PRELOAD variable loading the libraries, which
+// must be regular files inside the sandbox.
+func preloadEnv(libs []string) (string, error) {
+	for _, lib := range libs {
+		fi, err := os.Stat(lib)
+		if err != nil {
+			return "", fmt.Errorf("preload library (%s) does not exist inside the sandbox", lib)
+		}
+		if !fi.Mode().IsRegular() {
+			return "", fmt.Errorf("preload library (%s) is not a regular file", lib)
+		}
+	}
+	return "LD_PRELOAD=" + strings.Join(libs, ":"), nil
+}
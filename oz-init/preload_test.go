@@ -0,0 +1,35 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPreloadEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-preload-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	libs := []string{path.Join(dir, "a.so"), path.Join(dir, "b.so")}
+	for _, lib := range libs {
+		if err := ioutil.WriteFile(lib, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env, err := preloadEnv(libs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "LD_PRELOAD=" + libs[0] + ":" + libs[1]; env != expected {
+		t.Errorf("expecting %s, got %s", expected, env)
+	}
+	if _, err := preloadEnv([]string{path.Join(dir, "missing.so")}); err == nil {
+		t.Error("expecting a missing library to be rejected")
+	}
+	if _, err := preloadEnv([]string{dir}); err == nil {
+		t.Error("expecting a directory to be rejected")
+	}
+}
@@ -0,0 +1,158 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Used when ready_probe_timeout is not set in the profile
+	defaultReadyProbeTimeout = 30
+	readyProbeInterval       = time.Second
+	// Bytes of probe output reported when it fails
+	readyProbeOutputMax = 512
+)
+
+// exitWaiters hands the exit status of a child to the goroutine waiting for
+// it. Every child is collected by the reaper, so exec.Cmd.Wait cannot be used.
+type exitWaiters struct {
+	lock    sync.Mutex
+	waiters map[int]chan syscall.WaitStatus
+}
+
+// start starts cmd and registers its waiter, the lock is held so the reaper
+// cannot collect the child before it is registered.
+func (ew *exitWaiters) start(cmd *exec.Cmd) (<-chan syscall.WaitStatus, error) {
+	return ew.startWith(cmd, (*exec.Cmd).Start)
+}
+
+// startWith is start with cmd started by the given function
+func (ew *exitWaiters) startWith(cmd *exec.Cmd, start func(*exec.Cmd) error) (<-chan syscall.WaitStatus, error) {
+	ew.lock.Lock()
+	defer ew.lock.Unlock()
+	if err := start(cmd); err != nil {
+		return nil, err
+	}
+	if ew.waiters == nil {
+		ew.waiters = make(map[int]chan syscall.WaitStatus)
+	}
+	ch := make(chan syscall.WaitStatus, 1)
+	ew.waiters[cmd.Process.Pid] = ch
+	return ch, nil
+}
+
+// notify returns whether a waiter was registered for pid
+func (ew *exitWaiters) notify(pid int, wstatus syscall.WaitStatus) bool {
+	ew.lock.Lock()
+	defer ew.lock.Unlock()
+	ch, ok := ew.waiters[pid]
+	if ok {
+		ch <- wstatus
+		delete(ew.waiters, pid)
+	}
+	return ok
+}
+
+// exitCode returns the exit status of a process, or the negated number of the
+// signal which killed it
+func exitCode(wstatus syscall.WaitStatus) int {
+	if wstatus.Signaled() {
+		return -int(wstatus.Signal())
+	}
+	return wstatus.ExitStatus()
+}
+
+// runReadyProbe runs the ready probe of the profile once as the sandbox user,
+// it is killed if still running at the deadline.
+func (st *initState) runReadyProbe(deadline time.Time) error {
+	// The output goes to a file rather than a pipe, a probe leaving a
+	// background process behind would otherwise keep the pipe open
+	out, err := ioutil.TempFile("", "oz-ready-probe")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	probe := st.profile.ReadyProbe
+	cmd := exec.Command(probe[0], probe[1:]...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append([]string{}, st.launchEnv...)
+	if st.user != nil {
+		cmd.Dir = st.user.HomeDir
+	}
+	groups := st.userGroups()
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    st.uid,
+		Gid:    st.gid,
+		Groups: groups,
+	}
+	exited, err := st.exits.start(cmd)
+	if err != nil {
+		return err
+	}
+
+	var wstatus syscall.WaitStatus
+	select {
+	case wstatus = <-exited:
+	case <-time.After(deadline.Sub(time.Now())):
+		cmd.Process.Kill()
+		<-exited
+		return fmt.Errorf("still running at the deadline")
+	}
+	if wstatus.Exited() && wstatus.ExitStatus() == 0 {
+		return nil
+	}
+	result := describeWaitStatus(wstatus)
+	if output := probeOutput(out); output != "" {
+		return fmt.Errorf("%s: %s", result, output)
+	}
+	return fmt.Errorf("%s", result)
+}
+
+// probeOutput returns the end of the output written by a probe on a single line
+func probeOutput(f *os.File) string {
+	fi, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	offset := fi.Size() - readyProbeOutputMax
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, fi.Size()-offset)
+	n, _ := f.ReadAt(buf, offset)
+	return strings.Join(strings.Fields(string(buf[:n])), " ")
+}
+
+// waitReadyProbe polls the ready probe of the profile until it passes or its
+// timeout elapses, the sandbox is signalled ready in both cases.
+func (st *initState) waitReadyProbe() {
+	timeout := st.profile.ReadyProbeTimeout
+	if timeout <= 0 {
+		timeout = defaultReadyProbeTimeout
+	}
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	probe := strings.Join(st.profile.ReadyProbe, " ")
+	for attempt := 1; ; attempt++ {
+		err := st.runReadyProbe(deadline)
+		if err == nil {
+			st.log.Info("Ready probe (%s) passed after %d attempts", probe, attempt)
+			return
+		}
+		if time.Now().Add(readyProbeInterval).After(deadline) {
+			st.log.Error("Ready probe (%s) did not pass within %d seconds, last attempt %v", probe, timeout, err)
+			return
+		}
+		st.log.Debug("Ready probe (%s) failed: %v", probe, err)
+		time.Sleep(readyProbeInterval)
+	}
+}
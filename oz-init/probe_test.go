@@ -0,0 +1,72 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitWaiters(t *testing.T) {
+	ew := &exitWaiters{}
+	cmd := exec.Command("/bin/sh", "-c", "exit 3")
+	exited, err := ew.start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stands in for the reaper of oz-init
+	var wstatus syscall.WaitStatus
+	if _, err := syscall.Wait4(cmd.Process.Pid, &wstatus, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !ew.notify(cmd.Process.Pid, wstatus) {
+		t.Error("expecting a waiter to be registered for the started process")
+	}
+	select {
+	case ws := <-exited:
+		if ws.ExitStatus() != 3 {
+			t.Errorf("expecting exit status 3, got %d", ws.ExitStatus())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not notified")
+	}
+	if ew.notify(cmd.Process.Pid, wstatus) {
+		t.Error("expecting the waiter to be removed once notified")
+	}
+}
+
+func TestProbeOutput(t *testing.T) {
+	f, err := ioutil.TempFile("", "oz-probe-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	f.WriteString(strings.Repeat("x", 1000) + "\nconnection\trefused \n")
+	out := probeOutput(f)
+	if !strings.HasSuffix(out, "x connection refused") {
+		t.Errorf("expecting the end of the output on a single line, got %q", out)
+	}
+	if len(out) > readyProbeOutputMax {
+		t.Errorf("expecting at most %d bytes of output, got %d", readyProbeOutputMax, len(out))
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for script, expected := range map[string]int{
+		"exit 0":      0,
+		"exit 7":      7,
+		"kill -9 $$":  -int(syscall.SIGKILL),
+		"kill -15 $$": -int(syscall.SIGTERM),
+	} {
+		cmd := exec.Command("/bin/sh", "-c", script)
+		cmd.Run()
+		ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
+		if code := exitCode(ws); code != expected {
+			t.Errorf("expecting exit code %d for %q, got %d", expected, script, code)
+		}
+	}
+}
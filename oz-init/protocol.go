@@ -1,6 +1,11 @@
 package ozinit
 
-import "github.com/subgraph/oz/ipc"
+import (
+	"time"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
 
 type OkMsg struct {
 	_ string "Ok"
@@ -16,12 +21,230 @@ type PingMsg struct {
 
 type RunShellMsg struct {
 	Term string "RunShell"
+	// Working directory of the shell, the home directory if empty or missing
+	Pwd string
 }
 
 type RunProgramMsg struct {
 	Args []string "RunProgram"
 	Pwd  string
 	Path string
+	Term string
+	// Run the program in a new pty returned with the Ok response
+	Pty bool
+	// Run the program in its own network namespace with only loopback
+	IsolateNet bool
+	// argv[0] given to the program instead of its path, the profile argv0 if empty
+	Argv0 string
+	// Respond with an Exit message once the program exits rather than Ok
+	Wait bool
+	// Display the program is shown on, the main display of the sandbox if 0
+	Display int
+}
+
+// ExitMsg reports the exit status of a program run with Wait, a program
+// killed by a signal has the negated signal number as its code
+type ExitMsg struct {
+	Code int "Exit"
+}
+
+// ExecMsg runs a program without a pty, its combined output is sent back in
+// ExecOutput responses followed by an Exit response once it exits
+type ExecMsg struct {
+	Path string "Exec"
+	Args []string
+	Pwd  string
+}
+
+type ExecOutputMsg struct {
+	Data []byte "ExecOutput"
+}
+
+type ListProcessesMsg struct {
+	_ string "ListProcesses"
+}
+
+type ProcessInfo struct {
+	Pid  int
+	Path string
+	Args []string
+}
+
+type ListProcessesResp struct {
+	Processes []ProcessInfo "ListProcessesResp"
+}
+
+type ListMountsMsg struct {
+	_ string "ListMounts"
+}
+
+type MountEntry struct {
+	Source  string
+	Target  string
+	FSType  string
+	Options string
+}
+
+type ListMountsResp struct {
+	Mounts []MountEntry "ListMountsResp"
+}
+
+// Signal defaults to SIGTERM when zero
+type KillProcessMsg struct {
+	Pid    int "KillProcess"
+	Signal int
+}
+
+type RunDebuggerMsg struct {
+	Pid  int "RunDebugger"
+	Term string
+}
+
+type GetInfoMsg struct {
+	_ string "GetInfo"
+}
+
+type InfoMsg struct {
+	Display       int "Info"
+	ReapedTotal   uint64
+	ReapedOrphans uint64
+	RecentReaped  []ReapedProcess
+	PreWarmed     bool
+	// Displays of the extra xpra servers of the profile
+	ExtraDisplays []int
+	// Incremented each time the seccomp policy is reloaded
+	SeccompVersion    int
+	SeccompPolicyHash string
+	// Memory of oz-init itself, the limit is 0 when none is configured
+	InitMemoryLimit int64
+	InitMemorySys   uint64
+	InitHeapInuse   uint64
+	// Cpus the sandbox may run on
+	Cpus string
+}
+
+type GetStatusMsg struct {
+	_ string "GetStatus"
+}
+
+type StatusMsg struct {
+	Profile string "Status"
+	// Processes started by oz-init which are still running
+	Children  int
+	XpraReady bool
+	// Time elapsed since oz-init started
+	Uptime time.Duration
+	// Whether the network of the sandbox was set up
+	Networking bool
+}
+
+type DiskUsageMsg struct {
+	_ string "DiskUsage"
+}
+
+type MountUsage struct {
+	Path  string
+	Size  uint64
+	Used  uint64
+	Avail uint64
+}
+
+type DiskUsageResp struct {
+	Mounts []MountUsage "DiskUsageResp"
+}
+
+type TrimMemoryMsg struct {
+	_ string "TrimMemory"
+}
+
+type TrimMemoryResp struct {
+	RssBefore uint64 "TrimMemoryResp"
+	RssAfter  uint64
+	// Either cgroup or madvise
+	Method string
+}
+
+type DebugDumpMsg struct {
+	_ string "DebugDump"
+}
+
+type DebugProcess struct {
+	Pid     int
+	Path    string
+	Args    []string
+	Tracked bool
+	Shell   bool
+	// Launch order of the process
+	Seq uint64
+}
+
+type DebugDumpResp struct {
+	Goroutines string "DebugDumpResp"
+	Processes  []DebugProcess
+	// Most recent log lines of oz-init
+	Log []string
+}
+
+// Limits of a resource, RLIM_INFINITY when unlimited
+type Rlimit struct {
+	Resource string
+	Soft     uint64
+	Hard     uint64
+}
+
+type ProcessRlimits struct {
+	Pid    int
+	Limits []Rlimit
+}
+
+// Pid 0 selects every process started by oz-init
+type GetRlimitsMsg struct {
+	Pid int "GetRlimits"
+}
+
+type RlimitsResp struct {
+	Processes []ProcessRlimits "RlimitsResp"
+}
+
+type SetRlimitsMsg struct {
+	Pid    int "SetRlimits"
+	Limits []Rlimit
+}
+
+// Error is empty when the limit was changed
+type RlimitResult struct {
+	Pid      int
+	Resource string
+	Error    string
+}
+
+type SetRlimitsResp struct {
+	Results []RlimitResult "SetRlimitsResp"
+}
+
+// Groups maps the names of the supplementary groups to their gid
+type SetGroupsMsg struct {
+	Groups map[string]uint32 "SetGroups"
+}
+
+// AddWhitelistMsg binds a host path at the same path inside the running sandbox
+type AddWhitelistMsg struct {
+	Path     string "AddWhitelist"
+	ReadOnly bool
+}
+
+// AddWhitelistItemsMsg binds the whitelist items added to the profile of the
+// running sandbox
+type AddWhitelistItemsMsg struct {
+	Items []oz.WhitelistItem "AddWhitelistItems"
+}
+
+type SubscribeOutputMsg struct {
+	_ string "SubscribeOutput"
+}
+
+type ReloadSeccompMsg struct {
+	Seccomp oz.SeccompConf "ReloadSeccomp"
 }
 
 type ForwarderSuccessMsg struct {
@@ -36,5 +259,33 @@ var messageFactory = ipc.NewMsgFactory(
 	new(PingMsg),
 	new(RunShellMsg),
 	new(RunProgramMsg),
+	new(ExitMsg),
+	new(ExecMsg),
+	new(ExecOutputMsg),
+	new(RunDebuggerMsg),
+	new(GetInfoMsg),
+	new(InfoMsg),
+	new(GetStatusMsg),
+	new(StatusMsg),
+	new(DiskUsageMsg),
+	new(DiskUsageResp),
+	new(TrimMemoryMsg),
+	new(TrimMemoryResp),
+	new(DebugDumpMsg),
+	new(DebugDumpResp),
+	new(ListProcessesMsg),
+	new(ListProcessesResp),
+	new(ListMountsMsg),
+	new(ListMountsResp),
+	new(KillProcessMsg),
+	new(GetRlimitsMsg),
+	new(RlimitsResp),
+	new(SetRlimitsMsg),
+	new(SetRlimitsResp),
+	new(SetGroupsMsg),
+	new(AddWhitelistMsg),
+	new(AddWhitelistItemsMsg),
+	new(SubscribeOutputMsg),
+	new(ReloadSeccompMsg),
 	new(ForwarderSuccessMsg),
 )
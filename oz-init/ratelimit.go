@@ -0,0 +1,47 @@
+package ozinit
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket allows rate requests per second with bursts of up to burst
+// requests. A zero rate disables the limit.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+func (tb *tokenBucket) allow(now time.Time) bool {
+	if tb.rate <= 0 {
+		return true
+	}
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+	if !tb.last.IsZero() {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
@@ -0,0 +1,45 @@
+package ozinit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	tb := newTokenBucket(0, 0)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if !tb.allow(now) {
+			t.Fatalf("expecting unlimited bucket to allow request %d", i)
+		}
+	}
+}
+
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	tb := newTokenBucket(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !tb.allow(now) {
+			t.Errorf("expecting burst request %d to be allowed", i)
+		}
+	}
+	if tb.allow(now) {
+		t.Error("expecting request over burst to be rejected")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !tb.allow(now) {
+		t.Error("expecting a request to be allowed after refill")
+	}
+	if tb.allow(now) {
+		t.Error("expecting refill to add a single token after 500ms at 2/s")
+	}
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if !tb.allow(now) {
+			t.Errorf("expecting refilled burst request %d to be allowed", i)
+		}
+	}
+	if tb.allow(now) {
+		t.Error("expecting refill to be capped at burst")
+	}
+}
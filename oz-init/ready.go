@@ -0,0 +1,60 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Number of seconds to wait for the application to print a line matching
+// the ready_pattern of the profile when ready_timeout is not set
+const defaultReadyTimeout = 30
+
+func (st *initState) setupReadyPattern() error {
+	st.appReady = make(chan struct{})
+	if st.profile.ReadyPattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(st.profile.ReadyPattern)
+	if err != nil {
+		return fmt.Errorf("invalid ready_pattern (%s): %v", st.profile.ReadyPattern, err)
+	}
+	st.readyPattern = re
+	return nil
+}
+
+// The readiness timeout starts with the first application launched in the sandbox.
+func (st *initState) startReadyTimeout() {
+	if st.readyPattern == nil {
+		return
+	}
+	st.readyTimeout.Do(func() {
+		timeout := st.profile.ReadyTimeout
+		if timeout <= 0 {
+			timeout = defaultReadyTimeout
+		}
+		st.workers.Go(func(done <-chan struct{}) {
+			select {
+			case <-st.appReady:
+			case <-done:
+			case <-time.After(time.Duration(timeout) * time.Second):
+				st.log.Error("Application did not match ready pattern (%s) within %d seconds", st.profile.ReadyPattern, timeout)
+				// Signal the daemon the application is not ready
+				os.Stderr.WriteString("NOTREADY\n")
+			}
+		})
+	})
+}
+
+func (st *initState) checkReadyLine(line string) {
+	if st.readyPattern == nil || !st.readyPattern.MatchString(line) {
+		return
+	}
+	st.readySignal.Do(func() {
+		close(st.appReady)
+		st.log.Info("Application output matched ready pattern (%s)", st.profile.ReadyPattern)
+		// Signal the daemon the application is ready
+		os.Stderr.WriteString("READY\n")
+	})
+}
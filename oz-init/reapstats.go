@@ -0,0 +1,46 @@
+package ozinit
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Number of reaped processes remembered for GetInfo
+const recentReapedCount = 8
+
+type ReapedProcess struct {
+	Pid    int
+	Status int
+	Orphan bool
+}
+
+// reapStats keeps track of the processes reaped by oz-init. Counters are
+// updated atomically so only the short list of recent pids takes a lock.
+type reapStats struct {
+	total   uint64
+	orphans uint64
+	lock    sync.Mutex
+	recent  []ReapedProcess
+}
+
+func (rs *reapStats) add(pid int, wstatus syscall.WaitStatus, orphan bool) {
+	atomic.AddUint64(&rs.total, 1)
+	if orphan {
+		atomic.AddUint64(&rs.orphans, 1)
+	}
+	rs.lock.Lock()
+	if len(rs.recent) == recentReapedCount {
+		rs.recent = rs.recent[1:]
+	}
+	rs.recent = append(rs.recent, ReapedProcess{Pid: pid, Status: wstatus.ExitStatus(), Orphan: orphan})
+	rs.lock.Unlock()
+}
+
+func (rs *reapStats) fill(info *InfoMsg) {
+	info.ReapedTotal = atomic.LoadUint64(&rs.total)
+	info.ReapedOrphans = atomic.LoadUint64(&rs.orphans)
+	rs.lock.Lock()
+	info.RecentReaped = append([]ReapedProcess{}, rs.recent...)
+	rs.lock.Unlock()
+}
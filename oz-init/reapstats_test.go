@@ -0,0 +1,53 @@
+package ozinit
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestReapStats(t *testing.T) {
+	var rs reapStats
+	for pid := 1; pid <= recentReapedCount+2; pid++ {
+		rs.add(pid, syscall.WaitStatus(pid<<8), pid%2 == 0)
+	}
+	info := &InfoMsg{}
+	rs.fill(info)
+	if info.ReapedTotal != recentReapedCount+2 || info.ReapedOrphans != (recentReapedCount+2)/2 {
+		t.Errorf("expecting %d reaped and %d orphans, got %d and %d", recentReapedCount+2, (recentReapedCount+2)/2, info.ReapedTotal, info.ReapedOrphans)
+	}
+	if len(info.RecentReaped) != recentReapedCount {
+		t.Fatalf("expecting the last %d reaped processes, got %v", recentReapedCount, info.RecentReaped)
+	}
+	for i, rp := range info.RecentReaped {
+		pid := i + 3
+		if rp.Pid != pid || rp.Status != pid || rp.Orphan != (pid%2 == 0) {
+			t.Errorf("unexpected reaped process %+v, expecting pid and status %d", rp, pid)
+		}
+	}
+}
+
+func TestHandleChildExitCountsOrphans(t *testing.T) {
+	st := &initState{
+		log:       logging.MustGetLogger("oz-init-test"),
+		profile:   &oz.Profile{},
+		children:  make(map[int]procState),
+		prewarmed: true,
+	}
+	st.addChildProcess(&exec.Cmd{Process: &os.Process{Pid: 100}}, true)
+	st.handleChildExit(100, syscall.WaitStatus(1<<8))
+	st.handleChildExit(200, 0)
+
+	info := &InfoMsg{}
+	st.reaped.fill(info)
+	if info.ReapedTotal != 2 || info.ReapedOrphans != 1 {
+		t.Errorf("expecting 2 reaped processes with 1 orphan, got %d and %d", info.ReapedTotal, info.ReapedOrphans)
+	}
+	if len(info.RecentReaped) != 2 || info.RecentReaped[0].Orphan || !info.RecentReaped[1].Orphan || info.RecentReaped[0].Status != 1 {
+		t.Errorf("unexpected recent reaped processes: %+v", info.RecentReaped)
+	}
+}
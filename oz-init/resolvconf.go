@@ -0,0 +1,89 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/subgraph/oz/network"
+)
+
+const resolvConfPath = "/etc/resolv.conf"
+
+// resolvConfWithSearch replaces the search and domain lines of the host
+// resolv.conf with the search domains of the profile.
+func resolvConfWithSearch(host string, domains []string) string {
+	lines := []string{}
+	for _, line := range strings.Split(host, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	lines = append(lines, "search "+strings.Join(domains, " "))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// resolvConfWithServers replaces the nameserver lines of the host resolv.conf
+// with the name servers of the profile.
+func resolvConfWithServers(host string, servers []string) string {
+	lines := []string{}
+	for _, line := range strings.Split(host, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "nameserver" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, ns := range servers {
+		lines = append(lines, "nameserver "+ns)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// setupResolvConf writes the resolv.conf of the sandbox when the profile sets
+// search domains or name servers, otherwise the resolv.conf of the host is
+// used unchanged. The symlink to the host file is replaced so that file is
+// never modified.
+func (st *initState) setupResolvConf() {
+	domains := st.profile.Networking.SearchDomains
+	servers := st.profile.Networking.DNS
+	if st.profile.Networking.Nettype == network.TYPE_HOST {
+		servers = nil
+	}
+	if len(domains) == 0 && len(servers) == 0 {
+		return
+	}
+	host, err := ioutil.ReadFile(resolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		st.log.Warning("Unable to read host resolv.conf: %v", err)
+	}
+	if err := os.Remove(resolvConfPath); err != nil && !os.IsNotExist(err) {
+		st.log.Warning("Unable to setup resolv.conf: %v", err)
+		return
+	}
+	conf := string(host)
+	if len(servers) > 0 {
+		conf = resolvConfWithServers(conf, servers)
+	}
+	if len(domains) > 0 {
+		conf = resolvConfWithSearch(conf, domains)
+	}
+	if err := ioutil.WriteFile(resolvConfPath, []byte(conf), 0644); err != nil {
+		st.log.Warning("Unable to setup resolv.conf: %v", err)
+		return
+	}
+	if len(servers) > 0 {
+		st.log.Info("Name servers of the sandbox: %s", strings.Join(servers, " "))
+	}
+	if len(domains) > 0 {
+		st.log.Info("Search domains of the sandbox: %s", strings.Join(domains, " "))
+	}
+}
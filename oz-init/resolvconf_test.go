@@ -0,0 +1,26 @@
+package ozinit
+
+import "testing"
+
+func TestResolvConfWithSearch(t *testing.T) {
+	host := "# generated\nnameserver 10.0.0.1\nsearch host.example\ndomain example\noptions ndots:2\n\n"
+	expected := "# generated\nnameserver 10.0.0.1\noptions ndots:2\nsearch corp.example lab.example\n"
+	if out := resolvConfWithSearch(host, []string{"corp.example", "lab.example"}); out != expected {
+		t.Errorf("unexpected resolv.conf:\n%s", out)
+	}
+	if out := resolvConfWithSearch("", []string{"corp.example"}); out != "search corp.example\n" {
+		t.Errorf("unexpected resolv.conf without host file:\n%s", out)
+	}
+}
+
+func TestResolvConfWithServers(t *testing.T) {
+	host := "# generated\nnameserver 10.0.0.1\nnameserver 10.0.0.2\nsearch host.example\n\n"
+	expected := "# generated\nsearch host.example\nnameserver 9.9.9.9\nnameserver 2620:fe::fe\n"
+	if out := resolvConfWithServers(host, []string{"9.9.9.9", "2620:fe::fe"}); out != expected {
+		t.Errorf("unexpected resolv.conf:\n%s", out)
+	}
+	expected = "nameserver 9.9.9.9\nsearch corp.example\n"
+	if out := resolvConfWithSearch(resolvConfWithServers("", []string{"9.9.9.9"}), []string{"corp.example"}); out != expected {
+		t.Errorf("unexpected resolv.conf without host file:\n%s", out)
+	}
+}
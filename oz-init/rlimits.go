@@ -0,0 +1,204 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
+
+// Value of an unlimited resource
+const RLIM_INFINITY = ^uint64(0)
+
+// Used when max_open_files is not set in a profile with an X server
+const defaultGuiMaxOpenFiles = 65536
+
+// prlimit reads the limit of resource for pid and replaces it with new when
+// it is not nil.
+func prlimit(pid, resource int, new *syscall.Rlimit) (syscall.Rlimit, error) {
+	var old syscall.Rlimit
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(new)), uintptr(unsafe.Pointer(&old)), 0, 0)
+	if errno != 0 {
+		return old, errno
+	}
+	return old, nil
+}
+
+// maxOpenFiles returns the soft limit of open files of the applications, 0
+// leaves the inherited limit. Applications like browsers exceed the usual
+// default of 1024 files.
+func maxOpenFiles(p *oz.Profile) uint64 {
+	// An explicit nofile limit of the profile rlimits wins
+	if _, ok := p.Rlimits["nofile"]; ok {
+		return 0
+	}
+	if p.MaxOpenFiles == 0 && p.XServer.Enabled {
+		return defaultGuiMaxOpenFiles
+	}
+	return p.MaxOpenFiles
+}
+
+// nrOpen returns the highest limit of open files the kernel accepts
+func nrOpen() (uint64, error) {
+	data, err := ioutil.ReadFile("/proc/sys/fs/nr_open")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// applicationRlimits returns the limits set on the applications before they
+// execute, the soft limit of open files raising its hard limit when needed and
+// the limits of the profile.
+func (st *initState) applicationRlimits() map[string]syscall.Rlimit {
+	limits := map[string]syscall.Rlimit{}
+	if v := maxOpenFiles(st.profile); v != 0 {
+		if max, err := nrOpen(); err == nil && v > max {
+			st.log.Warning("max_open_files (%d) is above the limit of the kernel, using %d", v, max)
+			v = max
+		}
+		var cur syscall.Rlimit
+		if err := syscall.Getrlimit(oz.RlimitResources["nofile"], &cur); err != nil {
+			st.log.Warning("Failed to read nofile limit: %v", err)
+		} else {
+			l := syscall.Rlimit{Cur: v, Max: cur.Max}
+			if v > l.Max {
+				l.Max = v
+			}
+			limits["nofile"] = l
+		}
+	}
+	for name, v := range st.profile.Rlimits {
+		if _, ok := oz.RlimitResources[name]; ok {
+			limits[name] = syscall.Rlimit{Cur: v, Max: v}
+		}
+	}
+	return limits
+}
+
+// rlimitCommand wraps the command of an application in oz-supervise, which
+// sets the limits and executes it in place. Limits are shared by all the
+// threads of a process, so they cannot be set on oz-init around the fork of
+// the application as is done with the scheduling policy, and setting them
+// with prlimit once it started would let it run a moment without them. The
+// trampoline runs as the sandbox user, so oz-init first raises its own hard
+// limits for them to be inherited.
+func (st *initState) rlimitCommand(cpath, argv0 string, cmdArgs []string, limits map[string]syscall.Rlimit) (string, []string) {
+	names := make([]string, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{"-exec"}
+	for _, name := range names {
+		l := limits[name]
+		st.raiseHardLimit(name, l.Max)
+		args = append(args, "-rlimit", oz.FormatRlimit(name, l))
+		st.log.Info("Setting %s limit of %s to %d/%d", name, cpath, l.Cur, l.Max)
+	}
+	if argv0 != "" {
+		args = append(args, "-argv0", argv0)
+	}
+	args = append(args, "--", cpath)
+	return path.Join(st.config.PrefixPath, "bin", "oz-supervise"), append(args, cmdArgs...)
+}
+
+// raiseHardLimit raises the hard limit of oz-init to max, its soft limit is
+// left as is.
+func (st *initState) raiseHardLimit(name string, max uint64) {
+	resource := oz.RlimitResources[name]
+	var cur syscall.Rlimit
+	if err := syscall.Getrlimit(resource, &cur); err != nil || cur.Max >= max {
+		return
+	}
+	if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: cur.Cur, Max: max}); err != nil {
+		st.log.Warning("Failed to raise the %s hard limit of oz-init to %d: %v", name, max, err)
+	}
+}
+
+// rlimitTargets returns the tracked children matching pid, all of them when
+// pid is 0.
+func (st *initState) rlimitTargets(pid int) ([]int, error) {
+	pids := []int{}
+	for _, c := range st.childrenVector() {
+		if pid == 0 || c.cmd.Process.Pid == pid {
+			pids = append(pids, c.cmd.Process.Pid)
+		}
+	}
+	if pid != 0 && len(pids) == 0 {
+		return nil, fmt.Errorf("No process with pid %d was started by oz-init", pid)
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+func (st *initState) handleGetRlimits(gr *GetRlimitsMsg, msg *ipc.Message) error {
+	pids, err := st.rlimitTargets(gr.Pid)
+	if err != nil {
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	procs := []ProcessRlimits{}
+	for _, pid := range pids {
+		p := ProcessRlimits{Pid: pid}
+		for _, name := range oz.RlimitResourceNames() {
+			l, err := prlimit(pid, oz.RlimitResources[name], nil)
+			if err != nil {
+				continue
+			}
+			p.Limits = append(p.Limits, Rlimit{Resource: name, Soft: l.Cur, Hard: l.Max})
+		}
+		procs = append(procs, p)
+	}
+	return msg.Respond(&RlimitsResp{Processes: procs})
+}
+
+// handleSetRlimits applies the limits to the tracked children with prlimit.
+// oz-init may raise any limit, so only root is allowed to raise a hard limit.
+func (st *initState) handleSetRlimits(sr *SetRlimitsMsg, msg *ipc.Message) error {
+	if len(sr.Limits) == 0 {
+		return msg.Respond(&ErrorMsg{"No resource limits given"})
+	}
+	for _, l := range sr.Limits {
+		if _, ok := oz.RlimitResources[l.Resource]; !ok {
+			return msg.Respond(&ErrorMsg{fmt.Sprintf("Unknown resource (%s)", l.Resource)})
+		}
+		if l.Soft > l.Hard {
+			return msg.Respond(&ErrorMsg{fmt.Sprintf("Soft limit of %s is above its hard limit", l.Resource)})
+		}
+	}
+	pids, err := st.rlimitTargets(sr.Pid)
+	if err != nil {
+		return msg.Respond(&ErrorMsg{err.Error()})
+	}
+	privileged := msg.Ucred != nil && msg.Ucred.Uid == 0
+
+	results := []RlimitResult{}
+	for _, pid := range pids {
+		for _, l := range sr.Limits {
+			res := RlimitResult{Pid: pid, Resource: l.Resource}
+			resource := oz.RlimitResources[l.Resource]
+			if cur, err := prlimit(pid, resource, nil); err != nil {
+				res.Error = err.Error()
+			} else if l.Hard > cur.Max && !privileged {
+				res.Error = "raising the hard limit requires root"
+			} else if _, err := prlimit(pid, resource, &syscall.Rlimit{Cur: l.Soft, Max: l.Hard}); err != nil {
+				res.Error = err.Error()
+			}
+			if res.Error == "" {
+				st.log.Info("Set %s limit of pid=%d to %d/%d", l.Resource, pid, l.Soft, l.Hard)
+			} else {
+				st.log.Warning("Failed to set %s limit of pid=%d: %s", l.Resource, pid, res.Error)
+			}
+			results = append(results, res)
+		}
+	}
+	return msg.Respond(&SetRlimitsResp{Results: results})
+}
@@ -0,0 +1,80 @@
+package ozinit
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestPrlimit(t *testing.T) {
+	var expected syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &expected); err != nil {
+		t.Fatal(err)
+	}
+	l, err := prlimit(os.Getpid(), oz.RlimitResources["nofile"], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != expected {
+		t.Errorf("expecting nofile limit %+v, got %+v", expected, l)
+	}
+	if _, err := prlimit(os.Getpid(), oz.RlimitResources["nofile"], &syscall.Rlimit{Cur: l.Max + 1, Max: l.Max}); err == nil {
+		t.Error("expecting a soft limit above the hard limit to be rejected")
+	}
+}
+
+func TestMaxOpenFiles(t *testing.T) {
+	gui := &oz.Profile{XServer: oz.XServerConf{Enabled: true}}
+	if v := maxOpenFiles(gui); v != defaultGuiMaxOpenFiles {
+		t.Errorf("expecting the default of %d files with an X server, got %d", defaultGuiMaxOpenFiles, v)
+	}
+	if v := maxOpenFiles(&oz.Profile{}); v != 0 {
+		t.Errorf("expecting the inherited limit without an X server, got %d", v)
+	}
+	gui.MaxOpenFiles = 4096
+	if v := maxOpenFiles(gui); v != 4096 {
+		t.Errorf("expecting max_open_files to override the default, got %d", v)
+	}
+	gui.Rlimits = map[string]uint64{"nofile": 1024}
+	if v := maxOpenFiles(gui); v != 0 {
+		t.Errorf("expecting an explicit nofile rlimit to win over max_open_files, got %d", v)
+	}
+}
+
+func TestApplicationRlimits(t *testing.T) {
+	var nofile syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &nofile); err != nil {
+		t.Fatal(err)
+	}
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		config:  &oz.Config{PrefixPath: "/usr/lib/oz"},
+		profile: &oz.Profile{Rlimits: map[string]uint64{"core": 0, "nproc": 64}},
+	}
+	expected := map[string]syscall.Rlimit{"core": {Cur: 0, Max: 0}, "nproc": {Cur: 64, Max: 64}}
+	limits := st.applicationRlimits()
+	if !reflect.DeepEqual(limits, expected) {
+		t.Errorf("expecting the limits of the profile %v, got %v", expected, limits)
+	}
+	cpath, args := st.rlimitCommand("/usr/bin/app", "app", []string{"-v"}, limits)
+	if cpath != "/usr/lib/oz/bin/oz-supervise" {
+		t.Errorf("unexpected trampoline %s", cpath)
+	}
+	expectedArgs := []string{"-exec", "-rlimit", "core=0:0", "-rlimit", "nproc=64:64", "-argv0", "app", "--", "/usr/bin/app", "-v"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("expecting trampoline arguments %v, got %v", expectedArgs, args)
+	}
+
+	// The soft limit of open files keeps the hard limit unless above it
+	st.profile = &oz.Profile{MaxOpenFiles: 64}
+	if l := st.applicationRlimits()["nofile"]; l.Cur != 64 || l.Max != nofile.Max {
+		t.Errorf("expecting nofile limit 64/%d, got %d/%d", nofile.Max, l.Cur, l.Max)
+	}
+	if limits := (&initState{profile: &oz.Profile{}}).applicationRlimits(); len(limits) != 0 {
+		t.Errorf("expecting no limits without limits in the profile, got %v", limits)
+	}
+}
@@ -47,6 +47,8 @@ var deviceSymlinks = [][2]string{
 	{"/dev/pts/ptmx", "/dev/ptmx"},
 }
 
+const fusermountBlacklist = "${PATH}/fusermount"
+
 var basicBlacklist = []string{
 	"/usr/lib/gvfs",
 
@@ -58,7 +60,7 @@ var basicBlacklist = []string{
 	"${PATH}/sudo", "${PATH}/su",
 	"${PATH}/xinput", "${PATH}/strace",
 	"${PATH}/mount", "${PATH}/umount",
-	"${PATH}/fusermount", "${PATH}/fuser",
+	fusermountBlacklist, "${PATH}/fuser",
 	"${PATH}/kmod", "${PATH}/mknod", "${PATH}/udevadm",
 	"${PATH}/systemd", "${PATH}/systemd-*",
 }
@@ -101,7 +103,7 @@ func _makedev(x, y int) int {
 	return (((x) << 8) | (y))
 }
 
-func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display int, useFullDev bool, log *logging.Logger, etcIncludes []string) error {
+func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display, tmpfsSizeMB int, useFullDev, allowFuse bool, log *logging.Logger, etcIncludes []string) error {
 	if err := os.MkdirAll(fsys.Root(), 0755); err != nil {
 		return fmt.Errorf("could not create rootfs path '%s': %v", fsys.Root(), err)
 	}
@@ -150,15 +152,14 @@ func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display
 		}
 	}
 
-	basicEmptyUserDirs = append(basicEmptyUserDirs, user.HomeDir)
+	if hasHomeDir(user) {
+		basicEmptyUserDirs = append(basicEmptyUserDirs, user.HomeDir)
+	}
 	for _, p := range basicEmptyUserDirs {
 		//log.Debug("Creating empty user dir: %s", p)
-		if err := fsys.CreateEmptyDir(p); err != nil {
+		if err := fsys.CreateOwnedDir(p, int(uid), int(gid)); err != nil {
 			return fmt.Errorf("failed to create empty user directory '%s': %v", p, err)
 		}
-		if err := os.Chown(path.Join(fsys.Root(), p), int(uid), int(gid)); err != nil {
-			return fmt.Errorf("failed to chown user dir: %v", err)
-		}
 	}
 
 	rup := path.Join(fsys.Root(), "/run/user", strconv.FormatUint(uint64(uid), 10))
@@ -175,7 +176,11 @@ func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display
 	}
 
 	if !useFullDev {
-		for _, d := range basicDevices {
+		devices := basicDevices
+		if allowFuse {
+			devices = append(devices, fuseDevice)
+		}
+		for _, d := range devices {
 			if err := fsys.CreateDevice(d.path, d.dev, d.mode, d.gid); err != nil {
 				return err
 			}
@@ -186,14 +191,14 @@ func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display
 		if err := os.MkdirAll(smp, 0755); err != nil {
 			return err
 		}
-		if err := syscall.Mount("", smp, "tmpfs", smflags, "mode=1777"); err != nil {
+		if err := syscall.Mount("", smp, "tmpfs", smflags, fs.TmpfsOptions("1777", tmpfsSizeMB)); err != nil {
 			return err
 		}
 	}
 
 	tp := path.Join(fsys.Root(), "/tmp")
 	tflags := uintptr(syscall.MS_NODEV | syscall.MS_NOSUID | syscall.MS_NOEXEC | syscall.MS_REC)
-	if err := syscall.Mount("", tp, "tmpfs", tflags, "mode=777"); err != nil {
+	if err := syscall.Mount("", tp, "tmpfs", tflags, fs.TmpfsOptions("777", tmpfsSizeMB)); err != nil {
 		return err
 	}
 
@@ -208,6 +213,9 @@ func setupRootfs(fsys *fs.Filesystem, user *user.User, uid, gid uint32, display
 	}
 
 	for _, bl := range basicBlacklist {
+		if allowFuse && bl == fusermountBlacklist {
+			continue
+		}
 		if err := fsys.BlacklistPath(bl, display); err != nil {
 			log.Warning("Unable to blacklist %s: %v", bl, err)
 		}
@@ -0,0 +1,76 @@
+package ozinit
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var schedPolicies = map[string]int{
+	"normal": 0, // SCHED_OTHER
+	"batch":  3, // SCHED_BATCH
+	"idle":   5, // SCHED_IDLE
+}
+
+type schedParam struct {
+	priority int32
+}
+
+func getScheduler() (int, error) {
+	r, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
+
+// setScheduler changes the policy of the calling thread only.
+func setScheduler(policy int) error {
+	param := schedParam{}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER, 0, uintptr(policy), uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// startWithSchedPolicy starts cmd with the scheduling policy of the profile.
+// The policy is set on the thread forking the command so the child inherits it
+// before exec, then the thread is restored to its previous policy.
+func (st *initState) startWithSchedPolicy(cmd *exec.Cmd) error {
+	name := st.profile.SchedPolicy
+	if name == "" || name == "normal" {
+		return cmd.Start()
+	}
+	policy, ok := schedPolicies[name]
+	if !ok {
+		st.log.Warning("Unknown scheduling policy (%s), starting application with default policy", name)
+		return cmd.Start()
+	}
+
+	runtime.LockOSThread()
+	prev, err := getScheduler()
+	if err != nil {
+		runtime.UnlockOSThread()
+		st.log.Warning("Unable to read current scheduling policy: %v", err)
+		return cmd.Start()
+	}
+	if err := setScheduler(policy); err != nil {
+		runtime.UnlockOSThread()
+		st.log.Warning("Unable to apply scheduling policy %s: %v", name, err)
+		return cmd.Start()
+	}
+	err = cmd.Start()
+	if rerr := setScheduler(prev); rerr != nil {
+		// Keep the thread locked so it is discarded with this goroutine
+		// instead of running other goroutines with the wrong policy
+		st.log.Warning("Unable to restore scheduling policy: %v", rerr)
+	} else {
+		runtime.UnlockOSThread()
+	}
+	if err == nil {
+		st.log.Info("Application started with scheduling policy: %s", name)
+	}
+	return err
+}
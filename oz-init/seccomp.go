@@ -0,0 +1,75 @@
+package ozinit
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/ipc"
+)
+
+// seccompPolicyPath returns the policy file oz-seccomp will load for conf
+func (st *initState) seccompPolicyPath(conf oz.SeccompConf) string {
+	switch conf.Mode {
+	case oz.PROFILE_SECCOMP_WHITELIST:
+		return conf.Whitelist
+	case oz.PROFILE_SECCOMP_BLACKLIST:
+		if conf.Blacklist == "" {
+			return path.Join(st.config.EtcPrefix, "blacklist-generic.seccomp")
+		}
+		return conf.Blacklist
+	}
+	return ""
+}
+
+func policyHash(p string) (string, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// initSeccompVersion records the policy the sandbox was launched with as
+// version 1
+func (st *initState) initSeccompVersion() {
+	st.seccompVersion = 1
+	if p := st.seccompPolicyPath(st.profile.Seccomp); p != "" {
+		h, err := policyHash(p)
+		if err != nil {
+			st.log.Warning("Unable to read seccomp policy (%s): %v", p, err)
+			return
+		}
+		st.seccompHash = h
+	}
+}
+
+// handleReloadSeccomp replaces the seccomp configuration used by the next
+// launches. A filter cannot be removed or replaced once installed, so the
+// processes already running keep the policy they were started with.
+func (st *initState) handleReloadSeccomp(rs *ReloadSeccompMsg, msg *ipc.Message) error {
+	if msg.Ucred == nil || msg.Ucred.Uid != 0 {
+		return msg.Respond(&ErrorMsg{"Only root may reload the seccomp policy of the sandbox"})
+	}
+	if rs.Seccomp.Mode != st.profile.Seccomp.Mode {
+		return msg.Respond(&ErrorMsg{fmt.Sprintf("Seccomp mode cannot change in a running sandbox (%s)", st.profile.Seccomp.Mode)})
+	}
+	hash := ""
+	if p := st.seccompPolicyPath(rs.Seccomp); p != "" {
+		h, err := policyHash(p)
+		if err != nil {
+			return msg.Respond(&ErrorMsg{fmt.Sprintf("Unable to read seccomp policy: %v", err)})
+		}
+		hash = h
+	}
+	st.lock.Lock()
+	st.profile.Seccomp = rs.Seccomp
+	st.seccompVersion++
+	st.seccompHash = hash
+	version := st.seccompVersion
+	st.lock.Unlock()
+	st.log.Notice("Seccomp policy reloaded (version %d), running processes keep their installed filter", version)
+	return msg.Respond(&OkMsg{})
+}
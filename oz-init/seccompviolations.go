@@ -0,0 +1,27 @@
+package ozinit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Descriptor of the pipe on which oz-seccomp-tracer reports the syscalls hit
+// by the policy, the first of the extra files of the command
+const seccompViolationsFd = 3
+
+// readSeccompViolations logs the syscalls reported by oz-seccomp-tracer, it
+// returns once the tracer exits and closes the pipe.
+func (st *initState) readSeccompViolations(r io.ReadCloser) {
+	defer r.Close()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var pid, num int
+		var name string
+		if n, _ := fmt.Sscanf(sc.Text(), "%d %d %s", &pid, &num, &name); n < 2 {
+			st.log.Debug("Invalid seccomp violation report: %s", sc.Text())
+			continue
+		}
+		st.log.Notice("Seccomp violation by pid %d: syscall %d (%s)", pid, num, name)
+	}
+}
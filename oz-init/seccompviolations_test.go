@@ -0,0 +1,29 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestReadSeccompViolations(t *testing.T) {
+	be := logging.InitForTesting(logging.DEBUG)
+	st := &initState{log: logging.MustGetLogger("oz-init-test")}
+	st.readSeccompViolations(ioutil.NopCloser(strings.NewReader("42 165 mount\ngarbage\n7 101 ptrace\n")))
+
+	notices := []string{}
+	for n := be.Head(); n != nil; n = n.Next() {
+		if n.Record.Level == logging.NOTICE {
+			notices = append(notices, n.Record.Message())
+		}
+	}
+	expected := []string{
+		"Seccomp violation by pid 42: syscall 165 (mount)",
+		"Seccomp violation by pid 7: syscall 101 (ptrace)",
+	}
+	if strings.Join(notices, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting notices %q, got %q", expected, notices)
+	}
+}
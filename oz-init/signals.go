@@ -0,0 +1,67 @@
+package ozinit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Signals oz-init listens for, those not configured as shutdown signals are
+// forwarded to the applications.
+var handledSignals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+func parseSignalName(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := handledSignals[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported shutdown signal (%s)", name)
+	}
+	return sig, nil
+}
+
+// setupSignals parses the shutdown signals of the config and returns every
+// signal which should be delivered to processSignals.
+func (st *initState) setupSignals() ([]os.Signal, error) {
+	st.shutdownSignals = make(map[os.Signal]bool)
+	for _, name := range st.config.ShutdownSignals {
+		sig, err := parseSignalName(name)
+		if err != nil {
+			return nil, err
+		}
+		st.shutdownSignals[sig] = true
+	}
+	// The daemon stops sandboxes with SIGINT
+	if !st.shutdownSignals[syscall.SIGINT] {
+		return nil, fmt.Errorf("shutdown_signals must include SIGINT, the daemon stops sandboxes with it")
+	}
+	sigs := []os.Signal{}
+	for _, sig := range handledSignals {
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// forwardSignal delivers sig to the applications launched from the profile,
+// shells and debuggers entered into the sandbox are left alone.
+func (st *initState) forwardSignal(sig os.Signal) {
+	for _, c := range st.childrenVector() {
+		if !c.track {
+			continue
+		}
+		st.log.Info("Forwarding signal (%v) to pid=%d", sig, c.cmd.Process.Pid)
+		if err := c.cmd.Process.Signal(sig); err != nil {
+			st.log.Warning("Failed to forward signal (%v) to pid=%d: %v", sig, c.cmd.Process.Pid, err)
+		}
+	}
+}
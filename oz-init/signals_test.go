@@ -0,0 +1,127 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/xpra"
+)
+
+func TestParseSignalName(t *testing.T) {
+	for name, expected := range map[string]syscall.Signal{
+		"SIGTERM": syscall.SIGTERM,
+		"hup":     syscall.SIGHUP,
+		"SigUsr1": syscall.SIGUSR1,
+	} {
+		sig, err := parseSignalName(name)
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %v", name, err)
+		} else if sig != expected {
+			t.Errorf("expecting %s to parse as %v, got %v", name, expected, sig)
+		}
+	}
+	for _, name := range []string{"SIGKILL", "SIGCHLD", "15", ""} {
+		if _, err := parseSignalName(name); err == nil {
+			t.Errorf("expecting signal (%s) to be rejected", name)
+		}
+	}
+}
+
+func TestSetupSignals(t *testing.T) {
+	st := &initState{
+		log:    logging.MustGetLogger("oz-init-test"),
+		config: oz.NewDefaultConfig(),
+	}
+	sigs, err := st.setupSignals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sigs) != len(handledSignals) {
+		t.Errorf("expecting %d handled signals, got %d", len(handledSignals), len(sigs))
+	}
+	if !st.shutdownSignals[syscall.SIGTERM] || !st.shutdownSignals[syscall.SIGINT] {
+		t.Error("expecting SIGTERM and SIGINT to shut down by default")
+	}
+	if st.shutdownSignals[syscall.SIGHUP] {
+		t.Error("expecting SIGHUP to be forwarded by default")
+	}
+
+	st.config.ShutdownSignals = []string{"SIGTERM", "SIGHUP"}
+	if _, err := st.setupSignals(); err == nil {
+		t.Error("expecting shutdown signals without SIGINT to be rejected")
+	}
+}
+
+func TestWatchStartupAbortStop(t *testing.T) {
+	st := &initState{
+		log:             logging.MustGetLogger("oz-init-test"),
+		shutdownSignals: map[os.Signal]bool{syscall.SIGTERM: true},
+	}
+	sigs := make(chan os.Signal, 1)
+	stop := st.watchStartupAbort(sigs)
+	sigs <- syscall.SIGHUP
+	for deadline := time.Now().Add(time.Second); len(sigs) > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan []os.Signal)
+	go func() {
+		done <- stop()
+	}()
+	select {
+	case pending := <-done:
+		if !reflect.DeepEqual(pending, []os.Signal{syscall.SIGHUP}) {
+			t.Errorf("expecting SIGHUP to be queued, got %v", pending)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("startup abort watch did not stop")
+	}
+	// Signals are left to processSignals once the watch is stopped
+	sigs <- syscall.SIGTERM
+	if sig := <-sigs; sig != syscall.SIGTERM {
+		t.Errorf("expecting SIGTERM to be left in the channel, got %v", sig)
+	}
+}
+
+func TestReleaseStartup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-init-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	metadata := path.Join(dir, "metadata.json")
+	workdir := path.Join(dir, "xpra")
+	for _, p := range []string{metadata, path.Join(workdir, ":100.log")} {
+		os.MkdirAll(path.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	st := &initState{
+		log:      logging.MustGetLogger("oz-init-test"),
+		config:   &oz.Config{MetadataPath: metadata, CleanXpraWorkdir: true},
+		sockaddr: path.Join(dir, "socket"),
+		xpras:    map[int]*xpra.Xpra{100: {WorkDir: workdir, Process: cmd}},
+	}
+	st.releaseStartup()
+	for _, p := range []string{metadata, workdir} {
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("expecting %s to be removed by the aborted startup", p)
+		}
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Error("expecting xpra to be killed by the aborted startup")
+	}
+}
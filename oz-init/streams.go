@@ -0,0 +1,154 @@
+package ozinit
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/subgraph/oz/ipc"
+)
+
+// Chunks of output queued for a subscriber, output is dropped once a
+// subscriber falls this far behind so the applications never block on it
+const streamQueueLength = 256
+
+// outputStream relays the raw output of the applications to a client which
+// subscribed with SubscribeOutput. Output is logged line by line until a
+// client subscribes, and discarded once that client disconnects.
+type outputStream struct {
+	label    string
+	lock     sync.Mutex
+	queue    chan []byte
+	detached bool
+}
+
+func newOutputStreams() map[string]*outputStream {
+	return map[string]*outputStream{
+		"stdout": &outputStream{label: "stdout"},
+		"stderr": &outputStream{label: "stderr"},
+	}
+}
+
+// write returns false when there is no subscriber and the data must be logged
+func (s *outputStream) write(data []byte) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.queue == nil {
+		return s.detached
+	}
+	select {
+	case s.queue <- append([]byte(nil), data...):
+	default:
+	}
+	return true
+}
+
+func (s *outputStream) attach(f *os.File) {
+	q := make(chan []byte, streamQueueLength)
+	s.lock.Lock()
+	if s.queue != nil {
+		close(s.queue)
+	}
+	s.queue = q
+	s.detached = false
+	s.lock.Unlock()
+	go s.drain(f, q)
+}
+
+// drain writes the queued output to the subscriber f without holding the
+// lock, until the queue is closed or the subscriber disconnects.
+func (s *outputStream) drain(f *os.File, q chan []byte) {
+	defer f.Close()
+	for data := range q {
+		if _, err := f.Write(data); err != nil {
+			s.lock.Lock()
+			if s.queue == q {
+				close(q)
+				s.queue = nil
+				s.detached = true
+			}
+			s.lock.Unlock()
+			return
+		}
+	}
+}
+
+func (st *initState) relayApplicationOutput(r io.ReadCloser, stream *outputStream) {
+	buf := make([]byte, 4096)
+	pending := []byte{}
+	limit := st.newCaptureLimit()
+	max := st.maxLogLine()
+	dropped := 0
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if stream.write(buf[:n]) {
+				pending = pending[:0]
+				dropped = 0
+			} else {
+				pending = append(pending, buf[:n]...)
+				for {
+					idx := bytes.IndexByte(pending, '\n')
+					if idx < 0 {
+						break
+					}
+					end := idx
+					if end > max {
+						dropped += end - max
+						end = max
+					}
+					line := string(pending[:end])
+					st.captureLine(limit, stream.label, line)
+					if dropped > 0 {
+						st.log.Warning("(%s) [line truncated to %d bytes, %d bytes discarded]", stream.label, max, dropped)
+						dropped = 0
+					}
+					st.checkReadyLine(line)
+					pending = pending[idx+1:]
+				}
+				// Keep at most max bytes of a line, the rest is discarded
+				if len(pending) > max {
+					dropped += len(pending) - max
+					pending = pending[:max]
+				}
+			}
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				st.captureLine(limit, stream.label, string(pending))
+			}
+			return
+		}
+	}
+}
+
+func (st *initState) handleSubscribeOutput(so *SubscribeOutputMsg, msg *ipc.Message) error {
+	if !st.profile.StreamOutput {
+		return msg.Respond(&ErrorMsg{"Output streaming is not enabled in profile"})
+	}
+	fds := []int{}
+	files := []*os.File{}
+	for _, label := range []string{"stdout", "stderr"} {
+		pair, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return msg.Respond(&ErrorMsg{err.Error()})
+		}
+		st.streams[label].attach(os.NewFile(uintptr(pair[0]), label))
+		f := os.NewFile(uintptr(pair[1]), label)
+		files = append(files, f)
+		fds = append(fds, int(f.Fd()))
+	}
+	st.log.Info("Client subscribed to application output")
+	// Subscribers keep their connection open as long as they read
+	msg.Detach()
+	err := msg.Respond(&OkMsg{}, fds...)
+	for _, f := range files {
+		f.Close()
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func streamPair(t *testing.T) (*os.File, *os.File) {
+	pair, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.NewFile(uintptr(pair[0]), "sink"), os.NewFile(uintptr(pair[1]), "client")
+}
+
+func TestOutputStreamSlowSubscriber(t *testing.T) {
+	s := &outputStream{label: "stdout"}
+	if s.write([]byte("logged\n")) {
+		t.Error("expecting output to be logged without a subscriber")
+	}
+	sink, client := streamPair(t)
+	defer client.Close()
+	s.attach(sink)
+
+	// The subscriber never reads, writing must not block the application
+	written := make(chan struct{})
+	go func() {
+		chunk := []byte(strings.Repeat("x", 4096))
+		for i := 0; i < 4*streamQueueLength; i++ {
+			s.write(chunk)
+		}
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expecting writes to a slow subscriber not to block")
+	}
+	if len(s.queue) > streamQueueLength {
+		t.Errorf("expecting at most %d queued chunks, got %d", streamQueueLength, len(s.queue))
+	}
+}
+
+func TestOutputStreamDetach(t *testing.T) {
+	s := &outputStream{label: "stdout"}
+	sink, client := streamPair(t)
+	s.attach(sink)
+	s.write([]byte("relayed\n"))
+	buf := make([]byte, 64)
+	n, err := client.Read(buf)
+	if err != nil || string(buf[:n]) != "relayed\n" {
+		t.Fatalf("expecting the output relayed to the subscriber, got %q %v", buf[:n], err)
+	}
+	client.Close()
+
+	// Output is discarded, not logged, once the subscriber disconnected
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if !s.write([]byte("lost\n")) {
+			t.Fatal("expecting output not to be logged after the subscriber disconnected")
+		}
+		s.lock.Lock()
+		detached := s.detached
+		s.lock.Unlock()
+		if detached {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expecting the stream to detach from a closed subscriber")
+}
+
+func TestRelayApplicationOutputLongLine(t *testing.T) {
+	be := logging.InitForTesting(logging.DEBUG)
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{},
+		config:  &oz.Config{MaxLogLineBytes: 1024},
+	}
+	out := "before\n" + strings.Repeat("x", 100*1024) + "\nafter\n"
+	st.relayApplicationOutput(ioutil.NopCloser(strings.NewReader(out)), &outputStream{label: "stdout"})
+
+	logged := []string{}
+	for n := be.Head(); n != nil; n = n.Next() {
+		logged = append(logged, n.Record.Message())
+	}
+	all := strings.Join(logged, "\n")
+	for _, s := range []string{"(stdout) before", "[line truncated to 1024 bytes", "(stdout) after"} {
+		if !strings.Contains(all, s) {
+			t.Errorf("expecting %q to be logged, got:\n%s", s, all)
+		}
+	}
+	if strings.Contains(all, strings.Repeat("x", 1025)) {
+		t.Error("expecting the long line to be truncated")
+	}
+}
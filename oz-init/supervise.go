@@ -0,0 +1,25 @@
+package ozinit
+
+import (
+	"path"
+	"strconv"
+)
+
+// supervisedCommand wraps the command, after the seccomp wrapper, so that
+// oz-supervise is the process oz-init tracks. The profile written on stdin
+// for oz-seccomp is replayed by the supervisor to every restart.
+func (st *initState) supervisedCommand(cpath, argv0 string, cmdArgs []string, replayStdin bool) (string, []string) {
+	args := []string{}
+	if st.profile.SupervisorMaxRestarts > 0 {
+		args = append(args, "-max-restarts", strconv.Itoa(st.profile.SupervisorMaxRestarts))
+	}
+	if replayStdin {
+		args = append(args, "-replay-stdin")
+	}
+	if argv0 != "" {
+		args = append(args, "-argv0", argv0)
+	}
+	args = append(args, "--", cpath)
+	st.log.Notice("Supervising %s with oz-supervise", cpath)
+	return path.Join(st.config.PrefixPath, "bin", "oz-supervise"), append(args, cmdArgs...)
+}
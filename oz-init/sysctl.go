@@ -0,0 +1,69 @@
+package ozinit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Kernel tunables which are scoped to the IPC namespace of the sandbox.
+// Anything not listed here is either host global or owned by another
+// part of oz (hostname, domainname) and is rejected.
+var namespacedKernelTunables = map[string]bool{
+	"kernel.msgmax":             true,
+	"kernel.msgmnb":             true,
+	"kernel.msgmni":             true,
+	"kernel.sem":                true,
+	"kernel.shmall":             true,
+	"kernel.shmmax":             true,
+	"kernel.shmmni":             true,
+	"kernel.shm_rmid_forced":    true,
+	"fs.mqueue.msg_default":     true,
+	"fs.mqueue.msg_max":         true,
+	"fs.mqueue.msgsize_default": true,
+	"fs.mqueue.msgsize_max":     true,
+	"fs.mqueue.queues_max":      true,
+}
+
+func validateKernelTunables(tunables map[string]string) error {
+	for name, value := range tunables {
+		if !namespacedKernelTunables[name] {
+			return fmt.Errorf("kernel tunable (%s) is not namespaced and cannot be set from a sandbox", name)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("kernel tunable (%s) has an empty value", name)
+		}
+	}
+	return nil
+}
+
+func kernelTunablePath(name string) string {
+	return path.Join("/proc/sys", strings.Replace(name, ".", "/", -1))
+}
+
+func (st *initState) setupKernelTunables() error {
+	if len(st.profile.KernelTunables) == 0 {
+		return nil
+	}
+	if err := validateKernelTunables(st.profile.KernelTunables); err != nil {
+		return err
+	}
+	if st.profile.NoSysProc {
+		return fmt.Errorf("kernel tunables cannot be set when /proc is not mounted (no_sys_proc)")
+	}
+	names := make([]string, 0, len(st.profile.KernelTunables))
+	for name := range st.profile.KernelTunables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := st.profile.KernelTunables[name]
+		if err := ioutil.WriteFile(kernelTunablePath(name), []byte(value+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to set kernel tunable %s: %v", name, err)
+		}
+		st.log.Info("Kernel tunable set: %s = %s", name, value)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package ozinit
+
+import "testing"
+
+func TestValidateKernelTunables(t *testing.T) {
+	for _, test := range []struct {
+		tunables map[string]string
+		valid    bool
+	}{
+		{nil, true},
+		{map[string]string{"kernel.shmmax": "268435456", "fs.mqueue.msg_max": "32"}, true},
+		{map[string]string{"kernel.sem": "250 32000 32 128"}, true},
+		{map[string]string{"kernel.hostname": "sandbox"}, false},
+		{map[string]string{"net.ipv4.ip_forward": "1"}, false},
+		{map[string]string{"kernel.shmmax": " "}, false},
+	} {
+		err := validateKernelTunables(test.tunables)
+		if test.valid && err != nil {
+			t.Errorf("expecting %v to be accepted, got %v", test.tunables, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expecting %v to be rejected", test.tunables)
+		}
+	}
+	if p := kernelTunablePath("fs.mqueue.msg_max"); p != "/proc/sys/fs/mqueue/msg_max" {
+		t.Errorf("unexpected tunable path: %s", p)
+	}
+}
@@ -0,0 +1,166 @@
+package ozinit
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/subgraph/oz/ipc"
+)
+
+const (
+	sysPidfdOpen       = 434
+	sysProcessMadvise  = 440
+	madvPageout        = 21
+	madviseBatchRanges = 512
+)
+
+func sandboxPids() []int {
+	pids := []int{}
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return pids
+	}
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// processRss returns the resident set size of pid in bytes
+func processRss(pid int) uint64 {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "VmRSS:" {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			return kb << 10
+		}
+	}
+	return 0
+}
+
+func sandboxRss() uint64 {
+	total := uint64(0)
+	for _, pid := range sandboxPids() {
+		total += processRss(pid)
+	}
+	return total
+}
+
+// remoteIovec is a struct iovec describing memory of another process, the
+// address is never dereferenced so it is kept as an integer.
+type remoteIovec struct {
+	base uintptr
+	len  uint64
+}
+
+// pageoutProcess advises the kernel to reclaim every mapping of pid
+func pageoutProcess(pid int) error {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, _, errno := syscall.Syscall(sysPidfdOpen, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	pidfd := int(r)
+	defer syscall.Close(pidfd)
+
+	iovs := make([]remoteIovec, 0, madviseBatchRanges)
+	flush := func() error {
+		if len(iovs) == 0 {
+			return nil
+		}
+		_, _, errno := syscall.Syscall6(sysProcessMadvise, uintptr(pidfd), uintptr(unsafe.Pointer(&iovs[0])), uintptr(len(iovs)), madvPageout, 0, 0)
+		iovs = iovs[:0]
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// start-end perms offset dev inode path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		bounds := strings.SplitN(fields[0], "-", 2)
+		start, err1 := strconv.ParseUint(bounds[0], 16, 64)
+		end, err2 := strconv.ParseUint(bounds[len(bounds)-1], 16, 64)
+		if err1 != nil || err2 != nil || end <= start {
+			continue
+		}
+		// Locked and special mappings such as [vsyscall] are rejected
+		if len(fields) >= 6 && strings.HasPrefix(fields[5], "[v") {
+			continue
+		}
+		iovs = append(iovs, remoteIovec{base: uintptr(start), len: end - start})
+		if len(iovs) == madviseBatchRanges {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// trimMemory asks the kernel to reclaim memory of the sandbox, through the
+// cgroup of the applications when it has a memory limit, or by paging out
+// each process otherwise. The cgroup of oz-init is never used for a trim, it
+// may be shared with the daemon and the other sandboxes.
+func (st *initState) trimMemory(amount uint64) string {
+	debug.FreeOSMemory()
+	st.lock.Lock()
+	var reclaim *os.File
+	if st.appsCgroup != nil {
+		reclaim = st.appsCgroup.reclaim
+	}
+	st.lock.Unlock()
+	if reclaim != nil {
+		_, err := reclaim.WriteString(strconv.FormatUint(amount, 10))
+		if err == nil {
+			return "cgroup"
+		}
+		// EAGAIN only means less than the requested amount was reclaimed
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EAGAIN {
+			return "cgroup"
+		}
+		st.log.Warning("Unable to reclaim memory through the cgroup: %v", err)
+	}
+	self := os.Getpid()
+	for _, pid := range sandboxPids() {
+		if pid == self {
+			continue
+		}
+		if err := pageoutProcess(pid); err != nil {
+			st.log.Debug("Unable to page out memory of pid %d: %v", pid, err)
+		}
+	}
+	return "madvise"
+}
+
+func (st *initState) handleTrimMemory(tm *TrimMemoryMsg, msg *ipc.Message) error {
+	before := sandboxRss()
+	method := st.trimMemory(before)
+	after := sandboxRss()
+	st.log.Info("Trimmed sandbox memory with %s: RSS %d kB -> %d kB", method, before>>10, after>>10)
+	return msg.Respond(&TrimMemoryResp{RssBefore: before, RssAfter: after, Method: method})
+}
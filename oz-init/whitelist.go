@@ -0,0 +1,59 @@
+package ozinit
+
+import (
+	"path"
+
+	"github.com/subgraph/oz"
+	"github.com/subgraph/oz/fs"
+)
+
+// dedupWhitelist drops the whitelist items bound to the same target as a later
+// item, so a path listed by a base profile and again by a child profile is
+// bound once with the flags of the later item. The item keeps the position of
+// the first occurrence so parents are still bound before their children.
+// Items whose target cannot be resolved are kept for the bind to report.
+func dedupWhitelist(wlist []oz.WhitelistItem, resolve func(string) (string, error)) []oz.WhitelistItem {
+	targets := make([]string, len(wlist))
+	last := make(map[string]int)
+	for i, wl := range wlist {
+		if wl.Path == "" {
+			continue
+		}
+		t := wl.Target
+		if t == "" {
+			t = wl.Path
+		}
+		resolved, err := resolve(t)
+		if err != nil {
+			continue
+		}
+		targets[i] = path.Clean(resolved)
+		last[targets[i]] = i
+	}
+	out := make([]oz.WhitelistItem, 0, len(wlist))
+	for i, wl := range wlist {
+		t := targets[i]
+		if t == "" {
+			out = append(out, wl)
+			continue
+		}
+		j, ok := last[t]
+		if !ok {
+			// Already replaced by a later item
+			continue
+		}
+		out = append(out, wlist[j])
+		delete(last, t)
+	}
+	return out
+}
+
+func (st *initState) dedupWhitelist(fsys *fs.Filesystem, wlist []oz.WhitelistItem) []oz.WhitelistItem {
+	deduped := dedupWhitelist(wlist, func(p string) (string, error) {
+		return fs.ResolvePathNoGlob(p, st.display, st.user, fsys.GetXDGDirs(), st.profile)
+	})
+	if n := len(wlist) - len(deduped); n > 0 {
+		st.log.Info("Dropped %d whitelist items bound again to the same target", n)
+	}
+	return deduped
+}
@@ -0,0 +1,54 @@
+package ozinit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/subgraph/oz"
+)
+
+func TestDedupWhitelist(t *testing.T) {
+	resolve := func(p string) (string, error) {
+		return strings.Replace(p, "${HOME}", "/home/user", -1), nil
+	}
+	base := []oz.WhitelistItem{
+		{Path: "${HOME}/.config/app", ReadOnly: true},
+		{Path: "/etc/app"},
+		{Path: "/srv/data", Target: "${HOME}/data"},
+	}
+	child := []oz.WhitelistItem{
+		{Path: "/home/user/.config/app/", CanCreate: true},
+		{Path: "/var/lib/app", Target: "/home/user/data"},
+		{Path: "/usr/share/app"},
+	}
+	out := dedupWhitelist(append(base, child...), resolve)
+	if len(out) != 4 {
+		t.Fatalf("expecting 4 items after removing duplicates, got %d: %+v", len(out), out)
+	}
+	if out[0].ReadOnly || !out[0].CanCreate {
+		t.Errorf("expecting the flags of the child item to win, got %+v", out[0])
+	}
+	if out[1].Path != "/etc/app" {
+		t.Errorf("expecting unique items to keep their order, got %+v", out[1])
+	}
+	if out[2].Path != "/var/lib/app" {
+		t.Errorf("expecting the child source for a target bound twice, got %+v", out[2])
+	}
+	if out[3].Path != "/usr/share/app" {
+		t.Errorf("expecting the last child item at the end, got %+v", out[3])
+	}
+}
+
+func TestDedupWhitelistKeepsUnresolved(t *testing.T) {
+	resolve := func(p string) (string, error) {
+		if strings.Contains(p, "${") {
+			return "", fmt.Errorf("unable to resolve %s", p)
+		}
+		return p, nil
+	}
+	wl := []oz.WhitelistItem{{Path: "${UNKNOWN}/a"}, {Path: "${UNKNOWN}/a"}, {Path: ""}}
+	if out := dedupWhitelist(wl, resolve); len(out) != 3 {
+		t.Errorf("expecting unresolved and empty items to be kept, got %+v", out)
+	}
+}
@@ -0,0 +1,88 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xpraFileDisplay returns the display number of an xpra socket or log file,
+// these are named <hostname>-<display> and :<display>.log in the workdir.
+func xpraFileDisplay(name string) (int, bool) {
+	name = strings.TrimSuffix(name, ".log")
+	i := strings.LastIndexAny(name, "-:")
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func isSocketListening(p string) bool {
+	c, err := net.DialTimeout("unix", p, time.Second)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// cleanXpraWorkdir removes the sockets nobody listens on anymore from the
+// workdir, and the logs of the display when no live socket uses it. The
+// workdir is shared by the sandboxes of the profile so nothing belonging to a
+// running session on another display is removed.
+func (st *initState) cleanXpraWorkdir(workdir string, display int) {
+	entries, err := ioutil.ReadDir(workdir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			st.log.Warning("Unable to read xpra workdir %s: %v", workdir, err)
+		}
+		return
+	}
+	active := false
+	logs := []string{}
+	for _, fi := range entries {
+		p := path.Join(workdir, fi.Name())
+		n, ok := xpraFileDisplay(fi.Name())
+		switch {
+		case fi.Mode()&os.ModeSocket != 0:
+			if isSocketListening(p) {
+				if ok && n == display {
+					active = true
+				}
+				continue
+			}
+			st.log.Debug("Removing stale xpra socket %s", p)
+			if err := os.Remove(p); err != nil {
+				st.log.Warning("Unable to remove stale xpra socket %s: %v", p, err)
+			}
+		case fi.Mode().IsRegular() && strings.HasSuffix(fi.Name(), ".log") && ok && n == display:
+			logs = append(logs, p)
+		}
+	}
+	if active {
+		return
+	}
+	for _, p := range logs {
+		st.log.Debug("Removing stale xpra log %s", p)
+		if err := os.Remove(p); err != nil {
+			st.log.Warning("Unable to remove stale xpra log %s: %v", p, err)
+		}
+	}
+}
+
+// removeXpraWorkdir cleans the files of the stopped server and removes the
+// workdir once no other session uses it.
+func (st *initState) removeXpraWorkdir(workdir string, display int) {
+	st.cleanXpraWorkdir(workdir, display)
+	if err := os.Remove(workdir); err != nil && !os.IsNotExist(err) {
+		st.log.Debug("Xpra workdir %s not removed: %v", workdir, err)
+	}
+}
@@ -0,0 +1,112 @@
+package ozinit
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/op/go-logging"
+)
+
+func TestXpraFileDisplay(t *testing.T) {
+	for name, expected := range map[string]int{
+		"host-100":     100,
+		"my-host-101":  101,
+		":102.log":     102,
+		"host-103.log": 103,
+	} {
+		n, ok := xpraFileDisplay(name)
+		if !ok || n != expected {
+			t.Errorf("expecting display %d for %s, got %d %v", expected, name, n, ok)
+		}
+	}
+	for _, name := range []string{"host", "xpra.conf", "host-abc"} {
+		if _, ok := xpraFileDisplay(name); ok {
+			t.Errorf("expecting no display for %s", name)
+		}
+	}
+}
+
+func listenUnix(t *testing.T, p string) net.Listener {
+	l, err := net.Listen("unix", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestCleanXpraWorkdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oz-xpra-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st := &initState{log: logging.MustGetLogger("oz-init-test")}
+
+	// A socket left by a server which died, and the live one of another display
+	stale := listenUnix(t, path.Join(dir, "host-100"))
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	live := listenUnix(t, path.Join(dir, "host-101"))
+	defer live.Close()
+	for _, name := range []string{":100.log", ":101.log", "xpra.conf"} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st.cleanXpraWorkdir(dir, 100)
+	for name, kept := range map[string]bool{
+		"host-100":  false,
+		":100.log":  false,
+		"host-101":  true,
+		":101.log":  true,
+		"xpra.conf": true,
+	} {
+		_, err := os.Lstat(path.Join(dir, name))
+		if kept && err != nil {
+			t.Errorf("expecting %s to be kept: %v", name, err)
+		} else if !kept && err == nil {
+			t.Errorf("expecting %s to be removed", name)
+		}
+	}
+
+	// Nothing is removed for a display still in use
+	st.cleanXpraWorkdir(dir, 101)
+	if _, err := os.Lstat(path.Join(dir, ":101.log")); err != nil {
+		t.Errorf("expecting the log of an active display to be kept: %v", err)
+	}
+}
+
+func TestHasDisplay(t *testing.T) {
+	st := &initState{display: 100, extraDisplays: []int{101, 103}}
+	for _, d := range []int{100, 101, 103} {
+		if !st.hasDisplay(d) {
+			t.Errorf("expecting display :%d to be a display of the sandbox", d)
+		}
+	}
+	for _, d := range []int{0, 102, 104} {
+		if st.hasDisplay(d) {
+			t.Errorf("expecting display :%d not to be a display of the sandbox", d)
+		}
+	}
+}
+
+func TestNextDisplay(t *testing.T) {
+	st := &initState{reserved: []int{101, 102, 104}}
+	for _, test := range []struct {
+		display  int
+		expected int
+	}{
+		{99, 100},
+		{100, 103},
+		{103, 105},
+		{104, 105},
+	} {
+		if next := st.nextDisplay(test.display); next != test.expected {
+			t.Errorf("expecting display :%d after :%d, got :%d", test.expected, test.display, next)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ozinit
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time left to a client to reconnect before the sandbox is shut down after
+// the last xpra client disconnected
+const XPRA_DISCONNECT_GRACE = 5 * time.Second
+
+// xpraClients counts the clients attached to the xpra server from the
+// messages it logs when a client connects and disconnects.
+type xpraClients struct {
+	lock  sync.Mutex
+	count int
+	timer *time.Timer
+}
+
+func isXpraClientConnected(line string) bool {
+	return strings.Contains(line, "Handshake complete; enabling connection")
+}
+
+func isXpraClientDisconnected(line string) bool {
+	return strings.Contains(line, "client") && strings.Contains(line, "disconnected")
+}
+
+// trackXpraClients shuts down the sandbox once the last client disconnected
+// and none reconnected within the grace period, when the profile sets
+// exit_on_disconnect.
+func (st *initState) trackXpraClients(line string) {
+	c := &st.xpraClients
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch {
+	case isXpraClientConnected(line):
+		c.count++
+		if c.timer != nil {
+			c.timer.Stop()
+			c.timer = nil
+		}
+		st.log.Debug("Xpra client connected, %d attached", c.count)
+	case isXpraClientDisconnected(line) && c.count > 0:
+		c.count--
+		st.log.Debug("Xpra client disconnected, %d attached", c.count)
+		if c.count > 0 || !st.profile.XServer.ExitOnDisconnect {
+			return
+		}
+		st.log.Info("Last xpra client disconnected, shutting down in %v unless a client reconnects", XPRA_DISCONNECT_GRACE)
+		c.timer = time.AfterFunc(XPRA_DISCONNECT_GRACE, func() {
+			c.lock.Lock()
+			attached := c.count
+			c.lock.Unlock()
+			if attached == 0 {
+				st.log.Info("Shutting down sandbox after the last xpra client disconnected")
+				st.shutdown()
+			}
+		})
+	}
+}
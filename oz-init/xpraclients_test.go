@@ -0,0 +1,36 @@
+package ozinit
+
+import (
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/subgraph/oz"
+)
+
+func TestTrackXpraClients(t *testing.T) {
+	st := &initState{
+		log:     logging.MustGetLogger("oz-init-test"),
+		profile: &oz.Profile{XServer: oz.XServerConf{ExitOnDisconnect: true}},
+	}
+	connected := "2017-01-01 00:00:00,000 Handshake complete; enabling connection"
+	disconnected := "2017-01-01 00:00:00,000 xpra client 1 disconnected."
+
+	st.trackXpraClients(disconnected)
+	if st.xpraClients.count != 0 || st.xpraClients.timer != nil {
+		t.Fatal("expecting a disconnect without clients to be ignored")
+	}
+	st.trackXpraClients(connected)
+	st.trackXpraClients(connected)
+	st.trackXpraClients(disconnected)
+	if st.xpraClients.timer != nil {
+		t.Error("expecting no shutdown while a client is attached")
+	}
+	st.trackXpraClients(disconnected)
+	if st.xpraClients.timer == nil {
+		t.Fatal("expecting a shutdown to be scheduled after the last client disconnected")
+	}
+	st.trackXpraClients(connected)
+	if st.xpraClients.timer != nil || st.xpraClients.count != 1 {
+		t.Error("expecting a reconnecting client to cancel the shutdown")
+	}
+}
@@ -0,0 +1,43 @@
+package ozinit
+
+import (
+	"time"
+)
+
+// waitXpraReady returns whether the xpra server being started reported its
+// display ready, or a conflict on it, before the timeout of the oz config.
+func (st *initState) waitXpraReady() bool {
+	ready := make(chan struct{})
+	go func() {
+		st.xpraReady.Wait()
+		close(ready)
+	}()
+	select {
+	case <-ready:
+		return true
+	case <-time.After(st.xpraReadyTimeout()):
+		return false
+	}
+}
+
+func (st *initState) xpraReadyTimeout() time.Duration {
+	timeout := time.Duration(st.config.XpraReadyTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DEFAULT_XPRA_READY_TIMEOUT
+	}
+	return timeout
+}
+
+// failXpraStartup logs what xpra printed before giving up on it and aborts
+// the startup, the daemon otherwise waits forever for the sandbox.
+func (st *initState) failXpraStartup(display int) {
+	st.log.Error("Xpra did not report display :%d ready within %v", display, st.xpraReadyTimeout())
+	lines := st.xpraOutput.snapshot()
+	if len(lines) == 0 {
+		st.log.Error("Xpra printed nothing before the timeout")
+	}
+	for _, line := range lines {
+		st.log.Error("%s", line)
+	}
+	st.abortStartup()
+}
@@ -0,0 +1,37 @@
+package ozinit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/subgraph/oz"
+)
+
+func TestWaitXpraReady(t *testing.T) {
+	st := &initState{config: &oz.Config{XpraReadyTimeout: 1}}
+	st.xpraReady.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.xpraReady.Done()
+	}()
+	if !st.waitXpraReady() {
+		t.Fatal("expecting xpra to be ready")
+	}
+
+	st.xpraReady.Add(1)
+	start := time.Now()
+	if st.waitXpraReady() {
+		t.Fatal("expecting the wait to time out")
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expecting the wait to last the timeout, returned after %v", elapsed)
+	}
+	st.xpraReady.Done()
+}
+
+func TestXpraReadyTimeoutDefault(t *testing.T) {
+	st := &initState{config: &oz.Config{}}
+	if st.xpraReadyTimeout() != DEFAULT_XPRA_READY_TIMEOUT {
+		t.Errorf("expecting the default timeout, got %v", st.xpraReadyTimeout())
+	}
+}
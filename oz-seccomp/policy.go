@@ -0,0 +1,99 @@
+package seccomp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/subgraph/oz"
+	"github.com/twtiger/gosecco/parser"
+)
+
+// loadPolicySource reads a seccomp policy file and applies the adjustments
+// requested by the profile before it is handed to the compiler.
+// In blacklist mode syscalls are allowed by dropping their rule, in whitelist
+// mode they are allowed by appending an unconditional rule.
+func loadPolicySource(fpath string, p *oz.Profile, blacklist bool) (parser.Source, error) {
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read seccomp policy (%s): %v", fpath, err)
+	}
+	lines := strings.Split(string(data), "\n")
+
+	allowed := []string{}
+	if p.AllowPtrace {
+		log.Warning("Profile allows ptrace, it will not be filtered by seccomp. Sandbox isolation is reduced!")
+		allowed = append(allowed, "ptrace")
+	}
+
+	for _, name := range allowed {
+		if blacklist {
+			lines = dropPolicyRule(lines, name)
+		} else if !hasPolicyRule(lines, name) {
+			lines = append(lines, name+": 1")
+		}
+	}
+
+	if p.Seccomp.PersonalityLocked() {
+		lines = lockPersonality(lines, blacklist)
+	}
+	return &parser.StringSource{Name: fpath, Content: strings.Join(lines, "\n")}, nil
+}
+
+// Personalities which keep ASLR and the other defaults, 0xffffffff only
+// queries the current personality.
+const (
+	safePersonalities   = "arg0 == 0 || arg0 == 8 || arg0 == 0xffffffff"
+	unsafePersonalities = "arg0 != 0 && arg0 != 8 && arg0 != 0xffffffff"
+)
+
+// lockPersonality restricts personality to the default personalities so the
+// sandbox cannot disable ASLR with ADDR_NO_RANDOMIZE. A policy already
+// denying personality entirely is left untouched.
+func lockPersonality(lines []string, blacklist bool) []string {
+	if blacklist {
+		if hasPolicyRule(lines, "personality") {
+			return lines
+		}
+		return append(lines, "personality: "+unsafePersonalities)
+	}
+	// A whitelist without personality already denies it, only narrow a rule
+	// the profile whitelisted
+	if !hasPolicyRule(lines, "personality") {
+		return lines
+	}
+	lines = dropPolicyRule(lines, "personality")
+	return append(lines, "personality: "+safePersonalities)
+}
+
+func policyRuleName(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[:idx])
+}
+
+func hasPolicyRule(lines []string, name string) bool {
+	for _, line := range lines {
+		if policyRuleName(line) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func dropPolicyRule(lines []string, name string) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if policyRuleName(line) == name {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package seccomp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLockPersonality(t *testing.T) {
+	for _, test := range []struct {
+		lines     []string
+		blacklist bool
+		expected  []string
+	}{
+		{
+			[]string{"read: 1", "personality: 1"},
+			false,
+			[]string{"read: 1", "personality: " + safePersonalities},
+		},
+		{
+			[]string{"read: 1", "# personality: 1"},
+			false,
+			[]string{"read: 1", "# personality: 1"},
+		},
+		{
+			[]string{"ptrace: 1"},
+			true,
+			[]string{"ptrace: 1", "personality: " + unsafePersonalities},
+		},
+		{
+			[]string{"personality: 1"},
+			true,
+			[]string{"personality: 1"},
+		},
+	} {
+		if lines := lockPersonality(test.lines, test.blacklist); !reflect.DeepEqual(lines, test.expected) {
+			t.Errorf("expecting %v to be locked as %v, got %v", test.lines, test.expected, lines)
+		}
+	}
+}
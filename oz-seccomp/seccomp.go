@@ -35,6 +35,7 @@ func Main() {
 	policyptr := flag.String("policy", "", "seccomp policy path")
 	profilepath := flag.String("profile", "", "optional seccomp profile path")
 	newprivs := flag.Bool("allow-new-privs", false, "allow traced program to set new seccomp filters")
+	argv0 := flag.String("argv0", "", "argv[0] given to the command, its path if empty")
 
 	flag.Parse()
 
@@ -48,6 +49,9 @@ func Main() {
 
 	cmd := args[0]
 	cmdArgs := args
+	if *argv0 != "" {
+		cmdArgs = append([]string{*argv0}, args[1:]...)
+	}
 	fpath := ""
 
 	oz.CheckSettingsOverRide()
@@ -143,7 +147,11 @@ func Main() {
 			settings.DefaultNegativeAction = "trace"
 			settings.DefaultPolicyAction = "trace"
 		}
-		filter, err := seccomp.Prepare(fpath, settings)
+		src, err := loadPolicySource(fpath, p, false)
+		if err != nil {
+			log.Fatal("[FATAL] Seccomp filter load failed: ", err)
+		}
+		filter, err := seccomp.PrepareSource(src, settings)
 		if err != nil {
 			log.Fatal("[FATAL] Seccomp filter compile failed: ", err)
 		}
@@ -178,7 +186,11 @@ func Main() {
 		if enforce == false {
 			settings.DefaultPositiveAction = "trace"
 		}
-		filter, err := seccomp.Prepare(p.Seccomp.Blacklist, settings)
+		src, err := loadPolicySource(p.Seccomp.Blacklist, p, true)
+		if err != nil {
+			log.Fatal("[FATAL] Seccomp blacklist filter load failed: ", err)
+		}
+		filter, err := seccomp.PrepareSource(src, settings)
 		if err != nil {
 			log.Fatal("[FATAL] Seccomp blacklist filter compile failed: ", err)
 		}
@@ -195,7 +207,6 @@ func Main() {
 		log.Fatal("Invalid mode specified (must be whitelist, blacklist, or train)")
 	}
 
-
 }
 
 func loadProfile(dir, name string) (*oz.Profile, error) {
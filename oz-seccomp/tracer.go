@@ -727,6 +727,10 @@ func Tracer() {
 			Name:  "allow-new-privs, N",
 			Usage: "Allow traced program to set new seccomp filters",
 		},
+		cli.IntFlag{
+			Name:  "violations-fd",
+			Usage: "Descriptor on which each seccomp hit is reported as a <pid> <syscall number> <syscall name> line",
+		},
         }
 
 	app.Run(os.Args)
@@ -825,6 +829,13 @@ func TMain(ctx *cli.Context) {
 		cmdArgs = ctx.Args()[1:]
 	}
 
+	var violations *os.File
+	if fd := ctx.Int("violations-fd"); fd > 0 {
+		// Reporting is left to the tracer, the traced program must not inherit it
+		syscall.CloseOnExec(fd)
+		violations = os.NewFile(uintptr(fd), "violations")
+	}
+
 	var cpid = 0
 	done := false
 
@@ -954,6 +965,9 @@ func TMain(ctx *cli.Context) {
 					log.Error("Error: %v", err)
 					continue
 				}
+				if violations != nil {
+					fmt.Fprintf(violations, "%d %d %s\n", pid, systemcall.num, systemcall.name)
+				}
 
 				/* Render the system call invocation */
 
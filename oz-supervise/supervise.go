@@ -0,0 +1,282 @@
+// Package ozsupervise implements oz-supervise, a supervisor started inside
+// the sandbox as the sandbox user which launches the application and restarts
+// it when it crashes. With -exec it only sets the resource limits of the
+// application and executes it in place.
+package ozsupervise
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/subgraph/oz"
+)
+
+const PR_SET_CHILD_SUBREAPER = 36
+
+// Signals relayed to the application, a termination signal also ends the
+// supervision so the application is not restarted during a shutdown.
+var relayedSignals = []os.Signal{
+	syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT,
+	syscall.SIGUSR1, syscall.SIGUSR2,
+}
+
+func isTermination(sig os.Signal) bool {
+	return sig == syscall.SIGTERM || sig == syscall.SIGINT || sig == syscall.SIGQUIT
+}
+
+type supervisor struct {
+	args        []string
+	argv0       string
+	stdin       []byte
+	replayStdin bool
+	stopping    bool
+	pid         int
+}
+
+func report(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "oz-supervise: "+format+"\n", args...)
+}
+
+// rlimitFlags collects the -rlimit arguments
+type rlimitFlags []string
+
+func (r *rlimitFlags) String() string { return strings.Join(*r, ",") }
+
+func (r *rlimitFlags) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+func Main() {
+	maxRestarts := flag.Int("max-restarts", 5, "maximum number of restarts after a crash")
+	delay := flag.Duration("delay", time.Second, "delay before restarting the application")
+	replay := flag.Bool("replay-stdin", false, "read stdin once and pass it again to every restart")
+	argv0 := flag.String("argv0", "", "argv[0] given to the application, its path if empty")
+	execOnly := flag.Bool("exec", false, "execute the command in place of the supervisor rather than supervising it")
+	var rlimits rlimitFlags
+	flag.Var(&rlimits, "rlimit", "resource limit set before the command is executed, as name=soft:hard, may be repeated")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		report("must specify a command to supervise")
+		os.Exit(1)
+	}
+	if err := setRlimits(rlimits); err != nil {
+		report("%v", err)
+		os.Exit(1)
+	}
+	if *execOnly {
+		report("failed to execute %s: %v", flag.Arg(0), execCommand(flag.Args(), *argv0))
+		os.Exit(1)
+	}
+	s := &supervisor{args: flag.Args(), argv0: *argv0, replayStdin: *replay}
+	if s.replayStdin {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			report("unable to read stdin: %v", err)
+			os.Exit(1)
+		}
+		s.stdin = data
+	}
+	// Descendants of a double-forking application are reparented to the
+	// supervisor, which keeps the application running until they all exit
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_SET_CHILD_SUBREAPER, 1, 0); errno != 0 {
+		report("unable to become a child subreaper: %v", errno)
+	}
+
+	sigs := make(chan os.Signal, len(relayedSignals))
+	signal.Notify(sigs, relayedSignals...)
+	os.Exit(s.run(sigs, *maxRestarts, *delay))
+}
+
+// setRlimits sets the limits on the supervisor, they are inherited by the
+// command it executes or supervises
+func setRlimits(rlimits []string) error {
+	for _, r := range rlimits {
+		resource, l, err := oz.ParseRlimit(r)
+		if err != nil {
+			return err
+		}
+		if err := syscall.Setrlimit(resource, &l); err != nil {
+			return fmt.Errorf("unable to set limit %s: %v", r, err)
+		}
+	}
+	return nil
+}
+
+// execCommand replaces the supervisor with the command, it only returns on
+// failure
+func execCommand(args []string, argv0 string) error {
+	p, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+	argv := append([]string{}, args...)
+	if argv0 != "" {
+		argv[0] = argv0
+	}
+	return syscall.Exec(p, argv, os.Environ())
+}
+
+// run supervises the application until it exits cleanly, the restarts are
+// exhausted or a termination signal is received, and returns its exit status.
+func (s *supervisor) run(sigs chan os.Signal, maxRestarts int, delay time.Duration) int {
+	for restarts := 0; ; restarts++ {
+		status, err := s.runOnce(sigs)
+		if err != nil {
+			report("failed to start %s: %v", s.args[0], err)
+			return 1
+		}
+		switch {
+		case s.stopping:
+			report("%s stopped (%s)", s.args[0], describeStatus(status))
+			return exitCode(status)
+		case status.Exited() && status.ExitStatus() == 0:
+			report("%s exited", s.args[0])
+			return 0
+		case restarts >= maxRestarts:
+			report("%s crashed (%s), giving up after %d restarts", s.args[0], describeStatus(status), restarts)
+			return exitCode(status)
+		}
+		report("%s crashed (%s), restarting (%d/%d)", s.args[0], describeStatus(status), restarts+1, maxRestarts)
+		if sig := waitRestartDelay(sigs, delay); sig != nil {
+			report("%s not restarted after signal (%v)", s.args[0], sig)
+			return exitCode(status)
+		}
+	}
+}
+
+// waitRestartDelay waits for delay before a restart and returns early with
+// the termination signal received meanwhile, other signals have no process to
+// be relayed to and are dropped.
+func waitRestartDelay(sigs chan os.Signal, delay time.Duration) os.Signal {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case sig := <-sigs:
+			if isTermination(sig) {
+				return sig
+			}
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// runOnce starts the application and waits until it and every descendant
+// reparented to the supervisor exited. The status of the application process
+// decides whether it crashed.
+func (s *supervisor) runOnce(sigs chan os.Signal) (syscall.WaitStatus, error) {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	if s.argv0 != "" {
+		cmd.Args[0] = s.argv0
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if s.replayStdin {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return 0, err
+		}
+		go func() {
+			w.Write(s.stdin)
+			w.Close()
+		}()
+		defer r.Close()
+		cmd.Stdin = r
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	s.pid = cmd.Process.Pid
+
+	exited := make(chan syscall.WaitStatus)
+	go reapAll(s.pid, exited)
+	for {
+		select {
+		case sig := <-sigs:
+			if isTermination(sig) {
+				s.stopping = true
+			}
+			s.relay(sig)
+		case status := <-exited:
+			return status, nil
+		}
+	}
+}
+
+// reapAll waits for every child of the supervisor and sends the status of
+// pid once none is left. exec.Cmd.Wait is not used as the reparented
+// descendants must also be reaped.
+func reapAll(pid int, exited chan<- syscall.WaitStatus) {
+	var status syscall.WaitStatus
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			exited <- status
+			return
+		}
+		if wpid == pid {
+			status = ws
+		}
+	}
+}
+
+// relay delivers sig to the application and the descendants reparented to
+// the supervisor.
+func (s *supervisor) relay(sig os.Signal) {
+	syscall.Kill(s.pid, sig.(syscall.Signal))
+	for _, pid := range childPids() {
+		if pid != s.pid {
+			syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}
+}
+
+// childPids lists the children of every thread of the supervisor
+func childPids() []int {
+	paths, _ := filepath.Glob("/proc/self/task/*/children")
+	pids := []int{}
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		for _, f := range bytes.Fields(data) {
+			if pid, err := strconv.Atoi(string(f)); err == nil {
+				pids = append(pids, pid)
+			}
+		}
+	}
+	return pids
+}
+
+func describeStatus(ws syscall.WaitStatus) string {
+	if ws.Signaled() {
+		return fmt.Sprintf("signal %v", ws.Signal())
+	}
+	return fmt.Sprintf("status %d", ws.ExitStatus())
+}
+
+func exitCode(ws syscall.WaitStatus) int {
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ws.ExitStatus()
+}
@@ -0,0 +1,110 @@
+package ozsupervise
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunOnceMainStatus(t *testing.T) {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, PR_SET_CHILD_SUBREAPER, 1, 0); errno != 0 {
+		t.Skipf("unable to become a child subreaper: %v", errno)
+	}
+	defer syscall.RawSyscall(syscall.SYS_PRCTL, PR_SET_CHILD_SUBREAPER, 0, 0)
+
+	for _, test := range []struct {
+		script string
+		code   int
+	}{
+		// The descendant exiting last must not decide the status
+		{"(sleep 0.2; exit 0) & exit 3", 3},
+		{"(sleep 0.2; exit 5) & exit 0", 0},
+	} {
+		s := &supervisor{args: []string{"/bin/sh", "-c", test.script}}
+		status, err := s.runOnce(make(chan os.Signal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := exitCode(status); code != test.code {
+			t.Errorf("expecting %q to exit with %d, got %d", test.script, test.code, code)
+		}
+	}
+}
+
+func TestRunRestarts(t *testing.T) {
+	s := &supervisor{args: []string{"/bin/sh", "-c", "exit 4"}}
+	if code := s.run(make(chan os.Signal), 2, time.Millisecond); code != 4 {
+		t.Errorf("expecting the status of the last crash, got %d", code)
+	}
+	s = &supervisor{args: []string{"/bin/true"}}
+	if code := s.run(make(chan os.Signal), 2, time.Millisecond); code != 0 {
+		t.Errorf("expecting a clean exit, got %d", code)
+	}
+}
+
+func TestWaitRestartDelay(t *testing.T) {
+	delay := 100 * time.Millisecond
+	sigs := make(chan os.Signal, 2)
+	sigs <- syscall.SIGHUP
+	start := time.Now()
+	if sig := waitRestartDelay(sigs, delay); sig != nil {
+		t.Errorf("expecting SIGHUP not to cancel the restart, got %v", sig)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("expecting SIGHUP not to skip the restart delay, waited %v", elapsed)
+	}
+
+	sigs <- syscall.SIGTERM
+	start = time.Now()
+	if sig := waitRestartDelay(sigs, time.Minute); sig != syscall.SIGTERM {
+		t.Errorf("expecting SIGTERM to cancel the restart, got %v", sig)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Minute {
+		t.Errorf("expecting SIGTERM to end the delay, waited %v", elapsed)
+	}
+}
+
+func TestSetRlimits(t *testing.T) {
+	var core syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &core); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Setrlimit(syscall.RLIMIT_CORE, &core)
+	if err := setRlimits([]string{fmt.Sprintf("core=0:%d", core.Max)}); err != nil {
+		t.Fatal(err)
+	}
+	var l syscall.Rlimit
+	syscall.Getrlimit(syscall.RLIMIT_CORE, &l)
+	if l.Cur != 0 || l.Max != core.Max {
+		t.Errorf("expecting core limit 0/%d, got %d/%d", core.Max, l.Cur, l.Max)
+	}
+	for _, r := range []string{"unknown=1:1", "core", "core=1", "core=a:1", "core=2:1"} {
+		if err := setRlimits([]string{r}); err == nil {
+			t.Errorf("expecting %s to be rejected", r)
+		}
+	}
+}
+
+func TestExecCommandKeepsRlimits(t *testing.T) {
+	if os.Getenv("OZ_SUPERVISE_TEST_EXEC") == "1" {
+		if err := setRlimits([]string{"core=0:0"}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(execCommand([]string{"sh", "-c", "ulimit -c"}, ""))
+		os.Exit(1)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestExecCommandKeepsRlimits")
+	cmd.Env = append(os.Environ(), "OZ_SUPERVISE_TEST_EXEC=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("expecting the command to be executed: %v\n%s", err, out)
+	}
+	if s := strings.TrimSpace(string(out)); s != "0" {
+		t.Errorf("expecting the executed command to keep the core limit of 0, got %s", s)
+	}
+}
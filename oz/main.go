@@ -124,6 +124,111 @@ func runApplication() {
 			Name:   "shell",
 			Usage:  "start a shell in a running sandbox",
 			Action: handleShell,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "pwd",
+					Usage: "working directory of the shell inside the sandbox",
+				},
+			},
+		},
+		{
+			Name:   "run",
+			Usage:  "run a program in a terminal inside a running sandbox",
+			Action: handleRun,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "no-network",
+				},
+				cli.IntFlag{
+					Name:  "display",
+					Usage: "display of the sandbox the program uses, the main one by default",
+				},
+			},
+		},
+		{
+			Name:   "exec",
+			Usage:  "run a program inside a running sandbox without a terminal and print its output",
+			Action: handleExec,
+		},
+		{
+			Name:   "output",
+			Usage:  "display the output of the applications of a running sandbox",
+			Action: handleOutput,
+		},
+		{
+			Name:   "diskusage",
+			Usage:  "display disk usage of the writable areas of a running sandbox",
+			Action: handleDiskUsage,
+		},
+		{
+			Name:   "trim",
+			Usage:  "ask the kernel to reclaim memory of an idle running sandbox",
+			Action: handleTrim,
+		},
+		{
+			Name:   "debugdump",
+			Usage:  "dump the goroutines and internal state of oz-init in a running sandbox, requires root and enable_debug_dump",
+			Action: handleDebugDump,
+		},
+		{
+			Name:   "status",
+			Usage:  "display the status of a running sandbox as reported by oz-init",
+			Action: handleStatus,
+		},
+		{
+			Name:   "ps",
+			Usage:  "list the processes started by oz-init in a running sandbox",
+			Action: handleListProcesses,
+		},
+		{
+			Name:   "mounts",
+			Usage:  "list the mount points inside a running sandbox",
+			Action: handleListMounts,
+		},
+		{
+			Name:   "killproc",
+			Usage:  "send a signal, SIGTERM by default, to a process started by oz-init in a running sandbox",
+			Action: handleKillProcess,
+		},
+		{
+			Name:   "rlimits",
+			Usage:  "display the resource limits of the processes of a running sandbox",
+			Action: handleRlimits,
+		},
+		{
+			Name:   "setrlimit",
+			Usage:  "change resource limits of the processes of a running sandbox",
+			Action: handleSetRlimit,
+		},
+		{
+			Name:   "debug",
+			Usage:  "attach a debugger to a process in a running sandbox",
+			Action: handleDebug,
+		},
+		{
+			Name:   "setgroups",
+			Usage:  "update the supplementary groups of programs launched afterwards in a running sandbox",
+			Action: handleSetGroups,
+		},
+		{
+			Name:   "denials",
+			Usage:  "display the syscalls recently denied by seccomp in a running sandbox",
+			Action: handleSeccompDenials,
+		},
+		{
+			Name:   "reload-seccomp",
+			Usage:  "reload the seccomp policy of a running sandbox for programs launched afterwards",
+			Action: handleReloadSeccomp,
+		},
+		{
+			Name:   "whitelist",
+			Usage:  "bind a path of the host into a running sandbox",
+			Action: handleAddWhitelist,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name: "readonly, r",
+				},
+			},
 		},
 		{
 			Name:   "mount",
@@ -249,6 +354,11 @@ func handleList(c *cli.Context) {
 		if sb.Ephemeral {
 			ephemeral = " [ephemeral]"
 		}
+		if sb.NotReady {
+			ephemeral += " (not ready)"
+		} else if !sb.Ready {
+			ephemeral += " (starting)"
+		}
 		fmt.Printf("%2d) %s%s\n", sb.Id, sb.Profile, ephemeral)
 	}
 }
@@ -332,7 +442,7 @@ func handleShell(c *cli.Context) {
 	}
 
 	term := os.Getenv("TERM")
-	fd, err := ozinit.RunShell(sb.Address, term)
+	fd, err := ozinit.RunShell(sb.Address, term, c.String("pwd"))
 	if err != nil {
 		fmt.Printf("start shell command failed: %v\n", err)
 		os.Exit(1)
@@ -350,6 +460,522 @@ func handleShell(c *cli.Context) {
 	fmt.Println("done..")
 }
 
+func handleRun(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		fmt.Println("Sandbox id and program arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	pwd, _ := os.Getwd()
+	fd, err := ozinit.RunProgramPty(sb.Address, c.Args()[1], pwd, os.Getenv("TERM"), c.Args()[2:], c.Bool("no-network"), c.Int("display"))
+	if err != nil {
+		fmt.Printf("Run command failed: %v\n", err)
+		os.Exit(1)
+	}
+	st, err := SetRawTerminal(0)
+	HandleResize(fd)
+	f := os.NewFile(uintptr(fd), "")
+	go io.Copy(f, os.Stdin)
+	io.Copy(os.Stdout, f)
+	if err := RestoreTerminal(0, st); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleExec(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		fmt.Println("Sandbox id and program arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	pwd, _ := os.Getwd()
+	code, err := ozinit.Exec(sb.Address, c.Args()[1], pwd, c.Args()[2:], os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Exec command failed: %v\n", err)
+		os.Exit(1)
+	}
+	// Exit like a shell does for a program killed by a signal
+	if code < 0 {
+		code = 128 - code
+	}
+	os.Exit(code)
+}
+
+func handleOutput(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	ofd, efd, err := ozinit.SubscribeOutput(sb.Address)
+	if err != nil {
+		fmt.Printf("Output command failed: %v\n", err)
+		os.Exit(1)
+	}
+	stderr := os.NewFile(uintptr(efd), "stderr")
+	go io.Copy(os.Stderr, stderr)
+	io.Copy(os.Stdout, os.NewFile(uintptr(ofd), "stdout"))
+}
+
+func handleTrim(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	res, err := ozinit.TrimMemory(sb.Address)
+	if err != nil {
+		fmt.Printf("Trim command failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Resident memory: %s before, %s after (%s)\n", humanSize(res.RssBefore), humanSize(res.RssAfter), res.Method)
+}
+
+func handleDebugDump(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	dump, err := ozinit.DebugDump(sb.Address)
+	if err != nil {
+		fmt.Printf("Debug dump command failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("== Processes")
+	for _, p := range dump.Processes {
+		kind := "application"
+		if p.Shell {
+			kind = "shell"
+		} else if !p.Tracked {
+			kind = "untracked"
+		}
+		fmt.Printf("%5d #%-3d %-11s %s\n", p.Pid, p.Seq, kind, strings.Join(p.Args, " "))
+	}
+	fmt.Println("== Recent log")
+	for _, line := range dump.Log {
+		fmt.Println(line)
+	}
+	fmt.Println("== Goroutines")
+	fmt.Print(dump.Goroutines)
+}
+
+func handleDiskUsage(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	mounts, err := ozinit.DiskUsage(sb.Address)
+	if err != nil {
+		fmt.Printf("Disk usage command failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, m := range mounts {
+		pct := uint64(0)
+		if m.Size > 0 {
+			pct = m.Used * 100 / m.Size
+		}
+		fmt.Printf("%-30s %8s used of %8s (%d%%), %8s available\n", m.Path, humanSize(m.Used), humanSize(m.Size), pct, humanSize(m.Avail))
+	}
+}
+
+func handleStatus(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	st, err := ozinit.GetStatus(sb.Address)
+	if err != nil {
+		fmt.Printf("Status command failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Profile:    %s\n", st.Profile)
+	fmt.Printf("Uptime:     %ds\n", int64(st.Uptime.Seconds()))
+	fmt.Printf("Processes:  %d\n", st.Children)
+	fmt.Printf("Xpra ready: %v\n", st.XpraReady)
+	fmt.Printf("Networking: %v\n", st.Networking)
+}
+
+func handleListProcesses(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	procs, err := ozinit.ListProcesses(sb.Address)
+	if err != nil {
+		fmt.Printf("Ps command failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range procs {
+		fmt.Printf("%7d  %s\n", p.Pid, strings.Join(p.Args, " "))
+	}
+}
+
+func handleListMounts(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	mounts, err := ozinit.ListMounts(sb.Address)
+	if err != nil {
+		fmt.Printf("Mounts command failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, m := range mounts {
+		fmt.Printf("%-30s %-10s %-30s %s\n", m.Target, m.FSType, m.Source, m.Options)
+	}
+}
+
+func handleKillProcess(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		fmt.Println("Sandbox id and pid arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	pid, err := strconv.Atoi(c.Args()[1])
+	if err != nil {
+		fmt.Println("Pid argument must be an integer")
+		os.Exit(1)
+	}
+	sig := 0
+	if len(c.Args()) > 2 {
+		if sig, err = strconv.Atoi(c.Args()[2]); err != nil {
+			fmt.Println("Signal argument must be an integer")
+			os.Exit(1)
+		}
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	if err := ozinit.KillProcess(sb.Address, pid, sig); err != nil {
+		fmt.Printf("Killproc command failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleRlimits(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Println("Sandbox id argument needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	pid := 0
+	if len(c.Args()) > 1 {
+		if pid, err = strconv.Atoi(c.Args()[1]); err != nil {
+			fmt.Println("Pid argument must be an integer")
+			os.Exit(1)
+		}
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	procs, err := ozinit.GetRlimits(sb.Address, pid)
+	if err != nil {
+		fmt.Printf("Rlimits command failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range procs {
+		fmt.Printf("pid %d\n", p.Pid)
+		for _, l := range p.Limits {
+			fmt.Printf("  %-12s %-16s %s\n", l.Resource, rlimitString(l.Soft), rlimitString(l.Hard))
+		}
+	}
+}
+
+func rlimitString(v uint64) string {
+	if v == ozinit.RLIM_INFINITY {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func parseRlimitValue(s string) (uint64, error) {
+	if s == "unlimited" {
+		return ozinit.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// parseRlimit parses resource=soft[:hard], the hard limit defaults to soft
+func parseRlimit(arg string) (ozinit.Rlimit, error) {
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 {
+		return ozinit.Rlimit{}, fmt.Errorf("invalid limit (%s), expecting resource=soft[:hard]", arg)
+	}
+	vals := strings.SplitN(kv[1], ":", 2)
+	soft, err := parseRlimitValue(vals[0])
+	if err != nil {
+		return ozinit.Rlimit{}, fmt.Errorf("invalid soft limit in (%s)", arg)
+	}
+	hard := soft
+	if len(vals) == 2 {
+		if hard, err = parseRlimitValue(vals[1]); err != nil {
+			return ozinit.Rlimit{}, fmt.Errorf("invalid hard limit in (%s)", arg)
+		}
+	}
+	return ozinit.Rlimit{Resource: kv[0], Soft: soft, Hard: hard}, nil
+}
+
+func handleSetRlimit(c *cli.Context) {
+	if len(c.Args()) < 3 {
+		fmt.Println("Sandbox id, pid (0 for all processes) and limit arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	pid, err := strconv.Atoi(c.Args()[1])
+	if err != nil {
+		fmt.Println("Pid argument must be an integer")
+		os.Exit(1)
+	}
+	limits := []ozinit.Rlimit{}
+	for _, arg := range c.Args()[2:] {
+		l, err := parseRlimit(arg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		limits = append(limits, l)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	results, err := ozinit.SetRlimits(sb.Address, pid, limits)
+	if err != nil {
+		fmt.Printf("Setrlimit command failed: %v\n", err)
+		os.Exit(1)
+	}
+	failed := false
+	for _, r := range results {
+		if r.Error != "" {
+			failed = true
+			fmt.Printf("pid %d: %s rejected: %s\n", r.Pid, r.Resource, r.Error)
+		} else {
+			fmt.Printf("pid %d: %s changed\n", r.Pid, r.Resource)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func humanSize(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func handleDebug(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		fmt.Println("Sandbox id and pid arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	pid, err := strconv.Atoi(c.Args()[1])
+	if err != nil {
+		fmt.Println("Pid argument must be an integer")
+		os.Exit(1)
+	}
+
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+
+	chanb := make(chan bool, 1)
+	go promptConfirmShell(chanb, sb.Profile, id)
+	prompt := <-chanb
+	if !prompt {
+		fmt.Printf("Denied debugger execution... \n")
+		os.Exit(0)
+	}
+
+	term := os.Getenv("TERM")
+	fd, err := ozinit.RunDebugger(sb.Address, pid, term)
+	if err != nil {
+		fmt.Printf("start debugger command failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Attaching debugger to pid %d in `%s`\n\n", pid, sb.Profile)
+	st, err := SetRawTerminal(0)
+	HandleResize(fd)
+	f := os.NewFile(uintptr(fd), "")
+	go io.Copy(f, os.Stdin)
+	io.Copy(os.Stdout, f)
+	if err := RestoreTerminal(0, st); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("done..")
+}
+
 func getSandboxById(id int) (*daemon.SandboxInfo, error) {
 	sboxes, err := daemon.ListSandboxes()
 	if err != nil {
@@ -429,6 +1055,92 @@ func handleRelaunchXpraClient(c *cli.Context) {
 	}
 }
 
+func handleSetGroups(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "Need a sandbox id to set the groups\n")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not parse id value %s\n", c.Args()[0])
+		os.Exit(1)
+	}
+	if err := daemon.SetGroups(id, c.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Setgroups command failed: %s.\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleSeccompDenials(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "Need a sandbox id to display seccomp denials\n")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not parse id value %s\n", c.Args()[0])
+		os.Exit(1)
+	}
+	denials, err := daemon.GetSeccompDenials(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Denials command failed: %s.\n", err)
+		os.Exit(1)
+	}
+	for _, d := range denials {
+		name := d.SyscallName
+		if name == "" {
+			name = strconv.Itoa(d.Syscall)
+		}
+		fmt.Printf("%s  pid %-7d %-16s %-20s %s\n", d.Time.Format("15:04:05"), d.Pid, d.Comm, name, d.Exe)
+	}
+}
+
+func handleReloadSeccomp(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		fmt.Fprintf(os.Stderr, "Need a sandbox id to reload the seccomp policy\n")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not parse id value %s\n", c.Args()[0])
+		os.Exit(1)
+	}
+	if err := daemon.ReloadSeccomp(id); err != nil {
+		fmt.Fprintf(os.Stderr, "Reload seccomp command failed: %s.\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleAddWhitelist(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		fmt.Println("Sandbox id and path arguments needed")
+		os.Exit(1)
+	}
+	id, err := strconv.Atoi(c.Args()[0])
+	if err != nil {
+		fmt.Println("Sandbox id argument must be an integer")
+		os.Exit(1)
+	}
+	fpath, err := filepath.Abs(c.Args()[1])
+	if err != nil {
+		fmt.Printf("Unable to resolve path %s: %v\n", c.Args()[1], err)
+		os.Exit(1)
+	}
+	sb, err := getSandboxById(id)
+	if err != nil {
+		fmt.Printf("Error retrieving sandbox list: %v\n", err)
+		os.Exit(1)
+	}
+	if sb == nil {
+		fmt.Printf("No sandbox found with id = %d\n", id)
+		os.Exit(1)
+	}
+	if err := ozinit.AddWhitelist(sb.Address, fpath, c.Bool("readonly")); err != nil {
+		fmt.Printf("Whitelist command failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func handleForward(c *cli.Context) {
 	var out string
 	var err error
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"regexp"
@@ -20,16 +21,29 @@ type Profile struct {
 	Path string
 	// List of path to binaries matching this sandbox
 	Paths []string
+	// Other binaries the sandbox may run on request, in addition to path and paths
+	AllowedPaths []string `json:"allowed_paths"`
 	// Path of the config file
 	ProfilePath string `json:"-"`
 	// Default parameters to pass to the program
 	DefaultParams []string `json:"default_params"`
+	// argv[0] given to the program instead of its path, for multi-call binaries
+	Argv0 string `json:"argv0"`
 	// Pass command-line arguments
 	RejectUserArgs bool `json:"reject_user_args"`
+	// Arguments always passed to the program, even when user arguments are rejected
+	FixedArgs []string `json:"fixed_args"`
+	// Where the fixed arguments are added, one of (before, after), defaults to before
+	FixedArgsPosition string `json:"fixed_args_position"`
 	// Autoshutdown the sandbox when the process exits. One of (no, yes, soft), defaults to yes
 	AutoShutdown ShutdownMode `json:"auto_shutdown"`
 	// Optional list of executable names to watch for exit in case initial command spawns and exit
 	Watchdog []string
+	// Launch the application through oz-supervise, which runs as the sandbox
+	// user and restarts the application when it crashes
+	UseInSandboxSupervisor bool `json:"use_in_sandbox_supervisor"`
+	// Restarts allowed by the supervisor before giving up, defaults to 5
+	SupervisorMaxRestarts int `json:"supervisor_max_restarts"`
 	// Optional wrapper binary to use when launching command (ex: tsocks)
 	Wrapper string
 	// If true launch one sandbox per instance, otherwise run all instances in same sandbox
@@ -45,24 +59,123 @@ type Profile struct {
 	AllowedGroups []string `json:"allowed_groups"`
 	// Optional directory where per-process logs will be output
 	LogDir string `json:"log_dir"`
+	// Write the context of an application killed by a signal (status, command
+	// line, environment and recent output) to a file in log_dir
+	CaptureCrashDiagnostics bool `json:"capture_crash_diagnostics"`
 	// List of paths to bind mount inside jail
 	Whitelist []WhitelistItem
 	// List of paths to blacklist inside jail
 	Blacklist []BlacklistItem
 	// Shared Folders
 	SharedFolders []string `json:"shared_folders"`
+	// Application names whose ~/.config, ~/.local/share and ~/.cache
+	// subdirectories are bound read-write inside the jail
+	AppDataDirs []string `json:"app_data_dirs"`
+	// Bind the XDG Downloads directory of the user read-write inside the jail
+	ShareDownloads bool `json:"share_downloads"`
 	// Optional XServer config
 	XServer XServerConf
-	// List of environment variables
+	// List of environment variables, those given a value are set for the
+	// programs and shells of the sandbox, replacing any inherited variable
 	Environment []EnvVar
+	// Names of the environment_vars of the config copied from the launch
+	// environment, shell globs are accepted, all are copied when empty
+	EnvWhitelist []string `json:"env_whitelist"`
 	// Networking
 	Networking NetworkProfile
 	// Firewall
 	Firewall []FWRule
 	// Seccomp
 	Seccomp SeccompConf
+	// Cgroup of the sandbox
+	Cgroup CgroupConf
+	// Resource limits of the applications of the sandbox
+	Limits LimitsConf
+	// Soft limit of open file descriptors of the applications, defaults to
+	// 65536 with an X server and to the limit inherited from oz-init otherwise
+	MaxOpenFiles uint64 `json:"max_open_files"`
+	// Resource limits (ie: {"nofile": 1024}) set as both the soft and hard
+	// limit of the applications, resources not listed keep the inherited limit
+	Rlimits map[string]uint64 `json:"rlimits"`
+	// Start the processes of the sandbox user without any supplementary group
+	NoSupplementaryGroups bool `json:"no_supplementary_groups"`
+	// Set no_new_privs on the applications and shells so setuid binaries and
+	// file capabilities cannot raise their privileges, defaults to true when
+	// seccomp is enabled and no file capabilities are given
+	NoNewPrivs *bool `json:"no_new_privs"`
 	// External Forwarders
 	ExternalForwarders []ExternalForwarder `json:"external_forwarders"`
+	// Relay the raw application output to a client subscribed with `oz output`
+	// instead of logging it
+	StreamOutput bool `json:"stream_output"`
+	// Append the output of each program to <name>-<pid>.log in log_dir instead
+	// of logging it
+	CaptureOutput bool `json:"capture_output"`
+	// Mount every writable location (whitelist, /tmp, /dev/shm, home) noexec
+	NoExecWritable bool `json:"no_exec_writable"`
+	// Mount the root of the sandbox read-only, only the writable whitelist
+	// items and the tmpfs mounts can be written to
+	ReadOnlyRoot bool `json:"read_only_root"`
+	// Keep ptrace available to the sandbox so a debugger can be attached.
+	// This is a development option which reduces isolation.
+	AllowPtrace bool `json:"allow_ptrace"`
+	// Create /dev/fuse and let fusermount mount FUSE filesystems inside the
+	// sandbox. This reduces isolation as sandboxed processes can mount.
+	AllowFuse bool `json:"allow_fuse"`
+	// Allow programs run in the sandbox to request their own empty network
+	// namespace, holding only a loopback interface
+	AllowNetIsolation bool `json:"allow_net_isolation"`
+	// Application runs in a terminal, TERM defaults to xterm-256color when
+	// the launcher does not provide one
+	Terminal bool `json:"terminal"`
+	// Scheduling policy of the application, one of (normal, batch, idle), defaults to normal
+	SchedPolicy string `json:"sched_policy"`
+	// Landlock path rules restricting the filesystem access of the application
+	// inside the sandbox, everything not listed is denied
+	Landlock []LandlockRule `json:"landlock"`
+	// Fully initialize the sandbox and keep it idle until a program is run
+	// Used with `oz launch --noexec` to have sandboxes ready in advance
+	PreWarm bool `json:"pre_warm"`
+	// Regular expression matched against the application output, when set
+	// the sandbox is only marked ready once a line of output matches
+	ReadyPattern string `json:"ready_pattern"`
+	// Seconds to wait for the ready pattern to match, defaults to 30
+	ReadyTimeout int `json:"ready_timeout"`
+	// Command run as the sandbox user once the sandbox is set up, the sandbox
+	// is only signalled ready once it exits with status 0
+	ReadyProbe []string `json:"ready_probe"`
+	// Program run as the sandbox user once the filesystem is set up, before
+	// the sandbox is signalled ready, init is aborted unless it exits with status 0
+	PostSetupHook string `json:"post_setup_hook"`
+	// Seconds to keep polling the ready probe, defaults to 30
+	ReadyProbeTimeout int `json:"ready_probe_timeout"`
+	// Stage of the setup at which oz-init signals the daemon it is ready,
+	// one of (mounts, xpra), defaults to xpra
+	ReadyWhen string `json:"ready_when"`
+	// Order in which the sandbox is stopped on shutdown, one of
+	// (children-first, xpra-first, reverse-launch-order), defaults to children-first
+	ShutdownOrder string `json:"shutdown_order"`
+	// Seconds after the last process of the sandbox exited before it shuts
+	// down unless a program is run, 0 to keep the sandbox running
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+	// File capabilities to give to binaries inside the sandbox (ie: {"/bin/ping": ["cap_net_raw"]})
+	FileCapabilities map[string][]string `json:"file_capabilities"`
+	// Capabilities (ie: cap_sys_admin) dropped from the bounding, ambient and
+	// inheritable sets of the applications, all of them when empty
+	DropCapabilities []string `json:"drop_capabilities"`
+	// Capabilities never dropped from the applications, the file capabilities
+	// of the profile are always kept
+	KeepCapabilities []string `json:"keep_capabilities"`
+	// Libraries loaded into the applications with LD_PRELOAD, absolute paths
+	// inside the sandbox
+	Preload []string `json:"preload"`
+	// Mix fresh host entropy into /dev/urandom before any application starts
+	SeedEntropy bool `json:"seed_entropy"`
+	// Namespaced kernel tunables (ie: kernel.shmmax) to set inside the sandbox
+	KernelTunables map[string]string `json:"kernel_tunables"`
+	// Size limit in megabytes of the tmpfs mounted on /tmp and /dev/shm, 0 keeps
+	// the kernel default of half of the memory
+	TmpfsSizeMB int `json:"tmpfs_size_mb"`
 }
 
 type ShutdownMode string
@@ -73,6 +186,39 @@ const (
 	//PROFILE_SHUTDOWN_SOFT     ShutdownMode = "soft" // Unimplemented
 )
 
+type XpraMode string
+
+const (
+	PROFILE_XPRA_SEAMLESS XpraMode = "seamless"
+	PROFILE_XPRA_DESKTOP  XpraMode = "desktop"
+)
+
+type ClipboardMode string
+
+const (
+	PROFILE_CLIPBOARD_NONE         ClipboardMode = "none"
+	PROFILE_CLIPBOARD_TO_SANDBOX   ClipboardMode = "to-sandbox"
+	PROFILE_CLIPBOARD_FROM_SANDBOX ClipboardMode = "from-sandbox"
+	PROFILE_CLIPBOARD_BOTH         ClipboardMode = "both"
+)
+
+// Encodings allowed in XServerConf.Encoding, anything else could inject arguments to xpra
+var xpraEncodings = map[string]bool{
+	"auto":  true,
+	"rgb":   true,
+	"rgb24": true,
+	"rgb32": true,
+	"png":   true,
+	"png/L": true,
+	"png/P": true,
+	"jpeg":  true,
+	"webp":  true,
+	"h264":  true,
+	"h265":  true,
+	"vp8":   true,
+	"vp9":   true,
+}
+
 type AudioMode string
 
 const (
@@ -93,6 +239,33 @@ type XServerConf struct {
 	PulseAudio          bool      `json:"pulseaudio"`
 	Border              bool      `json:"border"`
 	Environment         []EnvVar  `json:"env"`
+	// Shut down the sandbox when the last xpra client disconnects
+	ExitOnDisconnect bool `json:"exit_on_disconnect"`
+	// Show each application window seamlessly on the host or the whole
+	// display in a single nested window, seamless when empty
+	Mode XpraMode `json:"mode"`
+	// Number of xpra displays started besides the main one, programs are
+	// shown on the main display unless another one is requested
+	ExtraDisplays int `json:"extra_displays"`
+	// Direction of the clipboard sharing, both directions when empty
+	Clipboard ClipboardMode `json:"clipboard"`
+	// Picture encoding of the display, rgb when empty
+	Encoding string `json:"encoding"`
+	// Picture quality of the lossy encodings from 1 to 100, chosen by xpra when 0
+	Quality int `json:"quality"`
+}
+
+type CgroupConf struct {
+	// Cpuset list (ex: 0-1,4) of the cores the sandbox is pinned to
+	Cpus string `json:"cpus"`
+}
+
+type LimitsConf struct {
+	// Memory available to the applications of the sandbox in MiB, unlimited when 0
+	MemoryMB uint64 `json:"memory_mb"`
+	// Cpu time available to the applications of the sandbox in percent of a
+	// single cpu (ie: 200 for two cpus), unlimited when 0
+	CPUPercent uint64 `json:"cpu_percent"`
 }
 
 type SeccompMode string
@@ -113,6 +286,46 @@ type SeccompConf struct {
 	Whitelist   string
 	Blacklist   string
 	ExtraDefs   []string
+	// Restrict personality so ASLR cannot be disabled, defaults to true in
+	// whitelist mode. Disable it for legacy binaries changing personality.
+	LockPersonality *bool `json:"lock_personality"`
+}
+
+// PersonalityLocked returns whether the personality syscall is restricted
+func (c SeccompConf) PersonalityLocked() bool {
+	if c.LockPersonality != nil {
+		return *c.LockPersonality
+	}
+	return c.Mode == PROFILE_SECCOMP_WHITELIST
+}
+
+// NoNewPrivsEnabled returns whether processes of the sandbox are started with no_new_privs
+func (p *Profile) NoNewPrivsEnabled() bool {
+	if p.NoNewPrivs != nil {
+		return *p.NoNewPrivs
+	}
+	return p.Seccomp.Mode != "" && p.Seccomp.Mode != PROFILE_SECCOMP_DISABLED && !p.grantsFileCapabilities()
+}
+
+// grantsFileCapabilities returns whether binaries get file capabilities, which
+// no_new_privs ignores: those of the profile, or the one oz-init gives to
+// fusermount with allow_fuse.
+func (p *Profile) grantsFileCapabilities() bool {
+	return len(p.FileCapabilities) > 0 || p.AllowFuse
+}
+
+// EnvWhitelisted returns whether the variable of the launch environment is
+// passed to the sandbox by env_whitelist
+func (p *Profile) EnvWhitelisted(name string) bool {
+	if len(p.EnvWhitelist) == 0 {
+		return true
+	}
+	for _, pattern := range p.EnvWhitelist {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
 }
 
 type VPNConf struct {
@@ -144,6 +357,19 @@ type WhitelistItem struct {
 	Force       bool
 	NoFollow    bool `json:"no_follow"`
 	AllowSetuid bool `json:"allow_suid"`
+	// Carry the noexec, nosuid and nodev options of the source mount over to the bind
+	PreserveMountFlags bool `json:"preserve_mount_flags"`
+	// Copy the extended attributes (ie: SELinux labels) of the source onto
+	// the parent directories created in the sandbox for the bind
+	PreserveXattrs bool `json:"preserve_xattrs"`
+	// Bind the mounts below the path along with it
+	Recursive bool `json:"recursive"`
+}
+
+type LandlockRule struct {
+	Path string
+	// Any of read, write and execute
+	Access []string
 }
 
 type BlacklistItem struct {
@@ -189,12 +415,38 @@ type NetworkProfile struct {
 	//  Applies to Nettype: bridge only
 	IpByte uint `json:"ip_byte"`
 
+	// Hardware address of the sandbox interface, random when empty
+	//  Applies to Nettype: bridge only
+	MacAddr string `json:"mac_addr"`
+
+	// Also give the sandbox an IPv6 address reaching its bridge only, skipped
+	// when the host lacks IPv6
+	//  Applies to Nettype: bridge only
+	LocalIPv6 bool `json:"local_ipv6"`
+
+	// Bandwidth of the sandbox in kilobits per second in each direction, unlimited when 0
+	//  Applies to Nettype: bridge only
+	RateLimitKbps int `json:"rate_limit_kbps"`
+
 	// DNS Mode one of: pass, none, dhcp
 	//  Applies to Nettype: bridge only
 	DNSMode DNSMode `json:"dns_mode"`
 
 	// Additional data for the hosts file
 	Hosts string
+
+	// Search domains written to the resolv.conf of the sandbox, the search
+	// domains of the host are kept when empty
+	SearchDomains []string `json:"search_domains"`
+
+	// Name servers written to the resolv.conf of the sandbox, the name
+	// servers of the host are kept when empty
+	//  Applies to Nettype: bridge and empty only
+	DNS []string `json:"dns"`
+
+	// Ports the sandbox can reach over tcp and udp, all ports are allowed when empty
+	//  Applies to Nettype: bridge and empty only
+	AllowedPorts []int `json:"allowed_ports"`
 }
 
 const defaultProfileDirectory = "/var/lib/oz/cells.d"
@@ -311,12 +563,208 @@ func loadProfileFile(fpath string) (*Profile, error) {
 	if p.XServer.AudioMode == "" {
 		p.XServer.AudioMode = PROFILE_AUDIO_NONE
 	}
+	switch p.XServer.Mode {
+	case "", PROFILE_XPRA_SEAMLESS, PROFILE_XPRA_DESKTOP:
+	default:
+		return nil, fmt.Errorf("invalid xserver mode (%s), must be one of seamless, desktop", p.XServer.Mode)
+	}
+	switch p.XServer.Clipboard {
+	case "", PROFILE_CLIPBOARD_NONE, PROFILE_CLIPBOARD_TO_SANDBOX, PROFILE_CLIPBOARD_FROM_SANDBOX, PROFILE_CLIPBOARD_BOTH:
+	default:
+		return nil, fmt.Errorf("invalid clipboard (%s), must be one of none, to-sandbox, from-sandbox, both", p.XServer.Clipboard)
+	}
+	if p.XServer.Encoding != "" && !xpraEncodings[p.XServer.Encoding] {
+		return nil, fmt.Errorf("invalid encoding (%s), must be one of auto, rgb, rgb24, rgb32, png, png/L, png/P, jpeg, webp, h264, h265, vp8, vp9", p.XServer.Encoding)
+	}
+	if p.XServer.ExtraDisplays < 0 {
+		return nil, fmt.Errorf("extra_displays must not be negative")
+	}
+	if p.XServer.Quality < 0 || p.XServer.Quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100")
+	}
 	if p.Seccomp.Mode == "" {
 		p.Seccomp.Mode = PROFILE_SECCOMP_DISABLED
 	}
+	switch p.FixedArgsPosition {
+	case "", "before", "after":
+	default:
+		return nil, fmt.Errorf("invalid fixed_args_position (%s), must be one of before, after", p.FixedArgsPosition)
+	}
+	switch p.SchedPolicy {
+	case "", "normal", "batch", "idle":
+	default:
+		return nil, fmt.Errorf("invalid sched_policy (%s), must be one of normal, batch, idle", p.SchedPolicy)
+	}
+	for _, app := range p.AppDataDirs {
+		if app == "" || app == "." || app == ".." || strings.Contains(app, "/") {
+			return nil, fmt.Errorf("invalid app_data_dirs entry (%s), must be a single directory name", app)
+		}
+	}
+	for _, r := range p.Landlock {
+		if r.Path == "" {
+			return nil, fmt.Errorf("landlock rule is missing a path")
+		}
+		for _, a := range r.Access {
+			switch a {
+			case "read", "write", "execute":
+			default:
+				return nil, fmt.Errorf("invalid landlock access (%s) for %s, must be one of read, write, execute", a, r.Path)
+			}
+		}
+	}
+	for _, lib := range p.Preload {
+		if !path.IsAbs(lib) || strings.ContainsAny(lib, ": ") {
+			return nil, fmt.Errorf("invalid preload library (%s), must be an absolute path without spaces or colons", lib)
+		}
+	}
+	if p.SupervisorMaxRestarts < 0 {
+		return nil, fmt.Errorf("supervisor_max_restarts cannot be negative")
+	}
+	if p.Cgroup.Cpus != "" {
+		if _, err := ParseCpuList(p.Cgroup.Cpus); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range p.Networking.SearchDomains {
+		if !isValidSearchDomain(d) {
+			return nil, fmt.Errorf("invalid search domain (%s)", d)
+		}
+	}
+	for _, ns := range p.Networking.DNS {
+		if net.ParseIP(ns) == nil {
+			return nil, fmt.Errorf("invalid dns server address (%s)", ns)
+		}
+	}
+	if len(p.Networking.DNS) > 0 && p.Networking.Nettype == network.TYPE_HOST {
+		return nil, fmt.Errorf("dns servers cannot be set with the host network type")
+	}
+	if p.Networking.MacAddr != "" {
+		if p.Networking.Nettype != network.TYPE_BRIDGE {
+			return nil, fmt.Errorf("mac_addr requires a network type of bridge")
+		}
+		if p.Multi {
+			// Every sandbox of the profile would share the address on the bridge
+			return nil, fmt.Errorf("mac_addr cannot be used with multi")
+		}
+		if _, err := network.ParseMacAddr(p.Networking.MacAddr); err != nil {
+			return nil, fmt.Errorf("mac_addr: %v", err)
+		}
+	}
+	if p.Networking.LocalIPv6 && p.Networking.Nettype != network.TYPE_BRIDGE {
+		return nil, fmt.Errorf("local_ipv6 requires a network type of bridge")
+	}
+	if p.Networking.LocalIPv6 && len(p.Firewall) > 0 {
+		// The firewall rules only match the IPv4 address of the sandbox
+		return nil, fmt.Errorf("local_ipv6 cannot be used with firewall rules")
+	}
+	if p.Networking.RateLimitKbps < 0 {
+		return nil, fmt.Errorf("rate_limit_kbps must not be negative")
+	}
+	if p.Networking.RateLimitKbps > 0 && p.Networking.Nettype != network.TYPE_BRIDGE {
+		return nil, fmt.Errorf("rate_limit_kbps requires a network type of bridge")
+	}
+	for _, port := range p.Networking.AllowedPorts {
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid allowed port (%d)", port)
+		}
+	}
+	if len(p.Networking.AllowedPorts) > 0 && p.Networking.Nettype == network.TYPE_HOST {
+		return nil, fmt.Errorf("allowed_ports cannot be set with the host network type")
+	}
+	if p.Argv0 != "" && !path.IsAbs(p.Path) {
+		return nil, fmt.Errorf("argv0 requires an absolute path to the program")
+	}
+	if len(p.ReadyProbe) > 0 && !path.IsAbs(p.ReadyProbe[0]) {
+		return nil, fmt.Errorf("ready_probe command (%s) must be an absolute path", p.ReadyProbe[0])
+	}
+	if p.PostSetupHook != "" && !path.IsAbs(p.PostSetupHook) {
+		return nil, fmt.Errorf("post_setup_hook (%s) must be an absolute path", p.PostSetupHook)
+	}
+	if p.ReadyProbeTimeout < 0 {
+		return nil, fmt.Errorf("ready_probe_timeout must not be negative")
+	}
+	if p.NoNewPrivs != nil && *p.NoNewPrivs {
+		if len(p.FileCapabilities) > 0 {
+			return nil, fmt.Errorf("file_capabilities cannot be granted with no_new_privs")
+		}
+		if p.AllowFuse {
+			return nil, fmt.Errorf("allow_fuse cannot be used with no_new_privs, fusermount needs its file capabilities")
+		}
+	}
+	if p.TmpfsSizeMB < 0 {
+		return nil, fmt.Errorf("tmpfs_size_mb must not be negative")
+	}
+	if p.IdleTimeoutSeconds < 0 {
+		return nil, fmt.Errorf("idle_timeout_seconds must not be negative")
+	}
+	for _, pattern := range p.EnvWhitelist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid env_whitelist pattern (%s): %v", pattern, err)
+		}
+	}
+	for _, v := range append(append([]EnvVar{}, p.Environment...), p.XServer.Environment...) {
+		if strings.ContainsAny(v.Name, "=\x00") {
+			return nil, fmt.Errorf("invalid environment variable name (%s)", v.Name)
+		}
+	}
+	if p.CaptureOutput && !path.IsAbs(p.LogDir) {
+		return nil, fmt.Errorf("capture_output requires an absolute log_dir")
+	}
+	if p.CaptureOutput && p.StreamOutput {
+		return nil, fmt.Errorf("capture_output cannot be used with stream_output")
+	}
+	if p.CaptureCrashDiagnostics && !path.IsAbs(p.LogDir) {
+		return nil, fmt.Errorf("capture_crash_diagnostics requires an absolute log_dir")
+	}
+	for _, ap := range p.AllowedPaths {
+		if !path.IsAbs(ap) {
+			return nil, fmt.Errorf("allowed_paths entry (%s) must be an absolute path", ap)
+		}
+	}
+	for name := range p.Rlimits {
+		if _, ok := RlimitResources[name]; !ok {
+			return nil, fmt.Errorf("unknown resource (%s) in rlimits, must be one of %s", name, strings.Join(RlimitResourceNames(), ", "))
+		}
+	}
+	switch p.ShutdownOrder {
+	case "", "children-first", "xpra-first", "reverse-launch-order":
+	default:
+		return nil, fmt.Errorf("invalid shutdown_order (%s), must be one of children-first, xpra-first, reverse-launch-order", p.ShutdownOrder)
+	}
+	switch p.ReadyWhen {
+	case "", "mounts", "xpra":
+	case "autostart-launched", "autostart-ready":
+		return nil, fmt.Errorf("ready_when (%s) requires autostart programs, which are not supported", p.ReadyWhen)
+	default:
+		return nil, fmt.Errorf("invalid ready_when (%s), must be one of mounts, xpra", p.ReadyWhen)
+	}
+	if p.ReadyWhen == "mounts" && len(p.ReadyProbe) > 0 {
+		return nil, fmt.Errorf("ready_probe cannot be used with a ready_when of mounts")
+	}
+	if p.ReadyPattern != "" {
+		if _, err := regexp.Compile(p.ReadyPattern); err != nil {
+			return nil, fmt.Errorf("invalid ready_pattern: %v", err)
+		}
+	}
 	if p.Networking.IpByte <= 1 || p.Networking.IpByte > 254 {
 		p.Networking.IpByte = 0
 	}
 	p.ProfilePath = fpath
 	return p, nil
 }
+
+var searchDomainLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// isValidSearchDomain checks that d is a hostname, with an optional trailing dot
+func isValidSearchDomain(d string) bool {
+	d = strings.TrimSuffix(d, ".")
+	if d == "" || len(d) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(d, ".") {
+		if !searchDomainLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package oz
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Resource numbers of the limits, by the names used in profiles
+var RlimitResources = map[string]int{
+	"cpu":        0,
+	"fsize":      1,
+	"data":       2,
+	"stack":      3,
+	"core":       4,
+	"rss":        5,
+	"nproc":      6,
+	"nofile":     7,
+	"memlock":    8,
+	"as":         9,
+	"locks":      10,
+	"sigpending": 11,
+	"msgqueue":   12,
+	"nice":       13,
+	"rtprio":     14,
+	"rttime":     15,
+}
+
+func RlimitResourceNames() []string {
+	names := make([]string, 0, len(RlimitResources))
+	for name := range RlimitResources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatRlimit returns the name=soft:hard form of a limit parsed by ParseRlimit
+func FormatRlimit(name string, l syscall.Rlimit) string {
+	return fmt.Sprintf("%s=%d:%d", name, l.Cur, l.Max)
+}
+
+// ParseRlimit parses a limit given as name=soft:hard
+func ParseRlimit(s string) (int, syscall.Rlimit, error) {
+	var l syscall.Rlimit
+	kv := strings.SplitN(s, "=", 2)
+	resource, ok := RlimitResources[kv[0]]
+	if !ok {
+		return 0, l, fmt.Errorf("unknown resource (%s), must be one of %s", kv[0], strings.Join(RlimitResourceNames(), ", "))
+	}
+	if len(kv) != 2 {
+		return 0, l, fmt.Errorf("invalid limit (%s), must be name=soft:hard", s)
+	}
+	limits := strings.SplitN(kv[1], ":", 2)
+	if len(limits) != 2 {
+		return 0, l, fmt.Errorf("invalid limit (%s), must be name=soft:hard", s)
+	}
+	var err error
+	if l.Cur, err = strconv.ParseUint(limits[0], 10, 64); err != nil {
+		return 0, l, fmt.Errorf("invalid soft limit (%s): %v", s, err)
+	}
+	if l.Max, err = strconv.ParseUint(limits[1], 10, 64); err != nil {
+		return 0, l, fmt.Errorf("invalid hard limit (%s): %v", s, err)
+	}
+	if l.Cur > l.Max {
+		return 0, l, fmt.Errorf("soft limit above the hard limit (%s)", s)
+	}
+	return resource, l, nil
+}
@@ -48,8 +48,17 @@ func prepareServerArgs(config *oz.XServerConf, display uint64, workdir string) [
 	args = append(args,
 		fmt.Sprintf("--bind=%s", workdir),
 		fmt.Sprintf("--socket-dir=%s", workdir),
-		"start",
+		startCommand(config.Mode),
 		fmt.Sprintf(":%d", display),
 	)
 	return args
 }
+
+// startCommand returns the xpra command starting a server in mode, desktop
+// mode runs a whole display the client shows in a single window.
+func startCommand(mode oz.XpraMode) string {
+	if mode == oz.PROFILE_XPRA_DESKTOP {
+		return "start-desktop"
+	}
+	return "start"
+}
@@ -12,6 +12,7 @@ import (
 	"path"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/subgraph/oz"
 )
@@ -41,16 +42,29 @@ var xpraDefaultArgs = []string{
 	"--xsettings",
 	//"--no-xsettings",
 	"--cursors",
-	"--encoding=rgb",
 }
 
+// Used when encoding is not set in the profile
+const defaultEncoding = "rgb"
+
 func getDefaultArgs(config *oz.XServerConf) []string {
 	args := []string{}
 	args = append(args, xpraDefaultArgs...)
-	if config.DisableClipboard {
+	encoding := config.Encoding
+	if encoding == "" {
+		encoding = defaultEncoding
+	}
+	args = append(args, "--encoding="+encoding)
+	if config.Quality > 0 {
+		args = append(args, "--quality="+strconv.Itoa(config.Quality))
+	}
+	if config.DisableClipboard || config.Clipboard == oz.PROFILE_CLIPBOARD_NONE {
 		args = append(args, "--no-clipboard")
 	} else {
 		args = append(args, "--clipboard")
+		if dir := clipboardDirection(config.Clipboard); dir != "" {
+			args = append(args, "--clipboard-direction="+dir)
+		}
 	}
 
 	// Temporarily disabled
@@ -75,17 +89,54 @@ func getDefaultArgs(config *oz.XServerConf) []string {
 	return args
 }
 
-func (x *Xpra) Stop(cred *syscall.Credential) ([]byte, error) {
+// clipboardDirection returns the xpra clipboard direction of a profile
+// clipboard mode, xpra calls the sandbox the server and the host the client.
+func clipboardDirection(mode oz.ClipboardMode) string {
+	switch mode {
+	case oz.PROFILE_CLIPBOARD_TO_SANDBOX:
+		return "to-server"
+	case oz.PROFILE_CLIPBOARD_FROM_SANDBOX:
+		return "to-client"
+	case oz.PROFILE_CLIPBOARD_BOTH:
+		return "both"
+	}
+	return ""
+}
+
+// ErrStopTimeout is returned by Stop when xpra stop did not return in time
+var ErrStopTimeout = errors.New("xpra stop timed out")
+
+// Stop runs xpra stop on the display and returns its output. The command is
+// killed when it has not returned after timeout.
+func (x *Xpra) Stop(cred *syscall.Credential, timeout time.Duration) ([]byte, error) {
 	cmd := exec.Command("/usr/bin/xpra",
 		"--socket-dir="+x.WorkDir,
 		"stop",
 		fmt.Sprintf(":%d", x.Display),
 	)
+	// In its own process group so that what it spawned is killed with it
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Credential: cred,
+		Setpgid:    true,
 	}
 	cmd.Env = []string{"TMPDIR=" + x.WorkDir}
-	return cmd.Output()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return out.Bytes(), err
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return out.Bytes(), ErrStopTimeout
+	}
 }
 
 func GetPath(u *user.User, name string) string {